@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"sync/atomic"
 )
 
 func cleanUpTempfile(f *os.File) {
@@ -21,6 +22,8 @@ const (
 	SubprocFateSucceeded SubprocFate = iota
 	SubprocFateFailed    SubprocFate = iota
 	SubprocFateCancelled SubprocFate = iota
+	SubprocFateSkipped   SubprocFate = iota // not run (e.g., due to a calendar)
+	SubprocFateWarning   SubprocFate = iota // ran, but with a warning
 )
 
 func (self SubprocFate) String() string {
@@ -31,6 +34,10 @@ func (self SubprocFate) String() string {
 		return "failed"
 	case SubprocFateCancelled:
 		return "cancelled"
+	case SubprocFateSkipped:
+		return "skipped"
+	case SubprocFateWarning:
+		return "warning"
 	default:
 		panic("Unhandled SubprocFate value")
 	}
@@ -41,9 +48,13 @@ func (self SubprocFate) String() string {
 // When done with this object (e.g., when done reading Stdout/Stderr), you must
 // call Close.
 type ExecResult struct {
-	Stdout io.ReadSeeker
-	Stderr io.ReadSeeker
-	Fate   SubprocFate
+	Stdout   io.ReadSeeker
+	Stderr   io.ReadSeeker
+	Fate     SubprocFate
+	ExitCode int // -1 if the subprocess was killed by a signal
+
+	// the limits that the subprocess exceeded, if any (cf. ExecParams)
+	LimitExceeded string
 }
 
 func (self *ExecResult) Close() {
@@ -92,7 +103,75 @@ func Sudo(usr user.User, cmdStr string) *exec.Cmd {
 	return su_cmd(usr.Username, cmdStr, "/bin/sh")
 }
 
+/*
+Parameters for ExecAndWaitWithParams.
+*/
+type ExecParams struct {
+	Args  []string
+	Input []byte
+
+	// extra environment variables ("NAME=value") for the subprocess
+	Env []string
+
+	/*
+		If not nil, this is called with the subprocess's PID right after
+		the subprocess has started.
+	*/
+	OnStart func(pid int)
+
+	// if not nil, limits on the subprocess's resources
+	Limits *ResourceLimits
+
+	// if not nil, restrictions on what the subprocess may see and do
+	Sandbox *Sandbox
+}
+
+/*
+Returned by ExecAndWaitWithParams if the subprocess's cgroup or sandbox
+couldn't be set up, in which case the subprocess wasn't started.
+*/
+type SetupError struct {
+	Cause error
+}
+
+func (self *SetupError) Error() string {
+	return self.Cause.Error()
+}
+
 func ExecAndWaitContext(ctx context.Context, args []string, input []byte) (*ExecResult, error) {
+	return ExecAndWaitWithParams(ctx, ExecParams{Args: args, Input: input})
+}
+
+func ExecAndWaitWithParams(ctx context.Context, params ExecParams) (*ExecResult, error) {
+	args, input := params.Args, params.Input
+	env := params.Env
+
+	// apply limits and sandbox
+	var cgroup string
+	if params.Sandbox != nil ||
+		(params.Limits != nil && params.Limits.needsHelper()) {
+
+		req := limitsHelperReq{Sandbox: params.Sandbox}
+		if params.Limits != nil {
+			req.Limits = *params.Limits
+		}
+		if req.Limits.usesCgroup() {
+			var err error
+			if cgroup, err = makeCgroup(&req.Limits); err != nil {
+				return nil, &SetupError{Cause: err}
+			}
+			defer removeCgroup(cgroup)
+			req.Cgroup = cgroup
+		}
+		var envVar string
+		var err error
+		args, envVar, err = limitedCommand(args, req)
+		if err != nil {
+			return nil, &SetupError{Cause: err}
+		}
+		env = append(env, envVar)
+	}
+
 	var cmd *exec.Cmd
 	var newCtx context.Context
 	var cancelSubproc context.CancelFunc
@@ -103,6 +182,16 @@ func ExecAndWaitContext(ctx context.Context, args []string, input []byte) (*Exec
 		defer cancelSubproc()
 		cmd = exec.CommandContext(newCtx, args[0], args[1:]...)
 	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if params.Sandbox != nil {
+		attr, err := sandboxProcAttr(params.Sandbox)
+		if err != nil {
+			return nil, &SetupError{Cause: err}
+		}
+		cmd.SysProcAttr = attr
+	}
 
 	// make temp files for stdout/stderr
 	stdout, err := ioutil.TempFile(TempDirPath(), "")
@@ -127,23 +216,31 @@ func ExecAndWaitContext(ctx context.Context, args []string, input []byte) (*Exec
 
 	// start cmd
 	if err = cmd.Start(); err != nil {
+		cleanUpTempfile(stdout)
+		cleanUpTempfile(stderr)
+		if params.Sandbox != nil {
+			return nil, &SetupError{Cause: sandboxStartError(err)}
+		}
 		return nil, fmt.Errorf("Failed to fork: %v", err)
 	}
+	if params.OnStart != nil {
+		params.OnStart(cmd.Process.Pid)
+	}
 
 	// write input
 	stdin.Write(input)
 	stdin.Close()
 
 	// launch cancelling thread
-	didCancel := false
+	var didCancel int32 // accessed atomically
 	if ctx != nil {
 		stop := make(chan struct{})
 		defer close(stop)
 		go func() {
 			select {
 			case <-ctx.Done():
+				atomic.StoreInt32(&didCancel, 1)
 				cancelSubproc()
-				didCancel = true
 				return
 			case <-stop:
 				return
@@ -176,13 +273,21 @@ func ExecAndWaitContext(ctx context.Context, args []string, input []byte) (*Exec
 	res := &ExecResult{}
 	res.Stdout = stdout
 	res.Stderr = stderr
+	res.ExitCode = cmd.ProcessState.ExitCode()
 	if waitErr == nil {
 		res.Fate = SubprocFateSucceeded
-	} else if didCancel {
+	} else if atomic.LoadInt32(&didCancel) != 0 {
 		res.Fate = SubprocFateCancelled
 	} else {
 		res.Fate = SubprocFateFailed
 	}
+	if params.Limits != nil && res.Fate != SubprocFateCancelled {
+		res.LimitExceeded = limitExceeded(params.Limits, cmd.ProcessState,
+			cgroup)
+		if len(res.LimitExceeded) > 0 {
+			res.Fate = SubprocFateFailed
+		}
+	}
 	return res, nil
 }
 
@@ -0,0 +1,240 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+/*
+Limits on the resources a subprocess may use (cf. ExecParams).  Zero
+values mean no limit.
+
+CPUTime, AddrSpace, OpenFiles, Nice, and the IO priority are applied
+with setrlimit, setpriority, and ioprio_set (Linux only) right before
+the subprocess's command is exec'ed.  MemoryMax and CPUMax are applied
+with a cgroup v2 cgroup made for the subprocess (Linux only), which
+requires that the runner's cgroup be delegated to its user; unless the
+memory and cpu controllers are already enabled for that cgroup's
+children, the runner moves itself into a child cgroup (named "runner")
+so that it can enable them.  If a subprocess's cgroup can't be made (or
+the helper that applies the limits can't be run), ExecAndWaitWithParams
+returns a *SetupError.
+*/
+type ResourceLimits struct {
+	CPUTime   time.Duration `json:"cpuTime,omitempty"`
+	AddrSpace uint64        `json:"addrSpace,omitempty"` // bytes
+	OpenFiles uint64        `json:"openFiles,omitempty"`
+	Nice      *int          `json:"nice,omitempty"`
+	IOClass   IOClass       `json:"ioClass,omitempty"`
+	IOLevel   int           `json:"ioLevel,omitempty"` // 0 (highest) to 7
+
+	// cgroup limits
+	MemoryMax uint64  `json:"memoryMax,omitempty"` // bytes
+	CPUMax    float64 `json:"cpuMax,omitempty"`    // number of CPUs
+}
+
+type IOClass int
+
+const (
+	IOClassNone       IOClass = 0
+	IOClassRealtime   IOClass = 1
+	IOClassBestEffort IOClass = 2
+	IOClassIdle       IOClass = 3
+)
+
+func (self IOClass) String() string {
+	switch self {
+	case IOClassRealtime:
+		return "realtime"
+	case IOClassBestEffort:
+		return "best-effort"
+	case IOClassIdle:
+		return "idle"
+	default:
+		return "none"
+	}
+}
+
+func (self *ResourceLimits) needsHelper() bool {
+	return self.CPUTime > 0 || self.AddrSpace > 0 || self.OpenFiles > 0 ||
+		self.Nice != nil || self.IOClass != IOClassNone || self.usesCgroup()
+}
+
+func (self *ResourceLimits) usesCgroup() bool {
+	return self.MemoryMax > 0 || self.CPUMax > 0
+}
+
+/*
+How much longer than CPUTime a subprocess may run before it is killed.
+At CPUTime it gets SIGXCPU, which it may catch.
+*/
+const gCPUTimeGrace = time.Second
+
+/*
+The environment variable by which ExecAndWaitWithParams tells the
+helper (cf. RunLimitsHelper) what to do.
+*/
+const gLimitsHelperEnvVar = "__JOBBER_LIMITS_HELPER"
+
+type limitsHelperReq struct {
+	Limits  ResourceLimits `json:"limits"`
+	Cgroup  string         `json:"cgroup,omitempty"` // dir to join
+	Sandbox *Sandbox       `json:"sandbox,omitempty"`
+}
+
+// (a var so that tests can make it fail)
+var gExecutable = os.Executable
+
+/*
+Make a command that runs the given args as described by req.  The
+command runs this program as a helper, which sets up the sandbox and
+applies the limits and then execs args.
+*/
+func limitedCommand(args []string, req limitsHelperReq) ([]string,
+	string, error) {
+
+	self, err := gExecutable()
+	if err != nil {
+		return nil, "", &Error{What: "Failed to find own executable",
+			Cause: err}
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, "", err
+	}
+	return append([]string{self}, args...),
+		gLimitsHelperEnvVar + "=" + string(data), nil
+}
+
+/*
+If this process was started by ExecAndWaitWithParams to apply resource
+limits or set up a sandbox, do so and exec the real command (never
+returning).  Otherwise, return right away.
+
+Programs that run subprocesses with limits or sandboxes must call this
+at the start of main (and tests, in TestMain).
+*/
+func RunLimitsHelper() {
+	data, ok := os.LookupEnv(gLimitsHelperEnvVar)
+	if !ok {
+		return
+	}
+	os.Unsetenv(gLimitsHelperEnvVar)
+
+	fail := func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "jobber: "+format+"\n", args...)
+		os.Exit(126)
+	}
+
+	var req limitsHelperReq
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		fail("invalid resource limits: %v", err)
+	}
+	if len(os.Args) < 2 {
+		fail("no command")
+	}
+	path, err := exec.LookPath(os.Args[1])
+	if err != nil {
+		fail("%v", err)
+	}
+
+	/*
+		Nice values and IO priorities belong to threads on Linux, so
+		set them on the thread that will do the exec.
+	*/
+	runtime.LockOSThread()
+
+	if len(req.Cgroup) > 0 {
+		if err := joinCgroup(req.Cgroup); err != nil {
+			fail("%v", err)
+		}
+	}
+	if req.Sandbox != nil {
+		if err := req.Sandbox.enter(); err != nil {
+			fail("%v", err)
+		}
+	}
+	if err := applyLimits(&req.Limits); err != nil {
+		fail("%v", err)
+	}
+	if req.Sandbox != nil {
+		if err := req.Sandbox.lockPrivs(); err != nil {
+			fail("%v", err)
+		}
+	}
+
+	err = syscall.Exec(path, os.Args[1:], os.Environ())
+	fail("failed to exec %v: %v", path, err)
+}
+
+func applyLimits(limits *ResourceLimits) error {
+	if limits.Nice != nil {
+		err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, *limits.Nice)
+		if err != nil {
+			return &Error{What: "Failed to set nice", Cause: err}
+		}
+	}
+	if limits.IOClass != IOClassNone {
+		if err := setIOPriority(limits.IOClass, limits.IOLevel); err != nil {
+			return &Error{What: "Failed to set IO priority", Cause: err}
+		}
+	}
+	if limits.OpenFiles > 0 {
+		err := setrlimit(syscall.RLIMIT_NOFILE, limits.OpenFiles,
+			limits.OpenFiles)
+		if err != nil {
+			return &Error{What: "Failed to limit open files", Cause: err}
+		}
+	}
+	if limits.CPUTime > 0 {
+		secs := uint64((limits.CPUTime + time.Second - 1) / time.Second)
+		grace := uint64(gCPUTimeGrace / time.Second)
+		if err := setrlimit(syscall.RLIMIT_CPU, secs, secs+grace); err != nil {
+			return &Error{What: "Failed to limit CPU time", Cause: err}
+		}
+	}
+	/* last, so that it doesn't get in the way of the above */
+	if limits.AddrSpace > 0 {
+		err := setrlimit(syscall.RLIMIT_AS, limits.AddrSpace,
+			limits.AddrSpace)
+		if err != nil {
+			return &Error{What: "Failed to limit address space", Cause: err}
+		}
+	}
+	return nil
+}
+
+/*
+Find out whether a finished subprocess exceeded one of its limits.
+Returns a description of the limit, or "".
+*/
+func limitExceeded(limits *ResourceLimits, state *os.ProcessState,
+	cgroup string) string {
+
+	var violations []string
+	if limits.CPUTime > 0 {
+		status, ok := state.Sys().(syscall.WaitStatus)
+		cpuTime := state.UserTime() + state.SystemTime()
+		killed := ok && status.Signaled() &&
+			(status.Signal() == syscall.SIGXCPU ||
+				(status.Signal() == syscall.SIGKILL && cpuTime >= limits.CPUTime))
+		/* a shell reports that a child was killed this way */
+		childKilled := ok && status.Exited() &&
+			status.ExitStatus() == 128+int(syscall.SIGXCPU)
+		if killed || childKilled {
+			violations = append(violations,
+				fmt.Sprintf("CPU time limit (%v)", limits.CPUTime))
+		}
+	}
+	if len(cgroup) > 0 && cgroupOOMKilled(cgroup) {
+		violations = append(violations,
+			fmt.Sprintf("memory limit (%v bytes)", limits.MemoryMax))
+	}
+	return strings.Join(violations, ", ")
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"syscall"
+)
+
+func setrlimit(resource int, soft, hard uint64) error {
+	/* don't try to raise the hard limit (which only root can do) */
+	var curr syscall.Rlimit
+	if err := syscall.Getrlimit(resource, &curr); err != nil {
+		return err
+	}
+	if hard > curr.Max {
+		hard = curr.Max
+	}
+	if soft > hard {
+		soft = hard
+	}
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: soft, Max: hard})
+}
+
+func setIOPriority(class IOClass, level int) error {
+	return &Error{What: "IO priorities are supported only on Linux"}
+}
+
+func makeCgroup(limits *ResourceLimits) (string, error) {
+	return "", &Error{What: "cgroup limits are supported only on Linux"}
+}
+
+func joinCgroup(dir string) error {
+	return &Error{What: "cgroup limits are supported only on Linux"}
+}
+
+func cgroupOOMKilled(dir string) bool {
+	return false
+}
+
+func removeCgroup(dir string) {
+}
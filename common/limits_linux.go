@@ -0,0 +1,271 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+func setrlimit(resource int, soft, hard uint64) error {
+	/* don't try to raise the hard limit (which only root can do) */
+	var curr syscall.Rlimit
+	if err := syscall.Getrlimit(resource, &curr); err != nil {
+		return err
+	}
+	if hard > curr.Max {
+		hard = curr.Max
+	}
+	if soft > hard {
+		soft = hard
+	}
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: soft, Max: hard})
+}
+
+const (
+	gIoprioWhoProcess = 1
+	gIoprioClassShift = 13
+)
+
+func setIOPriority(class IOClass, level int) error {
+	prio := uintptr(class)<<gIoprioClassShift | uintptr(level)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET,
+		gIoprioWhoProcess, 0, prio)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+/*
+The name of the cgroup into which the runner moves itself, so that
+controllers can be enabled for its subprocesses' cgroups.  (A cgroup
+that has processes can't have controllers enabled for its children.)
+*/
+const gRunnerCgroupName = "runner"
+
+/* cf. cpu.max */
+const gCgroupCPUPeriod = 100000 // microseconds
+
+var gCgroupParent struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+var gCgroupCount uint64 // accessed atomically
+
+/*
+Find where the cgroup v2 hierarchy is mounted.
+*/
+func cgroup2Mount() (string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[2] == "cgroup2" {
+			return fields[1], nil
+		}
+	}
+	return "", &Error{What: "cgroup v2 is not mounted"}
+}
+
+/*
+Get this process's cgroup (relative to the cgroup v2 mount).
+*/
+func ownCgroup() (string, error) {
+	data, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	return "", &Error{What: "This process isn't in a cgroup v2 cgroup"}
+}
+
+func writeCgroupFile(dir string, name string, value string) error {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(value), 0); err != nil {
+		msg := fmt.Sprintf("Failed to write \"%v\" to %v (has the cgroup "+
+			"been delegated to this user?)", value, path)
+		return &Error{What: msg, Cause: err}
+	}
+	return nil
+}
+
+/*
+Get the cgroup in which to make subprocesses' cgroups: this process's
+cgroup, which must have been delegated to its user.  The first time,
+we enable whichever of the memory and cpu controllers are available
+for its children, unless they're enabled already; to do that, this
+process must first move itself (and we log that it does) into a child
+cgroup named gRunnerCgroupName.
+*/
+func cgroupParent() (string, error) {
+	gCgroupParent.once.Do(func() {
+		gCgroupParent.path, gCgroupParent.err = setUpCgroupParent()
+	})
+	return gCgroupParent.path, gCgroupParent.err
+}
+
+func setUpCgroupParent() (string, error) {
+	mount, err := cgroup2Mount()
+	if err != nil {
+		return "", err
+	}
+	own, err := ownCgroup()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(mount, own)
+	movedAlready := filepath.Base(dir) == gRunnerCgroupName
+	if movedAlready {
+		/* done by an earlier instance of this program */
+		dir = filepath.Dir(dir)
+	}
+
+	// check which controllers we can use
+	data, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return "", &Error{What: "Failed to get cgroup controllers", Cause: err}
+	}
+	var enable []string
+	for _, controller := range strings.Fields(string(data)) {
+		if controller == "memory" || controller == "cpu" {
+			enable = append(enable, "+"+controller)
+		}
+	}
+	if len(enable) == 0 {
+		msg := fmt.Sprintf("Neither the memory nor the cpu cgroup "+
+			"controller is available in %v", dir)
+		return "", &Error{What: msg}
+	}
+
+	// are they enabled already?
+	data, err = ioutil.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+	if err != nil {
+		return "", &Error{What: "Failed to get cgroup controllers", Cause: err}
+	}
+	enabled := make(map[string]bool)
+	for _, controller := range strings.Fields(string(data)) {
+		enabled["+"+controller] = true
+	}
+	allEnabled := true
+	for _, controller := range enable {
+		allEnabled = allEnabled && enabled[controller]
+	}
+	if allEnabled {
+		return dir, nil
+	}
+
+	// move out of the way
+	if !movedAlready {
+		runnerDir := filepath.Join(dir, gRunnerCgroupName)
+		Logger.Printf("Moving this process (pid %v) into cgroup %v, so "+
+			"that cgroup controllers can be enabled for jobs' cgroups",
+			os.Getpid(), runnerDir)
+		if err := os.Mkdir(runnerDir, 0755); err != nil && !os.IsExist(err) {
+			return "", &Error{What: "Failed to make cgroup", Cause: err}
+		}
+		err := writeCgroupFile(runnerDir, "cgroup.procs",
+			strconv.Itoa(os.Getpid()))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// enable controllers
+	if err := writeCgroupFile(dir, "cgroup.subtree_control",
+		strings.Join(enable, " ")); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+/*
+Make a cgroup with the given limits for a subprocess.  Returns its dir.
+*/
+func makeCgroup(limits *ResourceLimits) (string, error) {
+	parent, err := cgroupParent()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("job-%v", atomic.AddUint64(&gCgroupCount, 1))
+	dir := filepath.Join(parent, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", &Error{What: "Failed to make cgroup", Cause: err}
+	}
+
+	if limits.MemoryMax > 0 {
+		err := writeCgroupFile(dir, "memory.max",
+			strconv.FormatUint(limits.MemoryMax, 10))
+		if err != nil {
+			os.Remove(dir)
+			return "", err
+		}
+	}
+	if limits.CPUMax > 0 {
+		quota := int64(limits.CPUMax * gCgroupCPUPeriod)
+		err := writeCgroupFile(dir, "cpu.max",
+			fmt.Sprintf("%v %v", quota, gCgroupCPUPeriod))
+		if err != nil {
+			os.Remove(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func joinCgroup(dir string) error {
+	return writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(os.Getpid()))
+}
+
+/*
+Whether a process in the cgroup was killed for exceeding memory.max.
+*/
+func cgroupOOMKilled(dir string) bool {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			n, _ := strconv.Atoi(fields[1])
+			return n > 0
+		}
+	}
+	return false
+}
+
+/*
+Remove a subprocess's cgroup, killing any processes (e.g., ones started
+in the background) that are left in it.
+*/
+func removeCgroup(dir string) {
+	/* cgroup.kill exists in Linux >= 5.14 */
+	ioutil.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0)
+
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = os.Remove(dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	ErrLogger.Printf("Failed to remove cgroup %v: %v", dir, err)
+}
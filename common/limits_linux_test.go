@@ -0,0 +1,25 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecCgroupSetupFails(t *testing.T) {
+	/* pretend that the runner's cgroup couldn't be set up */
+	gCgroupParent.once.Do(func() {
+		gCgroupParent.err = &Error{What: "cgroup v2 is not mounted"}
+	})
+	if gCgroupParent.err == nil {
+		t.Skip("cgroup already set up")
+	}
+
+	res, err := ExecAndWaitWithParams(nil, ExecParams{
+		Args:   []string{"/bin/sh", "-c", "true"},
+		Limits: &ResourceLimits{MemoryMax: 1 << 30},
+	})
+	require.Nil(t, res)
+	require.IsType(t, &SetupError{}, err)
+	require.Contains(t, err.Error(), "cgroup v2 is not mounted")
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	/* ExecAndWaitWithParams runs this binary to apply limits */
+	RunLimitsHelper()
+	os.Exit(m.Run())
+}
+
+func execWithLimits(t *testing.T, cmd string,
+	limits ResourceLimits) (*ExecResult, string) {
+
+	res, err := ExecAndWaitWithParams(context.Background(), ExecParams{
+		Args:   []string{"/bin/sh", "-c", cmd},
+		Limits: &limits,
+	})
+	require.Nil(t, err)
+	stdout, err := res.ReadStdout(1024)
+	require.Nil(t, err)
+	return res, strings.TrimSpace(string(stdout))
+}
+
+func TestExecRlimits(t *testing.T) {
+	res, stdout := execWithLimits(t, "ulimit -n; ulimit -t; ulimit -v",
+		ResourceLimits{
+			OpenFiles: 64,
+			CPUTime:   30 * time.Second,
+			AddrSpace: 1 << 30,
+		})
+	defer res.Close()
+	require.Equal(t, SubprocFateSucceeded, res.Fate)
+	require.Equal(t, "", res.LimitExceeded)
+	require.Equal(t, []string{"64", "30", "1048576"}, strings.Fields(stdout))
+}
+
+func TestExecNice(t *testing.T) {
+	nice := 7
+	res, stdout := execWithLimits(t, "nice", ResourceLimits{Nice: &nice})
+	defer res.Close()
+	require.Equal(t, SubprocFateSucceeded, res.Fate)
+	require.Equal(t, "7", stdout)
+}
+
+func TestExecIOPriority(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("IO priorities are supported only on Linux")
+	}
+	res, stdout := execWithLimits(t, "ionice",
+		ResourceLimits{IOClass: IOClassIdle})
+	defer res.Close()
+	require.Equal(t, SubprocFateSucceeded, res.Fate)
+	require.Equal(t, "idle", stdout)
+}
+
+func TestExecCPUTimeExceeded(t *testing.T) {
+	res, _ := execWithLimits(t, "while :; do :; done",
+		ResourceLimits{CPUTime: time.Second})
+	defer res.Close()
+	require.Equal(t, SubprocFateFailed, res.Fate)
+	require.Equal(t, "CPU time limit (1s)", res.LimitExceeded)
+}
+
+func TestExecBadLimits(t *testing.T) {
+	/* unprivileged users can't lower their nice values */
+	if os.Geteuid() == 0 {
+		t.Skip("Running as root")
+	}
+	nice := -5
+	res, _ := execWithLimits(t, "true", ResourceLimits{Nice: &nice})
+	defer res.Close()
+	require.Equal(t, SubprocFateFailed, res.Fate)
+	require.Equal(t, 126, res.ExitCode)
+}
+
+func TestExecHelperNotFound(t *testing.T) {
+	/* pretend that this program's executable can't be found */
+	gExecutable = func() (string, error) {
+		return "", &Error{What: "no such file"}
+	}
+	defer func() { gExecutable = os.Executable }()
+
+	res, err := ExecAndWaitWithParams(context.Background(), ExecParams{
+		Args:   []string{"/bin/sh", "-c", "true"},
+		Limits: &ResourceLimits{OpenFiles: 64},
+	})
+	require.Nil(t, res)
+	require.IsType(t, &SetupError{}, err)
+	require.Contains(t, err.Error(), "Failed to find own executable")
+}
@@ -0,0 +1,70 @@
+package common
+
+/*
+Restrictions on what a subprocess may see and do (cf. ExecParams).
+
+The subprocess is started in new user and mount namespaces (and, with
+NoNetwork, a new network namespace), and then the helper (cf.
+RunLimitsHelper) sets up its view of the filesystem before exec'ing its
+command.  This needs no privileges, but it does need a kernel that lets
+unprivileged users make user namespaces; if the sandbox can't be made,
+ExecAndWaitWithParams returns a *SetupError.  Linux only (cf.
+SandboxesSupported).
+*/
+type Sandbox struct {
+	// give the subprocess its own, empty /tmp
+	PrivateTmp bool `json:"privateTmp,omitempty"`
+
+	// paths (absolute) that the subprocess may read but not modify (nor
+	// anything mounted under them)
+	ReadOnly []string `json:"readOnly,omitempty"`
+
+	// give the subprocess a network namespace with only a loopback device
+	NoNetwork bool `json:"noNetwork,omitempty"`
+
+	// keep the subprocess from gaining privileges (e.g., via setuid programs)
+	NoNewPrivs bool `json:"noNewPrivs,omitempty"`
+}
+
+/*
+Set up the sandbox for the current process, which must have been started
+with the attributes from sandboxProcAttr.
+*/
+func (self *Sandbox) enter() error {
+	if err := makeMountsPrivate(); err != nil {
+		return &Error{What: "Failed to make mounts private", Cause: err}
+	}
+	if self.PrivateTmp {
+		if err := mountPrivateTmp(); err != nil {
+			return &Error{What: "Failed to make private /tmp", Cause: err}
+		}
+	}
+	for _, path := range self.ReadOnly {
+		if err := bindReadOnly(path); err != nil {
+			return &Error{What: "Failed to make " + path + " read-only",
+				Cause: err}
+		}
+	}
+	if self.NoNetwork {
+		if err := bringUpLoopback(); err != nil {
+			return &Error{What: "Failed to bring up loopback device",
+				Cause: err}
+		}
+	}
+	return nil
+}
+
+/*
+Set the no_new_privs flag, which is inherited across execve.  This is
+done right before exec'ing the command, after anything that might need
+privileges within the sandbox.
+*/
+func (self *Sandbox) lockPrivs() error {
+	if !self.NoNewPrivs {
+		return nil
+	}
+	if err := setNoNewPrivs(); err != nil {
+		return &Error{What: "Failed to set no_new_privs", Cause: err}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+/* cf. Sandbox */
+const SandboxesSupported = true
+
+/* cf. prctl(2) */
+const gPrSetNoNewPrivs = 38
+
+/*
+Make the attributes with which to start a sandboxed subprocess: new
+namespaces, in which the subprocess has the same UID and GID as this
+process.
+*/
+func sandboxProcAttr(sandbox *Sandbox) (*syscall.SysProcAttr, error) {
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS)
+	if sandbox.NoNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+	return &syscall.SysProcAttr{
+		Cloneflags: flags,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: uid, HostID: uid, Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: gid, HostID: gid, Size: 1},
+		},
+		GidMappingsEnableSetgroups: false,
+	}, nil
+}
+
+/*
+Explain why a sandboxed subprocess couldn't be started.  Usually, it's
+because the kernel doesn't let unprivileged users make user namespaces.
+*/
+func sandboxStartError(err error) error {
+	settings := []struct {
+		path     string
+		disabled string
+	}{
+		{"/proc/sys/kernel/unprivileged_userns_clone", "0"},
+		{"/proc/sys/user/max_user_namespaces", "0"},
+		{"/proc/sys/kernel/apparmor_restrict_unprivileged_userns", "1"},
+	}
+	for _, setting := range settings {
+		data, readErr := ioutil.ReadFile(setting.path)
+		if readErr != nil {
+			continue
+		}
+		if strings.TrimSpace(string(data)) == setting.disabled {
+			name := strings.Replace(
+				strings.TrimPrefix(setting.path, "/proc/sys/"), "/", ".", -1)
+			msg := fmt.Sprintf("Failed to make sandbox: the kernel doesn't "+
+				"allow unprivileged user namespaces (%v = %v)", name,
+				setting.disabled)
+			return &Error{What: msg, Cause: err}
+		}
+	}
+	return &Error{What: "Failed to make sandbox (does the kernel allow " +
+		"unprivileged user namespaces?)", Cause: err}
+}
+
+func makeMountsPrivate() error {
+	/* so that our mounts don't propagate out of the namespace */
+	return syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, "")
+}
+
+func mountPrivateTmp() error {
+	return syscall.Mount("tmpfs", "/tmp", "tmpfs",
+		syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777")
+}
+
+/*
+Flags that, if set on a mount made outside a user namespace, are locked
+and so must be kept when it is remounted inside the namespace.  The
+statfs flags have the same values as the corresponding mount flags.
+*/
+const gLockedMountFlags = syscall.MS_NOSUID | syscall.MS_NODEV |
+	syscall.MS_NOEXEC | syscall.MS_NOATIME | syscall.MS_NODIRATIME
+
+/* cf. statfs(2) */
+const gStRelatime = 4096
+
+/*
+Bind-mount path (and the mounts under it) onto itself and make all of
+them read-only.  (MS_RDONLY applies only to the mount that is
+remounted, so each submount must be remounted separately.)
+*/
+func bindReadOnly(path string) error {
+	err := syscall.Mount(path, path, "", syscall.MS_BIND|syscall.MS_REC, "")
+	if err != nil {
+		return err
+	}
+	mountinfo, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if err := remountReadOnly(path); err != nil {
+		return err
+	}
+	for _, mountPoint := range submounts(string(mountinfo), realPath) {
+		err := remountReadOnly(mountPoint)
+		if os.IsPermission(err) {
+			/* The subprocess can't get to it either. */
+			continue
+		} else if err != nil {
+			return &Error{What: "Failed to make " + mountPoint +
+				" read-only", Cause: err}
+		}
+	}
+	return nil
+}
+
+func remountReadOnly(path string) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	flags := uintptr(st.Flags) & gLockedMountFlags
+	if st.Flags&gStRelatime != 0 {
+		flags |= syscall.MS_RELATIME
+	}
+	return syscall.Mount("", path, "",
+		syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|flags, "")
+}
+
+/*
+Get the mount points (from the given contents of /proc/self/mountinfo)
+that are under path, not counting path itself.  Each is listed once.
+*/
+func submounts(mountinfo string, path string) []string {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	seen := make(map[string]bool)
+	var mountPoints []string
+	for _, line := range strings.Split(mountinfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		mountPoint := unescapeMountinfoField(fields[4])
+		if mountPoint == path || !strings.HasPrefix(mountPoint, prefix) ||
+			seen[mountPoint] {
+			continue
+		}
+		seen[mountPoint] = true
+		mountPoints = append(mountPoints, mountPoint)
+	}
+	return mountPoints
+}
+
+/*
+The kernel escapes spaces, tabs, newlines, and backslashes in
+mountinfo's fields as octal sequences (e.g., "\040").
+*/
+func unescapeMountinfoField(field string) string {
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if n, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+/*
+A new network namespace has a loopback device, but it is down.
+*/
+func bringUpLoopback() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var req struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+	copy(req.name[:], "lo")
+	req.flags = syscall.IFF_UP | syscall.IFF_LOOPBACK | syscall.IFF_RUNNING
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func setNoNewPrivs() error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, gPrSetNoNewPrivs,
+		1, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmounts(t *testing.T) {
+	mountinfo := `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+30 22 0:40 / /srv/data rw,relatime - ext4 /dev/sdb1 rw
+31 30 0:41 / /srv/data/cache rw,relatime - tmpfs tmpfs rw
+32 30 0:42 / /srv/data/my\040photos rw,relatime - ext4 /dev/sdc1 rw
+33 22 0:43 / /srv/database rw,relatime - ext4 /dev/sdd1 rw
+34 31 0:41 / /srv/data/cache rw,relatime - tmpfs tmpfs rw
+`
+	require.Equal(t, []string{"/srv/data/cache", "/srv/data/my photos"},
+		submounts(mountinfo, "/srv/data"))
+	require.Equal(t, []string{"/srv/data", "/srv/data/cache",
+		"/srv/data/my photos", "/srv/database"}, submounts(mountinfo, "/"))
+	require.Nil(t, submounts(mountinfo, "/srv/data/cache"))
+}
+
+func TestSandboxReadOnlySubmount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobber-sandbox-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	require.Nil(t, os.Mkdir(sub, 0755))
+	if err := syscall.Mount("tmpfs", sub, "tmpfs", 0, ""); err != nil {
+		t.Skipf("Can't mount tmpfs: %v", err)
+	}
+	defer syscall.Unmount(sub, 0)
+	path := filepath.Join(sub, "file")
+
+	res, stdout := execInSandbox(t,
+		"echo bye > "+path+" 2>/dev/null || echo denied",
+		Sandbox{ReadOnly: []string{dir}})
+	defer res.Close()
+	require.Equal(t, SubprocFateSucceeded, res.Fate)
+	require.Equal(t, "denied", stdout)
+
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,38 @@
+// +build !linux
+
+package common
+
+import (
+	"syscall"
+)
+
+/* cf. Sandbox */
+const SandboxesSupported = false
+
+func sandboxProcAttr(sandbox *Sandbox) (*syscall.SysProcAttr, error) {
+	return nil, &Error{What: "Sandboxes are supported only on Linux"}
+}
+
+func sandboxStartError(err error) error {
+	return err
+}
+
+func makeMountsPrivate() error {
+	return &Error{What: "Sandboxes are supported only on Linux"}
+}
+
+func mountPrivateTmp() error {
+	return &Error{What: "Sandboxes are supported only on Linux"}
+}
+
+func bindReadOnly(path string) error {
+	return &Error{What: "Sandboxes are supported only on Linux"}
+}
+
+func bringUpLoopback() error {
+	return &Error{What: "Sandboxes are supported only on Linux"}
+}
+
+func setNoNewPrivs() error {
+	return &Error{What: "Sandboxes are supported only on Linux"}
+}
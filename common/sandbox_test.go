@@ -0,0 +1,106 @@
+package common
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func execInSandbox(t *testing.T, cmd string,
+	sandbox Sandbox) (*ExecResult, string) {
+
+	if runtime.GOOS != "linux" {
+		t.Skip("Sandboxes are supported only on Linux")
+	}
+	res, err := ExecAndWaitWithParams(context.Background(), ExecParams{
+		Args:    []string{"/bin/sh", "-c", cmd},
+		Sandbox: &sandbox,
+	})
+	if err != nil && strings.Contains(err.Error(), "user namespaces") {
+		t.Skipf("Can't make sandboxes here: %v", err)
+	}
+	require.Nil(t, err)
+	stdout, err := res.ReadStdout(1024)
+	require.Nil(t, err)
+	return res, strings.TrimSpace(string(stdout))
+}
+
+func TestSandboxPrivateTmp(t *testing.T) {
+	f, err := ioutil.TempFile("/tmp", "jobber-sandbox-test")
+	require.Nil(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	res, stdout := execInSandbox(t,
+		"test -e "+f.Name()+" && echo visible; touch /tmp/x && ls /tmp",
+		Sandbox{PrivateTmp: true})
+	defer res.Close()
+	require.Equal(t, SubprocFateSucceeded, res.Fate)
+	require.Equal(t, "x", stdout)
+}
+
+func TestSandboxReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobber-sandbox-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	require.Nil(t, ioutil.WriteFile(path, []byte("hi\n"), 0644))
+
+	res, stdout := execInSandbox(t,
+		"cat "+path+"; echo bye > "+path+" 2>/dev/null || echo denied",
+		Sandbox{ReadOnly: []string{dir}})
+	defer res.Close()
+	require.Equal(t, SubprocFateSucceeded, res.Fate)
+	require.Equal(t, "hi\ndenied", stdout)
+
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, "hi\n", string(data))
+}
+
+func TestSandboxNoNetwork(t *testing.T) {
+	/* (/sys/class/net shows the network namespace in which /sys was mounted) */
+	res, stdout := execInSandbox(t, "tail -n +3 /proc/net/dev | cut -d: -f1",
+		Sandbox{NoNetwork: true})
+	defer res.Close()
+	require.Equal(t, SubprocFateSucceeded, res.Fate)
+	require.Equal(t, "lo", stdout)
+}
+
+func TestSandboxNoNewPrivs(t *testing.T) {
+	res, stdout := execInSandbox(t,
+		"grep '^NoNewPrivs:' /proc/self/status",
+		Sandbox{NoNewPrivs: true})
+	defer res.Close()
+	require.Equal(t, SubprocFateSucceeded, res.Fate)
+	require.Equal(t, []string{"NoNewPrivs:", "1"}, strings.Fields(stdout))
+}
+
+func TestSandboxBadPath(t *testing.T) {
+	res, _ := execInSandbox(t, "true",
+		Sandbox{ReadOnly: []string{"/no/such/path"}})
+	defer res.Close()
+	require.Equal(t, SubprocFateFailed, res.Fate)
+	stderr, err := res.ReadStderr(1024)
+	require.Nil(t, err)
+	require.Contains(t, string(stderr),
+		"Failed to make /no/such/path read-only")
+}
+
+func TestSandboxUnsupported(t *testing.T) {
+	if SandboxesSupported {
+		t.Skip("Sandboxes are supported here")
+	}
+	res, err := ExecAndWaitWithParams(context.Background(), ExecParams{
+		Args:    []string{"/bin/sh", "-c", "true"},
+		Sandbox: &Sandbox{NoNewPrivs: true},
+	})
+	require.Nil(t, res)
+	require.IsType(t, &SetupError{}, err)
+}
@@ -170,6 +170,32 @@ func CmdSocketPath(usr *user.User) string {
 	return filepath.Join(PerUserDirPath(usr), cmdSocketFileName)
 }
 
+/*
+Path of the socket on which the user's runner publishes events.
+*/
+func EventSocketPath(usr *user.User) string {
+	const eventSocketFileName = "events.sock"
+	return filepath.Join(PerUserDirPath(usr), eventSocketFileName)
+}
+
+/*
+Path of the socket on which jobbermaster publishes the events of all
+users' runners.
+*/
+func MasterEventSocketPath() string {
+	const eventSocketFileName = "events.sock"
+	return filepath.Join(VarDirPath(), eventSocketFileName)
+}
+
+/*
+Path of the socket on which jobbermaster serves locks shared by all
+users' jobs to the user's runner.
+*/
+func LockSocketPath(usr *user.User) string {
+	const lockSocketFileName = "locks.sock"
+	return filepath.Join(PerUserDirPath(usr), lockSocketFileName)
+}
+
 func LibexecProgramPath(name string) string {
 	return filepath.Join(LibexecDirPath(), name)
 }
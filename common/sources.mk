@@ -2,7 +2,14 @@ COMMON_SOURCES := \
 	common/consts.go \
 	common/error.go \
 	common/exec.go \
+	common/limits.go \
+	common/limits_darwin.go \
+	common/limits_freebsd.go \
+	common/limits_linux.go \
 	common/logging.go \
+	common/sandbox.go \
+	common/sandbox_linux.go \
+	common/sandbox_other.go \
 	common/settings.go \
 	common/sources.mk \
 	common/su_cmd_darwin.go \
@@ -12,4 +19,8 @@ COMMON_SOURCES := \
 	common/version.go
 
 COMMON_TEST_SOURCES := \
-	common/prefs_file_test.go
+	common/limits_linux_test.go \
+	common/limits_test.go \
+	common/prefs_file_test.go \
+	common/sandbox_linux_test.go \
+	common/sandbox_test.go
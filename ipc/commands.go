@@ -45,7 +45,9 @@ type JobDesc struct {
 	NotifyOnSuccess string     `json:"notifyOnSuccess"`
 	NotifyOnErr     string     `json:"notifyOnError"`
 	NotifyOnFail    string     `json:"notifyOnFailure"`
+	NotifyOnWarn    string     `json:"notifyOnWarning"`
 	ErrHandler      string     `json:"errHandler"`
+	Lock            string     `json:"lock,omitempty"` // with its state, if held or awaited
 }
 
 type ListJobsCmd struct{}
@@ -62,12 +64,41 @@ type LogDesc struct {
 	Fate      string        `json:"fate"`
 	ExecTime  time.Duration `json:"exectime"`
 	Result    string        `json:"result"`
+	Manual    bool          `json:"manual"`
+	RunId     string        `json:"runId"` // "" for old runs
+
+	// why the run was skipped (if Fate is "skipped")
+	SkipReason string `json:"skipReason,omitempty"`
+
+	// which attempt this was, for jobs with a retry policy
+	Attempt int `json:"attempt,omitempty"`
+
+	// how long the run waited for a slot (cf. maxConcurrentJobs)
+	QueueDelay time.Duration `json:"queueDelay,omitempty"`
+
+	// the resource limits the run exceeded, if any
+	LimitExceeded string `json:"limitExceeded,omitempty"`
 }
 
-type LogCmd struct{}
+/*
+Gets run log entries, latest first.  All fields are optional.
+*/
+type LogCmd struct {
+	Jobs  []string   `json:"jobs"`
+	Fates []string   `json:"fates"` // e.g., "failed"
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+	Limit int        `json:"limit"`
+
+	// NextCursor from a previous LogCmd with the same filters
+	Cursor string `json:"cursor"`
+}
 
 type LogCmdResp struct {
 	Logs []LogDesc `json:"logs"`
+
+	// if not "", there may be more entries
+	NextCursor string `json:"nextCursor"`
 	nonErrorCmdResp
 }
 
@@ -80,6 +111,32 @@ type TestCmdResp struct {
 	nonErrorCmdResp
 }
 
+/*
+Gets the stored output of a run of a job.  If List is true, instead
+describes all the stored runs of the job.
+*/
+type OutputCmd struct {
+	Job  string `json:"job"`
+	Run  string `json:"run"` // run ID; "" means the latest run
+	List bool   `json:"list"`
+}
+
+type RunOutputDesc struct {
+	Run       string    `json:"run"` // run ID
+	Time      time.Time `json:"time"`
+	Fate      string    `json:"fate"`
+	StdoutLen int       `json:"stdoutLen"`
+	StderrLen int       `json:"stderrLen"`
+}
+
+type OutputCmdResp struct {
+	Runs   []RunOutputDesc `json:"runs"` // if List
+	Run    RunOutputDesc   `json:"run"`  // if !List
+	Stdout []byte          `json:"stdout"`
+	Stderr []byte          `json:"stderr"`
+	nonErrorCmdResp
+}
+
 type CatCmd struct {
 	Job string `json:"job"`
 }
@@ -114,25 +171,101 @@ type InitCmdResp struct {
 	nonErrorCmdResp
 }
 
+type NextRunsCmd struct {
+	Job   string     `json:"job"`
+	Count int        `json:"count"`
+	From  *time.Time `json:"from"` // if nil, now
+}
+
+type NextRunsCmdResp struct {
+	Schedule string      `json:"schedule"`
+	Times    []time.Time `json:"times"`
+	nonErrorCmdResp
+}
+
+type RunCmd struct {
+	Job string `json:"job"`
+}
+
+type RunCmdResp struct {
+	Ok bool `json:"ok"` // just to make IPC work
+	nonErrorCmdResp
+}
+
+type RunningJobDesc struct {
+	Job       string    `json:"job"`
+	RunId     string    `json:"runId"`
+	Pid       int       `json:"pid"`
+	StartTime time.Time `json:"startTime"`
+	Manual    bool      `json:"manual"`
+	Lock      string    `json:"lock,omitempty"`
+	LockHeld  bool      `json:"lockHeld,omitempty"`
+	Queued    bool      `json:"queued,omitempty"` // waiting for a slot
+}
+
+type PsCmd struct{}
+
+type PsCmdResp struct {
+	Runs []RunningJobDesc `json:"runs"`
+	nonErrorCmdResp
+}
+
+type KillCmd struct {
+	Job string `json:"job"`
+}
+
+type KillCmdResp struct {
+	NumKilled int `json:"numKilled"`
+	nonErrorCmdResp
+}
+
 type JobV3RawWithName struct {
 	jobfile.JobV3Raw
 	Name string
 }
 
+/*
+SetJobCmd and DeleteJobCmd change the jobfile on disk and then reload
+it.  With DryRun, they just check the change and report what the
+jobfile would become.
+*/
+
 type SetJobCmd struct {
 	Job JobV3RawWithName `json:"job"`
+
+	// fail if there already is a job with this name
+	MustBeNew bool `json:"mustBeNew"`
+
+	/*
+		Fail if there is no job with this name, and keep the existing
+		job's values for fields that are empty in Job.
+	*/
+	Update bool `json:"update"`
+
+	DryRun bool `json:"dryRun"`
+
+	// if not nil, fail unless the jobfile's current contents are this
+	ExpectedJobfile *string `json:"expectedJobfile"`
 }
 
 type SetJobCmdResp struct {
-	Ok bool `json:"ok"` // just to make IPC work
+	Ok         bool   `json:"ok"` // just to make IPC work
+	OldJobfile string `json:"oldJobfile"`
+	NewJobfile string `json:"newJobfile"`
 	nonErrorCmdResp
 }
 
 type DeleteJobCmd struct {
-	Job string `json:"job"`
+	Job    string `json:"job"`
+	DryRun bool   `json:"dryRun"`
+
+	// if not nil, fail unless the jobfile's current contents are this
+	ExpectedJobfile *string `json:"expectedJobfile"`
 }
 
 type DeleteJobCmdResp struct {
-	Ok bool `json:"ok"` // just to make IPC work
+	Ok         bool   `json:"ok"` // just to make IPC work
+	OldJobfile string `json:"oldJobfile"`
+	NewJobfile string `json:"newJobfile"`
 	nonErrorCmdResp
 }
@@ -0,0 +1,276 @@
+package ipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+Besides the command socket, each runner has an event socket, on which
+it tells subscribers about things as they happen.  jobbermaster relays
+the events from all the runners on its own event socket (which only
+root can use), setting Event.User.
+
+The protocol is simple: the client connects and sends a
+SubscribeRequest as a line of JSON; the server then sends each event as
+a line of JSON, until one side closes the connection.  A subscriber
+that doesn't keep up is disconnected.
+*/
+
+type EventType string
+
+const (
+	EventRunStarted    EventType = "runStarted"
+	EventRunFinished   EventType = "runFinished"
+	EventStatusChanged EventType = "statusChanged"
+	EventPaused        EventType = "paused"
+	EventResumed       EventType = "resumed"
+	EventReloaded      EventType = "reloaded"
+)
+
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	User string    `json:"user,omitempty"` // set only by jobbermaster
+	Job  string    `json:"job,omitempty"`
+
+	// for runStarted, runFinished, and statusChanged
+	RunId string `json:"runId,omitempty"`
+
+	// for runStarted
+	Pid int `json:"pid,omitempty"`
+
+	// for runStarted and runFinished
+	Manual bool `json:"manual,omitempty"`
+
+	// for runFinished
+	Fate       string        `json:"fate,omitempty"`
+	ExecTime   time.Duration `json:"execTime,omitempty"`
+	SkipReason string        `json:"skipReason,omitempty"`
+	Attempt    int           `json:"attempt,omitempty"`
+	Limit      string        `json:"limit,omitempty"` // the resource limits exceeded
+	WillRetry  bool          `json:"willRetry,omitempty"`
+
+	// for runFinished and statusChanged
+	Status    string `json:"status,omitempty"`
+	OldStatus string `json:"oldStatus,omitempty"`
+
+	// for reloaded
+	NumJobs int `json:"numJobs"`
+}
+
+/*
+Says which events a subscriber wants.  Empty lists mean "all".  Events
+that aren't about a particular job (e.g., reloaded) are always sent.
+*/
+type SubscribeRequest struct {
+	Jobs  []string `json:"jobs"`
+	Users []string `json:"users"`
+}
+
+func (self SubscribeRequest) matches(event Event) bool {
+	contains := func(list []string, s string) bool {
+		for _, elem := range list {
+			if elem == s {
+				return true
+			}
+		}
+		return false
+	}
+	if len(self.Jobs) > 0 && len(event.Job) > 0 &&
+		!contains(self.Jobs, event.Job) {
+		return false
+	}
+	if len(self.Users) > 0 && len(event.User) > 0 &&
+		!contains(self.Users, event.User) {
+		return false
+	}
+	return true
+}
+
+/*
+How many events can be waiting to be sent to a subscriber before we
+give up on it.
+*/
+const gEventBufferLen = 256
+
+/*
+Time limit for reading a subscribe request and for writing an event.
+*/
+const gEventIoTimeout = 5 * time.Second
+
+/*
+An EventHub passes published events to subscribers.  It is safe to use
+from multiple threads.  A nil *EventHub ignores published events.
+*/
+type EventHub struct {
+	lock sync.Mutex
+	subs map[*EventSubscription]bool
+}
+
+type EventSubscription struct {
+	C <-chan Event // closed when the subscription ends
+
+	c   chan Event
+	req SubscribeRequest
+	hub *EventHub
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subs: make(map[*EventSubscription]bool)}
+}
+
+func (self *EventHub) Subscribe(req SubscribeRequest) *EventSubscription {
+	c := make(chan Event, gEventBufferLen)
+	sub := &EventSubscription{C: c, c: c, req: req, hub: self}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.subs[sub] = true
+	return sub
+}
+
+func (self *EventHub) Publish(event Event) {
+	if self == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for sub := range self.subs {
+		if !sub.req.matches(event) {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			// subscriber isn't keeping up
+			common.ErrLogger.Printf("Dropping slow event subscriber")
+			self.removeLocked(sub)
+		}
+	}
+}
+
+func (self *EventHub) removeLocked(sub *EventSubscription) {
+	if self.subs[sub] {
+		delete(self.subs, sub)
+		close(sub.c)
+	}
+}
+
+func (self *EventSubscription) Close() {
+	self.hub.lock.Lock()
+	defer self.hub.lock.Unlock()
+	self.hub.removeLocked(self)
+}
+
+/*
+Serve subscribers that connect to the given listener.  Returns when the
+listener is closed.
+*/
+func ServeEvents(listener net.Listener, hub *EventHub) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go serveEventConn(conn, hub)
+	}
+}
+
+func serveEventConn(conn net.Conn, hub *EventHub) {
+	defer conn.Close()
+
+	// read request
+	var req SubscribeRequest
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(gEventIoTimeout))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	sub := hub.Subscribe(req)
+	defer sub.Close()
+
+	// notice when client disconnects
+	clientGone := make(chan struct{})
+	go func() {
+		defer close(clientGone)
+		buf := make([]byte, 64)
+		for {
+			if _, err := reader.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	// send events
+	encoder := json.NewEncoder(conn)
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(gEventIoTimeout))
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+
+		case <-clientGone:
+			return
+		}
+	}
+}
+
+/*
+A client's connection to an event socket.
+*/
+type EventStream struct {
+	conn    net.Conn
+	decoder *json.Decoder
+}
+
+func DialEvents(socketPath string, req SubscribeRequest) (*EventStream, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &EventStream{conn: conn, decoder: json.NewDecoder(conn)}, nil
+}
+
+/*
+Wait for the next event.  Returns an error when the connection is
+closed.
+*/
+func (self *EventStream) Next() (Event, error) {
+	var event Event
+	err := self.decoder.Decode(&event)
+	return event, err
+}
+
+func (self *EventStream) Close() {
+	self.conn.Close()
+}
@@ -0,0 +1,85 @@
+package ipc
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventHubFilters(t *testing.T) {
+	hub := NewEventHub()
+	sub := hub.Subscribe(SubscribeRequest{Jobs: []string{"A"}})
+	defer sub.Close()
+
+	hub.Publish(Event{Type: EventRunStarted, Job: "B"})
+	hub.Publish(Event{Type: EventRunStarted, Job: "A"})
+	hub.Publish(Event{Type: EventReloaded, NumJobs: 2})
+
+	event := <-sub.C
+	require.Equal(t, EventRunStarted, event.Type)
+	require.Equal(t, "A", event.Job)
+	require.False(t, event.Time.IsZero())
+	event = <-sub.C
+	require.Equal(t, EventReloaded, event.Type)
+	require.Equal(t, 0, len(sub.C))
+}
+
+func TestEventHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewEventHub()
+	sub := hub.Subscribe(SubscribeRequest{})
+	for i := 0; i <= gEventBufferLen; i++ {
+		hub.Publish(Event{Type: EventPaused, Job: "A"})
+	}
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	require.Equal(t, gEventBufferLen, n)
+	sub.Close() // must be harmless
+}
+
+func TestServeEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	sockPath := filepath.Join(dir, "events.sock")
+	listener, err := net.Listen("unix", sockPath)
+	require.Nil(t, err)
+	defer listener.Close()
+
+	hub := NewEventHub()
+	go ServeEvents(listener, hub)
+
+	stream, err := DialEvents(sockPath, SubscribeRequest{Jobs: []string{"A"}})
+	require.Nil(t, err)
+	defer stream.Close()
+
+	// wait for server to subscribe
+	for i := 0; ; i++ {
+		hub.lock.Lock()
+		nbrSubs := len(hub.subs)
+		hub.lock.Unlock()
+		if nbrSubs > 0 {
+			break
+		}
+		require.True(t, i < 100, "Server didn't subscribe")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hub.Publish(Event{Type: EventRunStarted, Job: "B", Pid: 1})
+	hub.Publish(Event{Type: EventRunFinished, Job: "A", Fate: "failed",
+		ExecTime: time.Second})
+
+	event, err := stream.Next()
+	require.Nil(t, err)
+	require.Equal(t, EventRunFinished, event.Type)
+	require.Equal(t, "A", event.Job)
+	require.Equal(t, "failed", event.Fate)
+	require.Equal(t, time.Second, event.ExecTime)
+}
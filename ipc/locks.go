@@ -0,0 +1,363 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os/user"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+Named locks, used to keep jobs from running at the same time.  Each
+runner has a LockTable for its user's jobs, and jobbermaster has one
+for locks shared by all users, which runners use via sockets (one per
+user, accessible only by that user): a client holds a lock for as long
+as its connection stays open and (where we can tell) its process is
+alive.
+*/
+
+/*
+Who holds (or is waiting for) a lock.
+*/
+type LockHolder struct {
+	User  string    `json:"user,omitempty"`
+	Job   string    `json:"job"`
+	RunId string    `json:"runId"`
+	Since time.Time `json:"since"`
+}
+
+/*
+Describes a lock, its holder, and its waiters (in order).
+*/
+type LockDesc struct {
+	Name    string       `json:"name"`
+	Holder  LockHolder   `json:"holder"`
+	Waiters []LockHolder `json:"waiters"`
+}
+
+type LockTable struct {
+	lock  sync.Mutex
+	locks map[string]*lockEntry
+}
+
+type lockEntry struct {
+	holder  LockHolder
+	waiters []*lockWaiter
+}
+
+type lockWaiter struct {
+	holder  LockHolder
+	granted chan struct{} // closed when the waiter gets the lock
+}
+
+func NewLockTable() *LockTable {
+	return &LockTable{locks: make(map[string]*lockEntry)}
+}
+
+/*
+Acquire the named lock for the given holder.  If the lock is held and
+wait is false, returns the current holder.  Otherwise, waits (in FIFO
+order) until the lock is free, or until ctx is done (in which case it
+returns ctx.Err()).  On success, returns a function that releases the
+lock.
+*/
+func (self *LockTable) Acquire(ctx context.Context, name string,
+	holder LockHolder, wait bool) (func(), *LockHolder, error) {
+
+	holder.Since = time.Now()
+
+	self.lock.Lock()
+	entry, ok := self.locks[name]
+	if !ok {
+		self.locks[name] = &lockEntry{holder: holder}
+		self.lock.Unlock()
+		return self.releaser(name), nil, nil
+	}
+	if !wait {
+		current := entry.holder
+		self.lock.Unlock()
+		return nil, &current, nil
+	}
+	waiter := &lockWaiter{holder: holder, granted: make(chan struct{})}
+	entry.waiters = append(entry.waiters, waiter)
+	self.lock.Unlock()
+
+	select {
+	case <-waiter.granted:
+		return self.releaser(name), nil, nil
+
+	case <-ctx.Done():
+		self.lock.Lock()
+		select {
+		case <-waiter.granted:
+			/* we got it just now */
+			self.lock.Unlock()
+			self.release(name)
+		default:
+			entry := self.locks[name]
+			for i, w := range entry.waiters {
+				if w == waiter {
+					entry.waiters = append(entry.waiters[:i],
+						entry.waiters[i+1:]...)
+					break
+				}
+			}
+			self.lock.Unlock()
+		}
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (self *LockTable) releaser(name string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { self.release(name) })
+	}
+}
+
+func (self *LockTable) release(name string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	entry := self.locks[name]
+	if len(entry.waiters) == 0 {
+		delete(self.locks, name)
+		return
+	}
+	next := entry.waiters[0]
+	entry.waiters = entry.waiters[1:]
+	entry.holder = next.holder
+	entry.holder.Since = time.Now()
+	close(next.granted)
+}
+
+/*
+Describe the held locks, ordered by name.
+*/
+func (self *LockTable) Locks() []LockDesc {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	descs := make([]LockDesc, 0, len(self.locks))
+	for name, entry := range self.locks {
+		desc := LockDesc{Name: name, Holder: entry.holder}
+		for _, w := range entry.waiters {
+			desc.Waiters = append(desc.Waiters, w.holder)
+		}
+		descs = append(descs, desc)
+	}
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].Name < descs[j].Name
+	})
+	return descs
+}
+
+/*
+Sent by a client of a lock socket right after connecting.  The server
+ignores Holder.User, taking the holder's user from the socket's peer
+credentials instead.
+*/
+type LockRequest struct {
+	Name   string     `json:"name"`
+	Holder LockHolder `json:"holder"`
+	Wait   bool       `json:"wait"`
+}
+
+/*
+Sent by the server once the lock has been acquired, or (if the request
+didn't say to wait) right away if it's held.
+*/
+type LockResponse struct {
+	Acquired bool        `json:"acquired"`
+	Holder   *LockHolder `json:"holder,omitempty"` // if not acquired
+}
+
+/*
+How often the server checks that the process holding a lock is alive.
+(If it dies, its connection is normally closed, but the connection may
+have been inherited by another process.)
+*/
+var gLockHolderCheckInterval = 10 * time.Second
+
+/*
+The credentials of a lock socket's client.
+*/
+type peerCred struct {
+	uid uint32
+	pid int // 0 if unknown
+}
+
+/*
+Serve lock requests on the given (unix-socket) listener.  Returns when
+the listener is closed.
+*/
+func ServeLocks(listener *net.UnixListener, table *LockTable) {
+	for {
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			return
+		}
+		go serveLockConn(conn, table)
+	}
+}
+
+func serveLockConn(conn *net.UnixConn, table *LockTable) {
+	defer conn.Close()
+
+	// find out who the client is
+	peer, err := getPeerCred(conn)
+	if err != nil {
+		return
+	}
+
+	// read request
+	var req LockRequest
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(gEventIoTimeout))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(line, &req); err != nil || len(req.Name) == 0 {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	req.Holder.User = peerUsername(peer.uid)
+
+	// notice when client disconnects (which releases the lock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		buf := make([]byte, 64)
+		for {
+			if _, err := reader.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	release, current, err := table.Acquire(ctx, req.Name, req.Holder,
+		req.Wait)
+	if err != nil {
+		return
+	}
+	if release != nil {
+		defer release()
+	}
+
+	// send response
+	resp := LockResponse{Acquired: release != nil, Holder: current}
+	conn.SetWriteDeadline(time.Now().Add(gEventIoTimeout))
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		return
+	}
+
+	// hold lock until client disconnects or dies
+	if release != nil {
+		waitForPeer(ctx, peer.pid)
+	}
+}
+
+func peerUsername(uid uint32) string {
+	uidStr := strconv.FormatUint(uint64(uid), 10)
+	usr, err := user.LookupId(uidStr)
+	if err != nil {
+		return uidStr
+	}
+	return usr.Username
+}
+
+/*
+Wait until ctx is done or the process with the given pid (if known) has
+died.
+*/
+func waitForPeer(ctx context.Context, pid int) {
+	if pid <= 0 {
+		<-ctx.Done()
+		return
+	}
+	ticker := time.NewTicker(gLockHolderCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if syscall.Kill(pid, 0) == syscall.ESRCH {
+				return
+			}
+		}
+	}
+}
+
+/*
+A lock held via a lock socket.
+*/
+type SocketLock struct {
+	conn net.Conn
+}
+
+/*
+Acquire a lock via the lock socket at the given path (cf.
+LockTable.Acquire).  If the lock is held and req.Wait is false,
+returns the current holder.
+*/
+func DialLock(ctx context.Context, socketPath string,
+	req LockRequest) (*SocketLock, *LockHolder, error) {
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	// stop waiting when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	var resp LockResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+	if !resp.Acquired {
+		conn.Close()
+		return nil, resp.Holder, nil
+	}
+	if ctx.Err() != nil {
+		/* the goroutine above may have closed the conn */
+		conn.Close()
+		return nil, nil, ctx.Err()
+	}
+	return &SocketLock{conn: conn}, nil, nil
+}
+
+func (self *SocketLock) Release() {
+	self.conn.Close()
+}
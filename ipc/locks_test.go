@@ -0,0 +1,180 @@
+package ipc
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockTableSkip(t *testing.T) {
+	table := NewLockTable()
+	release, current, err := table.Acquire(context.Background(), "db",
+		LockHolder{Job: "A", RunId: "1"}, false)
+	require.Nil(t, err)
+	require.Nil(t, current)
+	require.NotNil(t, release)
+
+	release2, current, err := table.Acquire(context.Background(), "db",
+		LockHolder{Job: "B", RunId: "2"}, false)
+	require.Nil(t, err)
+	require.Nil(t, release2)
+	require.NotNil(t, current)
+	require.Equal(t, "A", current.Job)
+
+	/* other locks are independent */
+	release3, current, err := table.Acquire(context.Background(), "other",
+		LockHolder{Job: "B", RunId: "2"}, false)
+	require.Nil(t, err)
+	require.Nil(t, current)
+	release3()
+
+	release()
+	release() // must be harmless
+	require.Equal(t, 0, len(table.Locks()))
+}
+
+func TestLockTableWaitsInOrder(t *testing.T) {
+	table := NewLockTable()
+	release, _, err := table.Acquire(context.Background(), "db",
+		LockHolder{Job: "A"}, true)
+	require.Nil(t, err)
+
+	acquired := make(chan string, 2)
+	for _, job := range []string{"B", "C"} {
+		go func(job string) {
+			release, _, err := table.Acquire(context.Background(), "db",
+				LockHolder{Job: job}, true)
+			require.Nil(t, err)
+			acquired <- job
+			time.Sleep(10 * time.Millisecond)
+			release()
+		}(job)
+		/* make sure B is queued before C */
+		require.Eventually(t, func() bool {
+			locks := table.Locks()
+			return len(locks) == 1 &&
+				locks[0].Waiters[len(locks[0].Waiters)-1].Job == job
+		}, time.Second, time.Millisecond)
+	}
+
+	locks := table.Locks()
+	require.Equal(t, "A", locks[0].Holder.Job)
+	require.Equal(t, 2, len(locks[0].Waiters))
+
+	release()
+	require.Equal(t, "B", <-acquired)
+	require.Equal(t, "C", <-acquired)
+	require.Eventually(t, func() bool { return len(table.Locks()) == 0 },
+		time.Second, time.Millisecond)
+}
+
+func TestLockTableWaitCancelled(t *testing.T) {
+	table := NewLockTable()
+	release, _, err := table.Acquire(context.Background(), "db",
+		LockHolder{Job: "A"}, true)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	release2, _, err := table.Acquire(ctx, "db", LockHolder{Job: "B"}, true)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Nil(t, release2)
+	require.Equal(t, 0, len(table.Locks()[0].Waiters))
+
+	release()
+	require.Equal(t, 0, len(table.Locks()))
+}
+
+func TestServeLocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	sockPath := filepath.Join(dir, "locks.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: sockPath})
+	require.Nil(t, err)
+	defer listener.Close()
+	me, err := user.Current()
+	require.Nil(t, err)
+
+	table := NewLockTable()
+	go ServeLocks(listener, table)
+
+	// acquire
+	held, current, err := DialLock(context.Background(), sockPath,
+		LockRequest{Name: "db", Holder: LockHolder{User: "alice", Job: "A"}})
+	require.Nil(t, err)
+	require.Nil(t, current)
+	require.NotNil(t, held)
+
+	// try without waiting
+	held2, current, err := DialLock(context.Background(), sockPath,
+		LockRequest{Name: "db", Holder: LockHolder{User: "bob", Job: "B"}})
+	require.Nil(t, err)
+	require.Nil(t, held2)
+	require.Equal(t, "A", current.Job)
+
+	/* the holder's user comes from the socket, not the request */
+	require.Equal(t, me.Username, current.User)
+
+	// wait, and give up
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+	held2, _, err = DialLock(ctx, sockPath, LockRequest{Name: "db",
+		Holder: LockHolder{User: "bob", Job: "B"}, Wait: true})
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Nil(t, held2)
+
+	// wait, and get it when the first client disconnects
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		held.Release()
+	}()
+	held2, _, err = DialLock(context.Background(), sockPath,
+		LockRequest{Name: "db", Holder: LockHolder{User: "bob", Job: "B"},
+			Wait: true})
+	require.Nil(t, err)
+	require.NotNil(t, held2)
+	require.Equal(t, "B", table.Locks()[0].Holder.Job)
+	require.Equal(t, me.Username, table.Locks()[0].Holder.User)
+
+	held2.Release()
+	require.Eventually(t, func() bool { return len(table.Locks()) == 0 },
+		time.Second, time.Millisecond)
+}
+
+func TestWaitForPeerNoticesDeath(t *testing.T) {
+	oldInterval := gLockHolderCheckInterval
+	gLockHolderCheckInterval = time.Millisecond
+	defer func() { gLockHolderCheckInterval = oldInterval }()
+
+	cmd := exec.Command("true")
+	require.Nil(t, cmd.Run())
+
+	done := make(chan struct{})
+	go func() {
+		waitForPeer(context.Background(), cmd.Process.Pid)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("waitForPeer didn't notice that the process died")
+	}
+
+	/* a live process keeps the lock */
+	ctx, cancel := context.WithTimeout(context.Background(),
+		20*time.Millisecond)
+	defer cancel()
+	waitForPeer(ctx, os.Getpid())
+	require.NotNil(t, ctx.Err())
+}
@@ -0,0 +1,46 @@
+// +build darwin freebsd
+
+package ipc
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	solLocal      = 0 // SOL_LOCAL
+	localPeerCred = 1 // LOCAL_PEERCRED
+)
+
+/* struct xucred, up to the fields we need */
+type xucred struct {
+	version uint32
+	uid     uint32
+	ngroups int16
+	groups  [16]uint32
+}
+
+func getPeerCred(conn *net.UnixConn) (peerCred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return peerCred{}, err
+	}
+	var cred xucred
+	var errno syscall.Errno
+	err = raw.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(cred))
+		_, _, errno = syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd,
+			solLocal, localPeerCred, uintptr(unsafe.Pointer(&cred)),
+			uintptr(unsafe.Pointer(&size)), 0)
+	})
+	if err != nil {
+		return peerCred{}, err
+	}
+	if errno != 0 {
+		return peerCred{}, errno
+	}
+
+	/* the pid isn't (portably) available, so we can't watch the peer */
+	return peerCred{uid: cred.uid}, nil
+}
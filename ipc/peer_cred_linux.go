@@ -0,0 +1,26 @@
+package ipc
+
+import (
+	"net"
+	"syscall"
+)
+
+func getPeerCred(conn *net.UnixConn) (peerCred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return peerCred{}, err
+	}
+	var ucred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd),
+			syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return peerCred{}, err
+	}
+	if credErr != nil {
+		return peerCred{}, credErr
+	}
+	return peerCred{uid: ucred.Uid, pid: int(ucred.Pid)}, nil
+}
@@ -1,5 +1,11 @@
 IPC_SOURCES := \
 	ipc/commands.go \
+	ipc/events.go \
+	ipc/locks.go \
+	ipc/peer_cred_bsd.go \
+	ipc/peer_cred_linux.go \
 	ipc/sources.mk
 
-IPC_TEST_SOURCES :=
+IPC_TEST_SOURCES := \
+	ipc/events_test.go \
+	ipc/locks_test.go
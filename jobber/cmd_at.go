@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/ipc"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+var gClockTimeRegexp = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+
+/*
+Parse the time given to 'at': an absolute time (cf. parseTimeArg), a
+time of day like "02:00" (meaning the next such time), or "+DURATION"
+or "now+DURATION".
+*/
+func parseAtArg(s string, now time.Time) (time.Time, error) {
+	if strings.HasPrefix(s, "now+") || strings.HasPrefix(s, "+") {
+		d, err := time.ParseDuration(s[strings.Index(s, "+")+1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid duration: \"%v\"", s)
+		}
+		return now.Add(d).Truncate(time.Second), nil
+	}
+	if m := gClockTimeRegexp.FindStringSubmatch(s); m != nil {
+		t, err := time.ParseInLocation("15:04", s, time.Local)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid time: \"%v\"", s)
+		}
+		y, mon, d := now.Date()
+		at := time.Date(y, mon, d, t.Hour(), t.Minute(), 0, 0, time.Local)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	return parseTimeArg(s)
+}
+
+func doAtCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(AtCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(AtCmdStr, "TIME [-- CMD...]", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	var yes_p = flagSet.Bool("yes", false, "apply the change without asking")
+	var name_p = flagSet.String("name", "",
+		"the job's name (default: \"at-\" followed by the time)")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		fmt.Printf("\nTIME is a time like \"2026-11-01 02:00\", a time of " +
+			"day like \"02:00\", or a\nduration from now like " +
+			"\"+2h\".  If CMD is not given, it is read from stdin.\n" +
+			"\nThe job runs once and is then removed from the jobfile.\n")
+		return 0
+	}
+
+	// get time
+	posArgs := flagSet.Args()
+	if len(posArgs) == 0 {
+		fmt.Fprintf(os.Stderr, "You must specify a time.\n")
+		return 1
+	}
+	now := time.Now()
+	at, err := parseAtArg(posArgs[0], now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if at.Before(now) {
+		fmt.Fprintf(os.Stderr, "That time has passed.\n")
+		return 1
+	}
+	if len(*name_p) > jobfile.MaxOneShotJobNameLen {
+		fmt.Fprintf(os.Stderr, "The name may be at most %v bytes long.\n",
+			jobfile.MaxOneShotJobNameLen)
+		return 1
+	}
+
+	// get command
+	posArgs = posArgs[1:]
+	if len(posArgs) > 0 && posArgs[0] == "--" {
+		posArgs = posArgs[1:]
+	}
+	var jobCmd string
+	if len(posArgs) > 0 {
+		jobCmd = strings.Join(posArgs, " ")
+	} else {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read command: %v\n", err)
+			return 1
+		}
+		jobCmd = strings.TrimSpace(string(data))
+	}
+	if len(jobCmd) == 0 {
+		fmt.Fprintf(os.Stderr, "You must specify the job's command.\n")
+		return 1
+	}
+
+	// make job
+	atStr := at.Format(jobfile.AtTimeFmt)
+	cmd := ipc.SetJobCmd{MustBeNew: true}
+	cmd.Job.Name = *name_p
+	if len(cmd.Job.Name) == 0 {
+		cmd.Job.Name = "at-" + at.Format("20060102-150405")
+	}
+	cmd.Job.Cmd = jobCmd
+	cmd.Job.At = &atStr
+
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get current user: %v\n", err)
+		return 1
+	}
+
+	edit := func(dryRun bool, expected *string) (string, string, error) {
+		cmd.DryRun = dryRun
+		cmd.ExpectedJobfile = expected
+		var resp ipc.SetJobCmdResp
+		err := CallDaemon("IpcService.SetJob", cmd, &resp, usr, timeout_p)
+		return resp.OldJobfile, resp.NewJobfile, err
+	}
+	return editJobfile(edit, *yes_p)
+}
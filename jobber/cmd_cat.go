@@ -17,6 +17,7 @@ func doCatCmd(args []string) int {
 	var help_p *bool = flagSet.Bool("h", false, "help")
 	//	var jobUser_p *string = flagSet.String("u", user.Username, "user")
 	var timeout_p = flagSet.Duration("t", 5 * time.Second, "timeout")
+	var output_p = addOutputFlag(flagSet)
 	flagSet.Parse(args)
 
 	if *help_p {
@@ -24,6 +25,12 @@ func doCatCmd(args []string) int {
 		return 0
 	}
 
+	format, err := parseOutputFormat(*output_p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
 	// get job to cat
 	if len(flagSet.Args()) == 0 {
 		fmt.Fprintf(os.Stderr, "You must specify a job.\n")
@@ -55,6 +62,15 @@ func doCatCmd(args []string) int {
 	}
 
 	// handle response
+	if format != OutputTable {
+		rec := CmdOutputRec{User: usr.Username, Job: job, Cmd: resp.Result}
+		err := writeOutputRecs(os.Stdout, format, []CmdOutputRec{rec})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		return 0
+	}
 	fmt.Printf("%v\n", resp.Result)
 	return 0
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/dshearer/jobber/jobfile"
+)
+
+func printWarnings(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", warning)
+	}
+}
+
+func doImportCrontabCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(ImportCrontabCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(ImportCrontabCmdStr, "[CRONTAB]", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	// read crontab
+	var data []byte
+	var err error
+	if len(flagSet.Args()) == 0 || flagSet.Args()[0] == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(flagSet.Args()[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// convert it
+	result, err := jobfile.ImportCrontab(string(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	printWarnings(result.Warnings)
+
+	// print jobfile
+	var text string
+	for _, name := range result.Names {
+		text, err = jobfile.SetJobInText(text, name, result.Jobs[name])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+	if len(text) == 0 {
+		fmt.Fprintf(os.Stderr, "No jobs in crontab.\n")
+		return 1
+	}
+	fmt.Print(text)
+	return 0
+}
+
+func doExportCrontabCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(ExportCrontabCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(ExportCrontabCmdStr, "[JOBFILE]", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	// get jobfile
+	var path string
+	if len(flagSet.Args()) > 0 {
+		path = flagSet.Args()[0]
+	} else {
+		usr, err := user.Current()
+		if err != nil {
+			fmt.Fprintf(
+				os.Stderr, "Failed to get current user: %v\n", err,
+			)
+			return 1
+		}
+		path = filepath.Join(usr.HomeDir, ".jobber")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer f.Close()
+	raw, err := jobfile.LoadJobfile(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", path, err)
+		return 1
+	}
+
+	// convert it
+	crontab, warnings := jobfile.ExportCrontab(raw.Jobs)
+	printWarnings(warnings)
+	fmt.Print(crontab)
+	return 0
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/ipc"
+	"github.com/dshearer/jobber/jobfile"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+Sends a jobfile-editing command to the daemon and returns the old and
+new contents of the jobfile.
+*/
+type jobfileEditor func(dryRun bool, expected *string) (string, string, error)
+
+func doAddCmd(args []string) int {
+	return doSetJobCmdWithMode(AddCmdStr, args)
+}
+
+func doSetCmd(args []string) int {
+	return doSetJobCmdWithMode(SetCmdStr, args)
+}
+
+/*
+Implements 'add' and 'set'.  Both take the job either from flags or
+(as YAML) from stdin.  'add' fails if there already is a job with the
+given name.  'set' with flags changes just the given fields of an
+existing job; 'set' with stdin replaces the job (or adds it).
+*/
+func doSetJobCmdWithMode(subcmd string, args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(subcmd, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(subcmd, "JOB", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	var yes_p = flagSet.Bool("yes", false, "apply the change without asking")
+	var cmd_p = flagSet.String("cmd", "", "the job's command")
+	var time_p = flagSet.String("time", "", "the job's time spec")
+	var onError_p = flagSet.String("onError", "",
+		"what to do when the job fails (e.g., Stop, Backoff, Continue, "+
+			"or stop-after(n=3))")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		fmt.Printf("\nIf none of -cmd, -time, and -onError is given, the " +
+			"job is read from stdin as YAML.\n")
+		return 0
+	}
+
+	// get job name
+	if len(flagSet.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "You must specify a job.\n")
+		return 1
+	}
+	jobName := flagSet.Args()[0]
+
+	// get job
+	cmd := ipc.SetJobCmd{MustBeNew: subcmd == AddCmdStr}
+	cmd.Job.Name = jobName
+	usedFlags := false
+	flagSet.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cmd", "time", "onError":
+			usedFlags = true
+		}
+	})
+	if usedFlags {
+		cmd.Job.Cmd = *cmd_p
+		cmd.Job.Time = *time_p
+		if len(*onError_p) > 0 {
+			cmd.Job.OnError = onError_p
+		}
+		cmd.Update = subcmd == SetCmdStr
+		if subcmd == AddCmdStr && len(cmd.Job.Cmd) == 0 {
+			fmt.Fprintf(os.Stderr, "You must specify the job's command.\n")
+			return 1
+		}
+	} else {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read job: %v\n", err)
+			return 1
+		}
+		if err := yaml.UnmarshalStrict(data, &cmd.Job.JobV3Raw); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid job: %v\n", err)
+			return 1
+		}
+		cmd.Job.JobV3Raw = normalizeRawJob(cmd.Job.JobV3Raw)
+	}
+
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get current user: %v\n", err)
+		return 1
+	}
+
+	edit := func(dryRun bool, expected *string) (string, string, error) {
+		cmd.DryRun = dryRun
+		cmd.ExpectedJobfile = expected
+		var resp ipc.SetJobCmdResp
+		err := CallDaemon("IpcService.SetJob", cmd, &resp, usr, timeout_p)
+		return resp.OldJobfile, resp.NewJobfile, err
+	}
+	return editJobfile(edit, *yes_p)
+}
+
+func doRmCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(RmCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(RmCmdStr, "JOB", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	var yes_p = flagSet.Bool("yes", false, "apply the change without asking")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	// get job name
+	if len(flagSet.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "You must specify a job.\n")
+		return 1
+	}
+	jobName := flagSet.Args()[0]
+
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get current user: %v\n", err)
+		return 1
+	}
+
+	edit := func(dryRun bool, expected *string) (string, string, error) {
+		cmd := ipc.DeleteJobCmd{
+			Job:             jobName,
+			DryRun:          dryRun,
+			ExpectedJobfile: expected,
+		}
+		var resp ipc.DeleteJobCmdResp
+		err := CallDaemon("IpcService.DeleteJob", cmd, &resp, usr, timeout_p)
+		return resp.OldJobfile, resp.NewJobfile, err
+	}
+	return editJobfile(edit, *yes_p)
+}
+
+/*
+Show the user how the jobfile would change, ask whether to go ahead
+(unless yes is true), and then make the change.  The change is made
+only if the jobfile hasn't changed in the meantime.
+*/
+func editJobfile(edit jobfileEditor, yes bool) int {
+	// preview change
+	oldText, newText, err := edit(true, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	diff := unifiedDiff("jobfile", "jobfile (new)", oldText, newText)
+	if len(diff) == 0 {
+		fmt.Printf("No change.\n")
+		return 0
+	}
+	fmt.Print(diff)
+
+	// confirm
+	if !yes {
+		ok, err := confirm("Apply this change?")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v (use -yes to apply without "+
+				"asking)\n", err)
+			return 1
+		}
+		if !ok {
+			fmt.Printf("Not applied.\n")
+			return 1
+		}
+	}
+
+	// apply change
+	if _, _, err := edit(false, &oldText); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	fmt.Printf("Applied.\n")
+	return 0
+}
+
+/*
+Ask the user a yes/no question on the terminal.  (We don't use stdin,
+because it may have been used for the job.)
+*/
+func confirm(question string) (bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, fmt.Errorf("Cannot ask for confirmation: %v", err)
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%v [y/N] ", question)
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("Cannot ask for confirmation: %v", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+/*
+The YAML library gives us maps with interface{} keys, which can't be
+sent as JSON, so convert the result sinks' maps to ones with string
+keys.
+*/
+func normalizeRawJob(job jobfile.JobRaw) jobfile.JobRaw {
+	fixSinks := func(sinks []jobfile.ResultSinkRaw) []jobfile.ResultSinkRaw {
+		for _, sink := range sinks {
+			for key, value := range sink {
+				sink[key] = normalizeYamlValue(value)
+			}
+		}
+		return sinks
+	}
+	job.NotifyOnSuccess = fixSinks(job.NotifyOnSuccess)
+	job.NotifyOnError = fixSinks(job.NotifyOnError)
+	job.NotifyOnFailure = fixSinks(job.NotifyOnFailure)
+	job.NotifyOnWarning = fixSinks(job.NotifyOnWarning)
+	return job
+}
+
+func normalizeYamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for key, elem := range v {
+			m[fmt.Sprintf("%v", key)] = normalizeYamlValue(elem)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeYamlValue(v[i])
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/dshearer/jobber/ipc"
+)
+
+func doKillCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(KillCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(KillCmdStr, "JOB", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	// get job to kill
+	if len(flagSet.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "You must specify a job.\n")
+		return 1
+	}
+	var job string = flagSet.Args()[0]
+
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(
+			os.Stderr, "Failed to get current user: %v\n", err,
+		)
+		return 1
+	}
+
+	// send command
+	var resp ipc.KillCmdResp
+	err = CallDaemon(
+		"IpcService.Kill",
+		ipc.KillCmd{Job: job},
+		&resp,
+		usr,
+		timeout_p,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// handle response
+	fmt.Printf("Killed %v runs.\n", resp.NumKilled)
+	return 0
+}
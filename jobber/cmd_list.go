@@ -41,7 +41,9 @@ func formatResponseRecs(recs []ListRespRec, showUser bool) string {
 		"NOTIFY ON SUCCESS",
 		"NOTIFY ON ERR",
 		"NOTIFY ON FAIL",
+		"NOTIFY ON WARN",
 		"ERR HANDLER",
+		"LOCK",
 	}
 	if showUser {
 		headers = append(headers, "USER")
@@ -61,7 +63,9 @@ func formatResponseRecs(recs []ListRespRec, showUser bool) string {
 				fmt.Sprintf("%v", j.NotifyOnSuccess),
 				fmt.Sprintf("%v", j.NotifyOnErr),
 				fmt.Sprintf("%v", j.NotifyOnFail),
+				fmt.Sprintf("%v", j.NotifyOnWarn),
 				j.ErrHandler,
+				formatLock(j.Lock),
 			}
 			if showUser {
 				fields = append(fields, respRec.usr.Username)
@@ -76,7 +80,43 @@ func formatResponseRecs(recs []ListRespRec, showUser bool) string {
 	return buffer.String()
 }
 
-func doListCmd_allUsers(timeout_p *time.Duration) int {
+func listOutputRecs(recs []ListRespRec) []JobOutputRec {
+	outRecs := make([]JobOutputRec, 0)
+	for _, respRec := range recs {
+		for _, j := range respRec.resp.Jobs {
+			outRecs = append(outRecs, JobOutputRec{
+				User:            respRec.usr.Username,
+				Name:            j.Name,
+				Status:          j.Status,
+				Schedule:        j.Schedule,
+				NextRunTime:     j.NextRunTime,
+				NotifyOnSuccess: j.NotifyOnSuccess,
+				NotifyOnError:   j.NotifyOnErr,
+				NotifyOnFailure: j.NotifyOnFail,
+				ErrHandler:      j.ErrHandler,
+				NotifyOnWarning: j.NotifyOnWarn,
+				Lock:            j.Lock,
+			})
+		}
+	}
+	return outRecs
+}
+
+func displayResponseRecs(recs []ListRespRec, showUser bool,
+	format OutputFormat) int {
+
+	if format == OutputTable {
+		fmt.Println(formatResponseRecs(recs, showUser))
+		return 0
+	}
+	if err := writeOutputRecs(os.Stdout, format, listOutputRecs(recs)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func doListCmd_allUsers(timeout_p *time.Duration, format OutputFormat) int {
 	// get all users
 	users, err := common.AllUsersWithSockets()
 	if err != nil {
@@ -107,12 +147,10 @@ func doListCmd_allUsers(timeout_p *time.Duration) int {
 	}
 
 	// display response records
-	fmt.Println(formatResponseRecs(responses, true))
-
-	return 0
+	return displayResponseRecs(responses, true, format)
 }
 
-func doListCmd_currUser(timeout_p *time.Duration) int {
+func doListCmd_currUser(timeout_p *time.Duration, format OutputFormat) int {
 	// get current user
 	usr, err := user.Current()
 	if err != nil {
@@ -138,9 +176,7 @@ func doListCmd_currUser(timeout_p *time.Duration) int {
 
 	// display response records
 	rec := ListRespRec{usr: usr, resp: &resp}
-	fmt.Println(formatResponseRecs([]ListRespRec{rec}, false))
-
-	return 0
+	return displayResponseRecs([]ListRespRec{rec}, false, format)
 }
 
 func doListCmd(args []string) int {
@@ -150,6 +186,7 @@ func doListCmd(args []string) int {
 	var help_p = flagSet.Bool("h", false, "help")
 	var allUsers_p = flagSet.Bool("a", false, "all-users")
 	var timeout_p = flagSet.Duration("t", 5 * time.Second, "timeout")
+	var output_p = addOutputFlag(flagSet)
 	flagSet.Parse(args)
 
 	if *help_p {
@@ -157,9 +194,15 @@ func doListCmd(args []string) int {
 		return 0
 	}
 
+	format, err := parseOutputFormat(*output_p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
 	if *allUsers_p {
-		return doListCmd_allUsers(timeout_p)
+		return doListCmd_allUsers(timeout_p, format)
 	} else {
-		return doListCmd_currUser(timeout_p)
+		return doListCmd_currUser(timeout_p, format)
 	}
 }
@@ -18,6 +18,7 @@ import (
 type EnhancedLogDesc struct {
 	userName string
 	logDesc  ipc.LogDesc
+	usr      *user.User
 }
 
 /* For sorting LogDescs: */
@@ -56,7 +57,89 @@ func (self LogDescSorter) Less(i, j int) bool {
 	return self[i].Time.After(self[j].Time)
 }
 
-func doLogCmd_allUsers(timeout_p *time.Duration) int {
+func fateString(logDesc ipc.LogDesc) string {
+	fate := logDesc.Fate
+	if len(logDesc.LimitExceeded) > 0 {
+		fate += " (exceeded " + logDesc.LimitExceeded + ")"
+	}
+	if logDesc.Attempt > 0 {
+		fate += fmt.Sprintf(" (attempt %v)", logDesc.Attempt)
+	}
+	if logDesc.Manual {
+		return fate + " (manual)"
+	}
+	if len(logDesc.SkipReason) > 0 {
+		return fate + " (" + logDesc.SkipReason + ")"
+	}
+	return fate
+}
+
+/*
+Format how long a run waited for a slot (cf. maxConcurrentJobs).
+*/
+func formatQueueDelay(delay time.Duration) string {
+	if delay == 0 {
+		return "-"
+	} else if delay < time.Second {
+		return delay.Round(time.Millisecond).String()
+	}
+	return delay.Round(time.Second).String()
+}
+
+func logOutputRecs(logDescs []EnhancedLogDesc) []RunOutputRec {
+	outRecs := make([]RunOutputRec, 0)
+	for _, e := range logDescs {
+		outRecs = append(outRecs, RunOutputRec{
+			User:          e.usr.Username,
+			Time:          e.logDesc.Time,
+			Job:           e.logDesc.Job,
+			Fate:          e.logDesc.Fate,
+			Manual:        e.logDesc.Manual,
+			ExecTimeSec:   e.logDesc.ExecTime.Seconds(),
+			JobStatus:     e.logDesc.Result,
+			RunId:         e.logDesc.RunId,
+			SkipReason:    e.logDesc.SkipReason,
+			Attempt:       e.logDesc.Attempt,
+			QueueDelaySec: e.logDesc.QueueDelay.Seconds(),
+			LimitExceeded: e.logDesc.LimitExceeded,
+		})
+	}
+	return outRecs
+}
+
+func writeLogOutputRecs(logDescs []EnhancedLogDesc, format OutputFormat) int {
+	sort.Sort(EnhancedLogDescSorter(logDescs))
+	if err := writeOutputRecs(os.Stdout, format, logOutputRecs(logDescs)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+/*
+Parse a time given to -since or -until: either an absolute time or a
+duration (meaning that long before now).
+*/
+func parseLogTimeArg(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return parseTimeArg(s)
+}
+
+func splitListArg(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func doLogCmd_allUsers(cmd ipc.LogCmd, timeout_p *time.Duration,
+	format OutputFormat) int {
+
 	// get all users
 	users, err := common.AllUsersWithSockets()
 	if err != nil {
@@ -72,7 +155,7 @@ func doLogCmd_allUsers(timeout_p *time.Duration) int {
 		var resp ipc.LogCmdResp
 		err = CallDaemon(
 			"IpcService.Log",
-			ipc.LogCmd{},
+			cmd,
 			&resp,
 			usr,
 			timeout_p,
@@ -82,12 +165,20 @@ func doLogCmd_allUsers(timeout_p *time.Duration) int {
 				"Failed to get log for %v: %v.\n", usr.Name, err)
 		}
 		for _, log := range resp.Logs {
-			logDescs = append(logDescs, EnhancedLogDesc{usr.Name, log})
+			logDescs = append(logDescs, EnhancedLogDesc{usr.Name, log, usr})
 		}
 	}
 
+	// apply limit to combined entries
+	sort.Sort(EnhancedLogDescSorter(logDescs))
+	if cmd.Limit > 0 && len(logDescs) > cmd.Limit {
+		logDescs = logDescs[:cmd.Limit]
+	}
+
 	// handle response
-	if len(logDescs) == 0 {
+	if format != OutputTable {
+		return writeLogOutputRecs(logDescs, format)
+	} else if len(logDescs) == 0 {
 		fmt.Println("No run logs.")
 
 	} else {
@@ -95,15 +186,16 @@ func doLogCmd_allUsers(timeout_p *time.Duration) int {
 		var buffer bytes.Buffer
 		var writer *tabwriter.Writer = tabwriter.NewWriter(&buffer, 5,
 			0, 2, ' ', 0)
-		fmt.Fprintf(writer, "TIME\tJOB\tRESULT\tEXECTIME\tRESULT\tUSER\n")
+		fmt.Fprintf(writer, "TIME\tJOB\tRESULT\tEXECTIME\tQUEUED\tRESULT\tUSER\n")
 		var strs []string
 		for _, e := range logDescs {
 			s := fmt.Sprintf(
-				"%v\t%v\t%v\t%v\t%v\t%v\t",
+				"%v\t%v\t%v\t%v\t%v\t%v\t%v\t",
 				e.logDesc.Time.Format("Jan _2 15:04:05 2006"),
 				e.logDesc.Job,
-				e.logDesc.Fate,
+				fateString(e.logDesc),
 				e.logDesc.ExecTime.Round(time.Second),
+				formatQueueDelay(e.logDesc.QueueDelay),
 				e.logDesc.Result,
 				e.userName)
 			strs = append(strs, s)
@@ -116,7 +208,9 @@ func doLogCmd_allUsers(timeout_p *time.Duration) int {
 	return 0
 }
 
-func doLogCmd_currUser(timeout_p *time.Duration) int {
+func doLogCmd_currUser(cmd ipc.LogCmd, timeout_p *time.Duration,
+	format OutputFormat) int {
+
 	// get current user
 	usr, err := user.Current()
 	if err != nil {
@@ -130,7 +224,7 @@ func doLogCmd_currUser(timeout_p *time.Duration) int {
 	var resp ipc.LogCmdResp
 	err = CallDaemon(
 		"IpcService.Log",
-		ipc.LogCmd{},
+		cmd,
 		&resp,
 		usr,
 		timeout_p,
@@ -140,8 +234,19 @@ func doLogCmd_currUser(timeout_p *time.Duration) int {
 		return 1
 	}
 
+	if len(resp.NextCursor) > 0 {
+		defer fmt.Fprintf(os.Stderr, "There may be more entries.  To see "+
+			"them, use \"-cursor %v\".\n", resp.NextCursor)
+	}
+
 	// handle response
-	if len(resp.Logs) == 0 {
+	if format != OutputTable {
+		var logDescs []EnhancedLogDesc
+		for _, log := range resp.Logs {
+			logDescs = append(logDescs, EnhancedLogDesc{usr.Name, log, usr})
+		}
+		return writeLogOutputRecs(logDescs, format)
+	} else if len(resp.Logs) == 0 {
 		fmt.Println("No run logs.")
 
 	} else {
@@ -149,15 +254,16 @@ func doLogCmd_currUser(timeout_p *time.Duration) int {
 		var buffer bytes.Buffer
 		var writer *tabwriter.Writer = tabwriter.NewWriter(&buffer, 5, 0,
 			2, ' ', 0)
-		fmt.Fprintf(writer, "TIME\tJOB\tRESULT\tEXECTIME\tNEW JOB STATUS\t\n")
+		fmt.Fprintf(writer, "TIME\tJOB\tRESULT\tEXECTIME\tQUEUED\tNEW JOB STATUS\t\n")
 		strs := make([]string, 0)
 		for _, e := range resp.Logs {
 			s := fmt.Sprintf(
-				"%v\t%v\t%v\t%v\t%v\t",
+				"%v\t%v\t%v\t%v\t%v\t%v\t",
 				e.Time.Format("Jan _2 15:04:05 2006"),
 				e.Job,
-				e.Fate,
+				fateString(e),
 				e.ExecTime.Round(time.Second),
+				formatQueueDelay(e.QueueDelay),
 				e.Result)
 			strs = append(strs, s)
 		}
@@ -176,6 +282,19 @@ func doLogCmd(args []string) int {
 	var help_p = flagSet.Bool("h", false, "help")
 	var allUsers_p = flagSet.Bool("a", false, "all-users")
 	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	var output_p = addOutputFlag(flagSet)
+	var jobs_p = flagSet.String("job", "",
+		"show only runs of these jobs (comma-separated)")
+	var fates_p = flagSet.String("fate", "",
+		"show only runs with these fates (comma-separated; e.g., \"failed\")")
+	var since_p = flagSet.String("since", "",
+		"show only runs that started at or after this time (e.g., "+
+			"\"2006-01-02 15:04\" or \"24h\" for 24 hours ago)")
+	var until_p = flagSet.String("until", "",
+		"show only runs that started at or before this time")
+	var limit_p = flagSet.Int("n", 0, "show at most this many runs")
+	var cursor_p = flagSet.String("cursor", "",
+		"continue from where a previous (limited) listing stopped")
 	flagSet.Parse(args)
 
 	if *help_p {
@@ -183,9 +302,48 @@ func doLogCmd(args []string) int {
 		return 0
 	}
 
+	format, err := parseOutputFormat(*output_p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// make command
+	cmd := ipc.LogCmd{
+		Jobs:   splitListArg(*jobs_p),
+		Fates:  splitListArg(*fates_p),
+		Limit:  *limit_p,
+		Cursor: *cursor_p,
+	}
+	if cmd.Limit < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid limit: %v\n", cmd.Limit)
+		return 1
+	}
+	now := time.Now()
+	if len(*since_p) > 0 {
+		t, err := parseLogTimeArg(*since_p, now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		cmd.Since = &t
+	}
+	if len(*until_p) > 0 {
+		t, err := parseLogTimeArg(*until_p, now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		cmd.Until = &t
+	}
+
 	if *allUsers_p {
-		return doLogCmd_allUsers(timeout_p)
+		if len(cmd.Cursor) > 0 {
+			fmt.Fprintf(os.Stderr, "-cursor cannot be used with -a.\n")
+			return 1
+		}
+		return doLogCmd_allUsers(cmd, timeout_p, format)
 	} else {
-		return doLogCmd_currUser(timeout_p)
+		return doLogCmd_currUser(cmd, timeout_p, format)
 	}
 }
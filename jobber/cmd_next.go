@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/dshearer/jobber/ipc"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+const gNextTimeFmt = "Mon Jan _2 15:04:05 2006"
+
+func doNextCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(NextCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(NextCmdStr, "JOB", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var timeSpec_p = flagSet.String("time", "",
+		"a time spec to use instead of a job's")
+	var count_p = flagSet.Int("n", 5, "number of times to show")
+	var from_p = flagSet.String("from", "",
+		"show times at or after this time (default: now)")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	if *count_p < 1 {
+		fmt.Fprintf(os.Stderr, "-n must be positive.\n")
+		return 1
+	}
+	from := time.Now()
+	if len(*from_p) > 0 {
+		var err error
+		from, err = parseTimeArg(*from_p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	if len(*timeSpec_p) > 0 {
+		if len(flagSet.Args()) > 0 {
+			fmt.Fprintf(os.Stderr,
+				"You cannot specify both a job and -time.\n")
+			return 1
+		}
+		return doNextCmd_spec(*timeSpec_p, *count_p, from)
+	}
+
+	// get job
+	if len(flagSet.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "You must specify a job or -time.\n")
+		return 1
+	}
+	var job string = flagSet.Args()[0]
+
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(
+			os.Stderr, "Failed to get current user: %v\n", err,
+		)
+		return 1
+	}
+
+	// send command
+	var resp ipc.NextRunsCmdResp
+	err = CallDaemon(
+		"IpcService.NextRuns",
+		ipc.NextRunsCmd{Job: job, Count: *count_p, From: &from},
+		&resp,
+		usr,
+		timeout_p,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// handle response
+	fmt.Printf("Schedule: %v\n", resp.Schedule)
+	printNextTimes(resp.Times)
+	return 0
+}
+
+/*
+Show the next run times for a time spec without talking to the daemon.
+*/
+func doNextCmd_spec(timeSpecStr string, count int, from time.Time) int {
+	timeSpec, err := jobfile.ParseFullTimeSpec(timeSpecStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	origSpecStr := timeSpec.String()
+	timeSpec.Derandomize()
+	derandSpecStr := timeSpec.String()
+
+	if derandSpecStr != origSpecStr {
+		fmt.Printf("Schedule: %v (random values chosen for this run; "+
+			"a job's will differ)\n", derandSpecStr)
+	} else {
+		fmt.Printf("Schedule: %v\n", derandSpecStr)
+	}
+	printNextTimes(timeSpec.NextTimes(from, count))
+	return 0
+}
+
+func printNextTimes(times []time.Time) {
+	if len(times) == 0 {
+		fmt.Println("Never runs.")
+		return
+	}
+	for _, t := range times {
+		fmt.Println(t.Format(gNextTimeFmt))
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dshearer/jobber/ipc"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+func printOutputList(job string, runs []ipc.RunOutputDesc) {
+	if len(runs) == 0 {
+		fmt.Printf("No output kept for job \"%v\".\n", job)
+		return
+	}
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 5, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "RUN\tTIME\tRESULT\tSTDOUT\tSTDERR\n")
+	for _, run := range runs {
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v B\t%v B\n",
+			run.Run,
+			run.Time.Local().Format("Jan _2 15:04:05 2006"),
+			run.Fate,
+			run.StdoutLen,
+			run.StderrLen)
+	}
+	writer.Flush()
+	fmt.Print(buffer.String())
+}
+
+func printSection(title string, data []byte) {
+	fmt.Printf("--- %v ---\n", title)
+	os.Stdout.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		fmt.Println()
+	}
+}
+
+func doOutputCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(OutputCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(OutputCmdStr, "JOB", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	var run_p = flagSet.String("run", "", "show the output of the run "+
+		"with this ID (cf. -list)")
+	var last_p = flagSet.Bool("last", false, "show the output of the "+
+		"latest run (the default)")
+	var list_p = flagSet.Bool("list", false, "list the runs whose output "+
+		"is kept")
+	var stdoutOnly_p = flagSet.Bool("stdout", false, "show just stdout, "+
+		"as is")
+	var stderrOnly_p = flagSet.Bool("stderr", false, "show just stderr, "+
+		"as is")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	// get job
+	if len(flagSet.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "You must specify a job.\n")
+		return 1
+	}
+	var job string = flagSet.Args()[0]
+
+	// check flags
+	if len(*run_p) > 0 && !jobfile.IsValidRunId(*run_p) {
+		fmt.Fprintf(os.Stderr, "Invalid run ID: %v\n", *run_p)
+		return 1
+	}
+	if *last_p && len(*run_p) > 0 {
+		fmt.Fprintf(os.Stderr, "Cannot use both -run and -last.\n")
+		return 1
+	}
+	if *stdoutOnly_p && *stderrOnly_p {
+		fmt.Fprintf(os.Stderr, "Cannot use both -stdout and -stderr.\n")
+		return 1
+	}
+
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(
+			os.Stderr, "Failed to get current user: %v\n", err,
+		)
+		return 1
+	}
+
+	// send command
+	var resp ipc.OutputCmdResp
+	err = CallDaemon(
+		"IpcService.Output",
+		ipc.OutputCmd{Job: job, Run: *run_p, List: *list_p},
+		&resp,
+		usr,
+		timeout_p,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// handle response
+	if *list_p {
+		printOutputList(job, resp.Runs)
+		return 0
+	}
+	if *stdoutOnly_p {
+		os.Stdout.Write(resp.Stdout)
+		return 0
+	}
+	if *stderrOnly_p {
+		os.Stdout.Write(resp.Stderr)
+		return 0
+	}
+	fmt.Printf("Run %v of \"%v\" (%v at %v)\n", resp.Run.Run, job,
+		resp.Run.Fate, resp.Run.Time.Local().Format("Jan _2 15:04:05 2006"))
+	printSection("stdout", resp.Stdout)
+	printSection("stderr", resp.Stderr)
+	return 0
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+)
+
+type PsRespRec struct {
+	usr  *user.User
+	resp *ipc.PsCmdResp
+}
+
+func formatPid(pid int) string {
+	if pid == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%v", pid)
+}
+
+func formatRunPid(run ipc.RunningJobDesc) string {
+	if run.Queued {
+		return "(queued)"
+	}
+	return formatPid(run.Pid)
+}
+
+func formatLock(lock string) string {
+	if len(lock) == 0 {
+		return "-"
+	}
+	return lock
+}
+
+func formatRunLock(run ipc.RunningJobDesc) string {
+	if len(run.Lock) == 0 {
+		return "-"
+	}
+	if run.LockHeld {
+		return run.Lock + " (held)"
+	}
+	return run.Lock + " (waiting)"
+}
+
+func formatPsRespRecs(recs []PsRespRec, showUser bool, now time.Time) string {
+	// collect runs
+	type userRun struct {
+		usr *user.User
+		run ipc.RunningJobDesc
+	}
+	var runs []userRun
+	for _, respRec := range recs {
+		for _, run := range respRec.resp.Runs {
+			runs = append(runs, userRun{respRec.usr, run})
+		}
+	}
+	if len(runs) == 0 {
+		return "No running jobs."
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].run.StartTime.Before(runs[j].run.StartTime)
+	})
+
+	// make table header
+	var buffer bytes.Buffer
+	var writer *tabwriter.Writer = tabwriter.NewWriter(&buffer,
+		5, 0, 2, ' ', 0)
+	headers := []string{"JOB", "RUN ID", "PID", "STARTED", "ELAPSED",
+		"TRIGGER", "LOCK"}
+	if showUser {
+		headers = append(headers, "USER")
+	}
+	writer.Write([]byte(strings.Join(headers, "\t")))
+	writer.Write([]byte("\n"))
+
+	// make table rows
+	var rows []string
+	for _, r := range runs {
+		trigger := "schedule"
+		if r.run.Manual {
+			trigger = "manual"
+		}
+		fields := []string{
+			r.run.Job,
+			r.run.RunId,
+			formatRunPid(r.run),
+			r.run.StartTime.Local().Format("Jan _2 15:04:05 2006"),
+			fmt.Sprintf("%v", now.Sub(r.run.StartTime).Round(time.Second)),
+			trigger,
+			formatRunLock(r.run),
+		}
+		if showUser {
+			fields = append(fields, r.usr.Username)
+		}
+		rows = append(rows, strings.Join(fields, "\t"))
+	}
+	writer.Write([]byte(strings.Join(rows, "\n")))
+
+	// finish up
+	writer.Flush()
+	return buffer.String()
+}
+
+func doPsCmd_allUsers(timeout_p *time.Duration) int {
+	// get all users
+	users, err := common.AllUsersWithSockets()
+	if err != nil {
+		fmt.Fprintf(
+			os.Stderr, "Failed to get all users: %v\n", err,
+		)
+		return 1
+	}
+
+	// send cmd
+	var responses []PsRespRec
+	for _, usr := range users {
+		var resp ipc.PsCmdResp
+		err = CallDaemon(
+			"IpcService.Ps",
+			ipc.PsCmd{},
+			&resp,
+			usr,
+			timeout_p,
+		)
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"Failed to list running jobs for %v: %v\n", usr.Username, err)
+			continue
+		}
+		responses = append(responses, PsRespRec{usr: usr, resp: &resp})
+	}
+
+	// display response records
+	fmt.Println(formatPsRespRecs(responses, true, time.Now()))
+	return 0
+}
+
+func doPsCmd_currUser(timeout_p *time.Duration) int {
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(
+			os.Stderr, "Failed to get current user: %v\n", err,
+		)
+		return 1
+	}
+
+	// send cmd
+	var resp ipc.PsCmdResp
+	err = CallDaemon(
+		"IpcService.Ps",
+		ipc.PsCmd{},
+		&resp,
+		usr,
+		timeout_p,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// display response records
+	rec := PsRespRec{usr: usr, resp: &resp}
+	fmt.Println(formatPsRespRecs([]PsRespRec{rec}, false, time.Now()))
+	return 0
+}
+
+func doPsCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(PsCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(PsCmdStr, "", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var allUsers_p = flagSet.Bool("a", false, "all-users")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	if *allUsers_p {
+		return doPsCmd_allUsers(timeout_p)
+	} else {
+		return doPsCmd_currUser(timeout_p)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/dshearer/jobber/ipc"
+)
+
+func doRunCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(RunCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(RunCmdStr, "JOB", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	// get job to run
+	if len(flagSet.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "You must specify a job.\n")
+		return 1
+	}
+	var job string = flagSet.Args()[0]
+
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(
+			os.Stderr, "Failed to get current user: %v\n", err,
+		)
+		return 1
+	}
+
+	// send command
+	var resp ipc.RunCmdResp
+	err = CallDaemon(
+		"IpcService.Run",
+		ipc.RunCmd{Job: job},
+		&resp,
+		usr,
+		timeout_p,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// handle response
+	fmt.Printf("Started job \"%v\".  Use 'jobber log' to see the result.\n",
+		job)
+	return 0
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+)
+
+const gTailTimeFmt = "Jan _2 15:04:05"
+
+func formatTailLine(t time.Time, userName string, msg string) string {
+	if len(userName) > 0 {
+		return fmt.Sprintf("%v  [%v]  %v", t.Local().Format(gTailTimeFmt),
+			userName, msg)
+	}
+	return fmt.Sprintf("%v  %v", t.Local().Format(gTailTimeFmt), msg)
+}
+
+func formatRunResult(job string, fate string, manual bool,
+	execTime time.Duration, status string, skipReason string,
+	attempt int, limitExceeded string) string {
+
+	if fate == common.SubprocFateSkipped.String() {
+		return fmt.Sprintf("%v: %v (%v)", job, fate, skipReason)
+	}
+	msg := fmt.Sprintf("%v: %v after %v (status: %v)", job, fate,
+		execTime.Round(time.Second), status)
+	if len(limitExceeded) > 0 {
+		msg += " (exceeded " + limitExceeded + ")"
+	}
+	if attempt > 0 {
+		msg += fmt.Sprintf(" (attempt %v)", attempt)
+	}
+	if manual {
+		msg += " (manual)"
+	}
+	return msg
+}
+
+func formatEvent(event ipc.Event) string {
+	var msg string
+	switch event.Type {
+	case ipc.EventRunStarted:
+		msg = fmt.Sprintf("%v: started (run %v, pid %v)", event.Job,
+			event.RunId, event.Pid)
+		if event.Manual {
+			msg += " (manual)"
+		}
+	case ipc.EventRunFinished:
+		msg = formatRunResult(event.Job, event.Fate, event.Manual,
+			event.ExecTime, event.Status, event.SkipReason, event.Attempt,
+			event.Limit)
+		if event.WillRetry {
+			msg += " (will retry)"
+		}
+	case ipc.EventStatusChanged:
+		msg = fmt.Sprintf("%v: status changed from %v to %v", event.Job,
+			event.OldStatus, event.Status)
+	case ipc.EventPaused:
+		msg = fmt.Sprintf("%v: paused", event.Job)
+	case ipc.EventResumed:
+		msg = fmt.Sprintf("%v: resumed", event.Job)
+	case ipc.EventReloaded:
+		msg = fmt.Sprintf("jobfile loaded (%v jobs)", event.NumJobs)
+	default:
+		msg = fmt.Sprintf("%v %v", event.Type, event.Job)
+	}
+	return formatTailLine(event.Time, event.User, msg)
+}
+
+/*
+Print the last few finished runs, oldest first.  (Like the
+runFinished events, they are shown at the time they finished.)
+*/
+func printRecentRuns(users []*user.User, showUser bool, jobs []string,
+	limit int, timeout_p *time.Duration) int {
+
+	var logDescs []EnhancedLogDesc
+	for _, usr := range users {
+		var resp ipc.LogCmdResp
+		err := CallDaemon(
+			"IpcService.Log",
+			ipc.LogCmd{Jobs: jobs, Limit: limit},
+			&resp,
+			usr,
+			timeout_p,
+		)
+		if err != nil {
+			if !showUser {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return 1
+			}
+			fmt.Fprintf(os.Stderr, "Failed to get log for %v: %v\n",
+				usr.Username, err)
+			continue
+		}
+		for _, log := range resp.Logs {
+			logDescs = append(logDescs, EnhancedLogDesc{usr.Name, log, usr})
+		}
+	}
+
+	sort.Sort(EnhancedLogDescSorter(logDescs))
+	if len(logDescs) > limit {
+		logDescs = logDescs[:limit]
+	}
+	for i := len(logDescs) - 1; i >= 0; i-- {
+		e := logDescs[i].logDesc
+		var userName string
+		if showUser {
+			userName = logDescs[i].usr.Username
+		}
+		msg := formatRunResult(e.Job, e.Fate, e.Manual, e.ExecTime, e.Result,
+			e.SkipReason, e.Attempt, e.LimitExceeded)
+		fmt.Println(formatTailLine(e.Time.Add(e.ExecTime), userName, msg))
+	}
+	return 0
+}
+
+func doTailCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(TailCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(TailCmdStr, "", flagSet)
+	var help_p = flagSet.Bool("h", false, "help")
+	var allUsers_p = flagSet.Bool("a", false, "all-users")
+	var timeout_p = flagSet.Duration("t", 5*time.Second, "timeout")
+	var follow_p = flagSet.Bool("f", false,
+		"keep showing events as they happen")
+	var jobs_p = flagSet.String("job", "",
+		"show only these jobs (comma-separated)")
+	var limit_p = flagSet.Int("n", 10, "number of recent runs to show")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+	if *limit_p < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid number of runs: %v\n", *limit_p)
+		return 1
+	}
+	jobs := splitListArg(*jobs_p)
+
+	// get users
+	var users []*user.User
+	if *allUsers_p {
+		var err error
+		users, err = common.AllUsersWithSockets()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get all users: %v\n", err)
+			return 1
+		}
+	} else {
+		usr, err := user.Current()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get current user: %v\n", err)
+			return 1
+		}
+		users = []*user.User{usr}
+	}
+
+	/*
+		Subscribe to events before getting the recent runs, so that we
+		don't miss any.
+	*/
+	var stream *ipc.EventStream
+	if *follow_p {
+		var sockPath string
+		if *allUsers_p {
+			sockPath = common.MasterEventSocketPath()
+		} else {
+			sockPath = common.EventSocketPath(users[0])
+		}
+		var err error
+		stream, err = ipc.DialEvents(sockPath, ipc.SubscribeRequest{Jobs: jobs})
+		if err != nil {
+			if *allUsers_p {
+				fmt.Fprintf(os.Stderr, "Failed to subscribe to events from "+
+					"jobbermaster (are you root?): %v\n", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Failed to subscribe to events: %v\n",
+					err)
+			}
+			return 1
+		}
+		defer stream.Close()
+	}
+
+	// show recent runs
+	if *limit_p > 0 {
+		if res := printRecentRuns(users, *allUsers_p, jobs, *limit_p,
+			timeout_p); res != 0 {
+			return res
+		}
+	}
+
+	if stream == nil {
+		return 0
+	}
+
+	// show events
+	for {
+		event, err := stream.Next()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Lost connection to Jobber: %v\n", err)
+			return 1
+		}
+		fmt.Println(formatEvent(event))
+	}
+}
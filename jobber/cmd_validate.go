@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/dshearer/jobber/jobfile"
+	"gopkg.in/yaml.v2"
+)
+
+func doValidateCmd(args []string) int {
+	// parse flags
+	flagSet := flag.NewFlagSet(ValidateCmdStr, flag.ExitOnError)
+	flagSet.Usage = subcmdUsage(ValidateCmdStr, "[PATH]", flagSet)
+	var help_p *bool = flagSet.Bool("h", false, "help")
+	var print_p *bool = flagSet.Bool("print", false,
+		"print the jobfile as normalized v3 YAML")
+	flagSet.Parse(args)
+
+	if *help_p {
+		flagSet.Usage()
+		return 0
+	}
+
+	// get current user
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Fprintf(
+			os.Stderr, "Failed to get current user: %v\n", err,
+		)
+		return 1
+	}
+
+	// get jobfile to validate
+	var path string
+	if len(flagSet.Args()) > 0 {
+		path = flagSet.Args()[0]
+	} else {
+		path = filepath.Join(usr.HomeDir, ".jobber")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	// validate it
+	raw, problems := jobfile.ValidateJobfile(f, usr, time.Now())
+	hadError := false
+	for _, problem := range problems {
+		if problem.Line > 0 {
+			fmt.Fprintf(os.Stderr, "%v:%v\n", path, problem)
+		} else {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", path, problem)
+		}
+		if problem.Severity == jobfile.ProblemError {
+			hadError = true
+		}
+	}
+	if hadError {
+		return 1
+	}
+
+	// print it
+	if *print_p {
+		if raw.Version.IsZero() {
+			raw.Version = jobfile.SemVer{Major: 1, Minor: 4}
+		}
+		data, err := yaml.Marshal(raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		fmt.Print(string(data))
+	}
+	return 0
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const gDiffContextLines = 3
+
+type diffOp struct {
+	kind byte // ' ', '-', or '+'
+	line string
+}
+
+/*
+Compute a line-based diff of two texts, using the longest common
+subsequence of their lines.  Jobfiles are small, so the quadratic
+algorithm is fine.
+*/
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		} else {
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+func splitLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+/*
+Make a unified diff of two texts.  Returns "" if they are the same.
+*/
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	// find hunks
+	var builder strings.Builder
+	idx := 0
+	for idx < len(ops) {
+		// find next change
+		for idx < len(ops) && ops[idx].kind == ' ' {
+			idx++
+		}
+		if idx == len(ops) {
+			break
+		}
+		start := idx - gDiffContextLines
+		if start < 0 {
+			start = 0
+		}
+
+		// find end of hunk (i.e., a long-enough run of unchanged lines)
+		end := idx
+		unchanged := 0
+		for end < len(ops) && unchanged <= 2*gDiffContextLines {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		if unchanged > gDiffContextLines {
+			end -= unchanged - gDiffContextLines
+		}
+
+		// compute line numbers
+		oldLine, newLine := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		if oldCount == 0 {
+			oldLine--
+		}
+		if newCount == 0 {
+			newLine--
+		}
+
+		if builder.Len() == 0 {
+			fmt.Fprintf(&builder, "--- %v\n+++ %v\n", oldName, newName)
+		}
+		fmt.Fprintf(&builder, "@@ -%v,%v +%v,%v @@\n", oldLine, oldCount,
+			newLine, newCount)
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&builder, "%c%v\n", op.kind, op.line)
+		}
+		idx = end
+	}
+	return builder.String()
+}
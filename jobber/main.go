@@ -13,11 +13,24 @@ const (
 	LogCmdStr    = "log"
 	ReloadCmdStr = "reload"
 	//	StopCmdStr   = "stop"
-	TestCmdStr   = "test"
-	CatCmdStr    = "cat"
-	PauseCmdStr  = "pause"
-	ResumeCmdStr = "resume"
-	InitCmdStr   = "init"
+	TestCmdStr          = "test"
+	CatCmdStr           = "cat"
+	PauseCmdStr         = "pause"
+	ResumeCmdStr        = "resume"
+	InitCmdStr          = "init"
+	ValidateCmdStr      = "validate"
+	NextCmdStr          = "next"
+	RunCmdStr           = "run"
+	PsCmdStr            = "ps"
+	KillCmdStr          = "kill"
+	AddCmdStr           = "add"
+	SetCmdStr           = "set"
+	RmCmdStr            = "rm"
+	TailCmdStr          = "tail"
+	OutputCmdStr        = "output"
+	ImportCrontabCmdStr = "import-crontab"
+	ExportCrontabCmdStr = "export-crontab"
+	AtCmdStr            = "at"
 )
 
 var CmdStrs = [...]string{
@@ -30,19 +43,45 @@ var CmdStrs = [...]string{
 	PauseCmdStr,
 	ResumeCmdStr,
 	InitCmdStr,
+	ValidateCmdStr,
+	NextCmdStr,
+	RunCmdStr,
+	PsCmdStr,
+	KillCmdStr,
+	AddCmdStr,
+	SetCmdStr,
+	RmCmdStr,
+	TailCmdStr,
+	OutputCmdStr,
+	ImportCrontabCmdStr,
+	ExportCrontabCmdStr,
+	AtCmdStr,
 }
 
 type CmdHandler func([]string) int
 
 var CmdHandlers = map[string]CmdHandler{
-	ListCmdStr:   doListCmd,
-	LogCmdStr:    doLogCmd,
-	ReloadCmdStr: doReloadCmd,
-	TestCmdStr:   doTestCmd,
-	CatCmdStr:    doCatCmd,
-	PauseCmdStr:  doPauseCmd,
-	ResumeCmdStr: doResumeCmd,
-	InitCmdStr:   doInitCmd,
+	ListCmdStr:          doListCmd,
+	LogCmdStr:           doLogCmd,
+	ReloadCmdStr:        doReloadCmd,
+	TestCmdStr:          doTestCmd,
+	CatCmdStr:           doCatCmd,
+	PauseCmdStr:         doPauseCmd,
+	ResumeCmdStr:        doResumeCmd,
+	InitCmdStr:          doInitCmd,
+	ValidateCmdStr:      doValidateCmd,
+	NextCmdStr:          doNextCmd,
+	RunCmdStr:           doRunCmd,
+	PsCmdStr:            doPsCmd,
+	KillCmdStr:          doKillCmd,
+	AddCmdStr:           doAddCmd,
+	SetCmdStr:           doSetCmd,
+	RmCmdStr:            doRmCmd,
+	TailCmdStr:          doTailCmd,
+	OutputCmdStr:        doOutputCmd,
+	ImportCrontabCmdStr: doImportCrontabCmd,
+	ExportCrontabCmdStr: doExportCrontabCmd,
+	AtCmdStr:            doAtCmd,
 }
 
 func usage() {
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+The list, log, and cat commands can print their results as JSON, YAML,
+or TSV (instead of as a table meant for people), for use by scripts.
+
+The records printed are described by the *OutputRec structs below;
+their JSON field names are also used as the YAML field names and as the
+TSV column headers.  The schema is stable: fields will not be renamed,
+removed, or reordered, though new fields may be added at the end.
+
+  - JSON: an array of records.
+  - YAML: a sequence of records.
+  - TSV: a header line, then one line per record.  In values, tabs,
+    newlines, carriage returns, and backslashes are written as \t, \n,
+    \r, and \\.  Absent values are empty.
+
+Times are in RFC 3339 format; durations are in seconds.  The "user"
+field is always set, even when not listing all users.
+*/
+
+type OutputFormat int
+
+const (
+	OutputTable OutputFormat = iota
+	OutputJson
+	OutputYaml
+	OutputTsv
+)
+
+/*
+Describes one job (for 'jobber list').
+*/
+type JobOutputRec struct {
+	User            string     `json:"user" yaml:"user"`
+	Name            string     `json:"name" yaml:"name"`
+	Status          string     `json:"status" yaml:"status"`
+	Schedule        string     `json:"schedule" yaml:"schedule"`
+	NextRunTime     *time.Time `json:"nextRunTime" yaml:"nextRunTime"`
+	NotifyOnSuccess string     `json:"notifyOnSuccess" yaml:"notifyOnSuccess"`
+	NotifyOnError   string     `json:"notifyOnError" yaml:"notifyOnError"`
+	NotifyOnFailure string     `json:"notifyOnFailure" yaml:"notifyOnFailure"`
+	ErrHandler      string     `json:"errHandler" yaml:"errHandler"`
+	NotifyOnWarning string     `json:"notifyOnWarning" yaml:"notifyOnWarning"`
+	Lock            string     `json:"lock,omitempty" yaml:"lock,omitempty"`
+}
+
+/*
+Describes one run of a job (for 'jobber log').  JobStatus is the status
+of the job after the run.  RunId is empty for runs logged by versions
+of Jobber that didn't assign run IDs.  SkipReason is set only for
+skipped runs.  Attempt is set only for jobs with a retry policy, and
+QueueDelaySec only for runs that had to wait (cf. maxConcurrentJobs).
+LimitExceeded is set only for runs that exceeded their resource limits.
+*/
+type RunOutputRec struct {
+	User          string    `json:"user" yaml:"user"`
+	Time          time.Time `json:"time" yaml:"time"`
+	Job           string    `json:"job" yaml:"job"`
+	Fate          string    `json:"fate" yaml:"fate"`
+	Manual        bool      `json:"manual" yaml:"manual"`
+	ExecTimeSec   float64   `json:"execTimeSec" yaml:"execTimeSec"`
+	JobStatus     string    `json:"jobStatus" yaml:"jobStatus"`
+	RunId         string    `json:"runId" yaml:"runId"`
+	SkipReason    string    `json:"skipReason" yaml:"skipReason"`
+	Attempt       int       `json:"attempt,omitempty" yaml:"attempt,omitempty"`
+	QueueDelaySec float64   `json:"queueDelaySec,omitempty" yaml:"queueDelaySec,omitempty"`
+	LimitExceeded string    `json:"limitExceeded,omitempty" yaml:"limitExceeded,omitempty"`
+}
+
+/*
+Describes a job's command (for 'jobber cat').
+*/
+type CmdOutputRec struct {
+	User string `json:"user" yaml:"user"`
+	Job  string `json:"job" yaml:"job"`
+	Cmd  string `json:"cmd" yaml:"cmd"`
+}
+
+func addOutputFlag(flagSet *flag.FlagSet) *string {
+	return flagSet.String("output", "table",
+		"output format: table, json, yaml, or tsv")
+}
+
+func parseOutputFormat(s string) (OutputFormat, error) {
+	switch strings.ToLower(s) {
+	case "table", "":
+		return OutputTable, nil
+	case "json":
+		return OutputJson, nil
+	case "yaml":
+		return OutputYaml, nil
+	case "tsv":
+		return OutputTsv, nil
+	default:
+		return OutputTable, &common.Error{
+			What: fmt.Sprintf("Invalid output format: \"%v\"", s),
+		}
+	}
+}
+
+/*
+Write records (a slice of one of the *OutputRec structs) in a
+machine-readable format.
+*/
+func writeOutputRecs(w io.Writer, format OutputFormat, recs interface{}) error {
+	switch format {
+	case OutputJson:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(recs)
+
+	case OutputYaml:
+		data, err := yaml.Marshal(recs)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+
+	case OutputTsv:
+		return writeTsv(w, recs)
+
+	default:
+		panic("Unhandled OutputFormat value")
+	}
+}
+
+var gTsvEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\t", "\\t",
+	"\n", "\\n",
+	"\r", "\\r",
+)
+
+func writeTsv(w io.Writer, recs interface{}) error {
+	recsVal := reflect.ValueOf(recs)
+	recType := recsVal.Type().Elem()
+
+	// write header
+	var headers []string
+	for i := 0; i < recType.NumField(); i++ {
+		name := strings.Split(recType.Field(i).Tag.Get("json"), ",")[0]
+		headers = append(headers, name)
+	}
+	if _, err := fmt.Fprintf(w, "%v\n", strings.Join(headers, "\t")); err != nil {
+		return err
+	}
+
+	// write records
+	for i := 0; i < recsVal.Len(); i++ {
+		rec := recsVal.Index(i)
+		var fields []string
+		for j := 0; j < rec.NumField(); j++ {
+			fields = append(fields, gTsvEscaper.Replace(tsvValue(rec.Field(j))))
+		}
+		if _, err := fmt.Fprintf(w, "%v\n", strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tsvValue(val reflect.Value) string {
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return ""
+		}
+		val = val.Elem()
+	}
+	if t, ok := val.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v", val.Interface())
+}
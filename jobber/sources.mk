@@ -1,14 +1,27 @@
 CLIENT_SOURCES := \
+	jobber/cmd_at.go \
 	jobber/cmd_cat.go \
+	jobber/cmd_crontab.go \
+	jobber/cmd_edit_job.go \
 	jobber/cmd_init.go \
+	jobber/cmd_kill.go \
 	jobber/cmd_list.go \
 	jobber/cmd_log.go \
+	jobber/cmd_next.go \
+	jobber/cmd_output.go \
 	jobber/cmd_pause.go \
+	jobber/cmd_ps.go \
 	jobber/cmd_reload.go \
 	jobber/cmd_resume.go \
+	jobber/cmd_run.go \
+	jobber/cmd_tail.go \
 	jobber/cmd_test_job.go \
+	jobber/cmd_validate.go \
 	jobber/daemon_client.go \
+	jobber/diff.go \
 	jobber/main.go \
+	jobber/output.go \
+	jobber/time_arg.go \
 	jobber/sources.mk
 
 CLIENT_TEST_SOURCES :=
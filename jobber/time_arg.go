@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+var gTimeArgLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+/*
+Parse a time given on the command line.  Times without a zone are
+taken to be local.
+*/
+func parseTimeArg(s string) (time.Time, error) {
+	for _, layout := range gTimeArgLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(
+		"Invalid time: \"%v\" (expected e.g. \"2006-01-02 15:04:05\")", s)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/user"
+	"syscall"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+)
+
+/*
+How long to wait before trying again to subscribe to a runner's events.
+*/
+const gEventRelayRetryDelay = 5 * time.Second
+
+/*
+Make the socket on which we publish all runners' events.  Only root can
+use it.
+*/
+func launchMasterEventServer(hub *ipc.EventHub) (net.Listener, error) {
+	sockPath := common.MasterEventSocketPath()
+
+	oldUmask := syscall.Umask(0077)
+	defer syscall.Umask(oldUmask)
+
+	os.Remove(sockPath)
+	addr, err := net.ResolveUnixAddr("unix", sockPath)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenUnix("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	go ipc.ServeEvents(listener, hub)
+	return listener, nil
+}
+
+/*
+Subscribe to the events from a user's runner and republish them on the
+given hub, with their User field set.  Resubscribes when the runner
+restarts.  Returns when ctx is cancelled.
+*/
+func relayEvents(ctx context.Context, usr *user.User, hub *ipc.EventHub) {
+	sockPath := common.EventSocketPath(usr)
+	for {
+		stream, err := ipc.DialEvents(sockPath, ipc.SubscribeRequest{})
+		if err == nil {
+			// close stream when ctx is cancelled
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					stream.Close()
+				case <-done:
+				}
+			}()
+
+			for {
+				event, err := stream.Next()
+				if err != nil {
+					break
+				}
+				event.User = usr.Username
+				hub.Publish(event)
+			}
+			close(done)
+			stream.Close()
+		}
+
+		// wait for runner to come (back)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(gEventRelayRetryDelay):
+		}
+	}
+}
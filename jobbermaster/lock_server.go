@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"syscall"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+)
+
+/*
+Make the socket on which we serve system locks (i.e., locks shared by
+all users' jobs) to the given user's runner.  Only that user may use
+it.
+*/
+func launchLockServer(usr *user.User, table *ipc.LockTable) (net.Listener,
+	error) {
+
+	sockPath := common.LockSocketPath(usr)
+
+	oldUmask := syscall.Umask(0077)
+	defer syscall.Umask(oldUmask)
+
+	os.Remove(sockPath)
+	addr, err := net.ResolveUnixAddr("unix", sockPath)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenUnix("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.Chown(sockPath, usr); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	go ipc.ServeLocks(listener, table)
+	return listener, nil
+}
@@ -13,6 +13,7 @@ import (
 
 	arg "github.com/alexflint/go-arg"
 	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
 )
 
 /*
@@ -135,6 +136,20 @@ func doDefault(args argsS) int {
 	ctx, cancelCtx :=
 		context.WithCancel(context.Background())
 	var runnerWaitGroup sync.WaitGroup
+
+	// make socket for all runners' events
+	eventHub := ipc.NewEventHub()
+	eventListener, err := launchMasterEventServer(eventHub)
+	if err != nil {
+		common.ErrLogger.Printf("Failed to make event socket: %v", err)
+	} else {
+		defer os.Remove(common.MasterEventSocketPath())
+		defer eventListener.Close()
+	}
+
+	// system locks are shared by all users
+	lockTable := ipc.NewLockTable()
+
 	for _, usr := range users {
 		// look for jobfile
 		jobfilePath := filepath.Join(usr.HomeDir, gJobFileName)
@@ -158,12 +173,31 @@ func doDefault(args argsS) int {
 			continue
 		}
 
+		// make socket for system locks
+		lockListener, err := launchLockServer(usr, lockTable)
+		if err != nil {
+			common.ErrLogger.Printf(
+				"Failed to make lock socket for %v: %v",
+				usr.Username,
+				err)
+		} else {
+			defer os.Remove(common.LockSocketPath(usr))
+			defer lockListener.Close()
+		}
+
 		// launch thread to monitor runner process
 		runnerWaitGroup.Add(1)
 		go func(u *user.User, p string) {
 			defer runnerWaitGroup.Done()
 			runnerThread(ctx, u, p)
 		}(usr, jobfilePath)
+
+		// launch thread to relay runner's events
+		runnerWaitGroup.Add(1)
+		go func(u *user.User) {
+			defer runnerWaitGroup.Done()
+			relayEvents(ctx, u, eventHub)
+		}(usr)
 	}
 
 	// Set up channel on which to send signal notifications.
@@ -96,6 +96,7 @@ func LaunchRunner(usr *user.User,
 		quotify(runnerPath),
 		"-q", quotify(runnerProc.quitSockPath),
 		"-u", quotify(common.CmdSocketPath(usr)),
+		"-e", quotify(common.EventSocketPath(usr)),
 		quotify(jobfilePath),
 	}
 	cmdParts = append(cmdParts, "-t", quotify(common.TempDirPath()))
@@ -1,8 +1,10 @@
 MASTER_SOURCES := \
 	jobbermaster/bounded_buffer.go \
+	jobbermaster/event_relay.go \
 	jobbermaster/get_users_darwin.go \
 	jobbermaster/get_users_nondarwin.go \
 	jobbermaster/get_users.go \
+	jobbermaster/lock_server.go \
 	jobbermaster/main.go \
 	jobbermaster/runner_proc.go \
 	jobbermaster/sources.mk
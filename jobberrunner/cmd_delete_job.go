@@ -1,26 +1,28 @@
 package main
 
 import (
+	"github.com/dshearer/jobber/common"
 	"github.com/dshearer/jobber/ipc"
 	"github.com/dshearer/jobber/jobfile"
 )
 
 func (self *JobManager) doDeleteJobCmd(cmd ipc.DeleteJobCmd) ipc.ICmdResp {
-	// make new raw jobfile
-	newJobs := make(map[string]jobfile.JobRaw)
-	for currJobName, currJob := range self.jfile.Raw.Jobs {
-		if currJobName == cmd.Job {
-			continue
+	edit := func(text string) (string, error) {
+		if len(text) == 0 {
+			return "", &common.Error{What: "No such job."}
 		}
-		newJobs[currJobName] = currJob
-	}
-	newJobfile := jobfile.JobFileRaw{
-		Prefs: self.jfile.Raw.Prefs,
-		Jobs:  newJobs,
+		return jobfile.DeleteJobFromText(text, cmd.Job)
 	}
 
-	// install it
-	self.replaceCurrJobfile(&newJobfile)
+	oldText, newText, err := self.editJobfile(edit, cmd.DryRun,
+		cmd.ExpectedJobfile)
+	if err != nil {
+		return ipc.NewErrorCmdResp(err)
+	}
 
-	return ipc.DeleteJobCmdResp{Ok: true}
+	return ipc.DeleteJobCmdResp{
+		Ok:         true,
+		OldJobfile: oldText,
+		NewJobfile: newText,
+	}
 }
@@ -45,6 +45,11 @@ prefs:
   #    maxFileLen: 50m  # in MB
   #    maxHistories: 5
 
+  ## You can limit how many of your jobs run at once.  Runs over the
+  ## limit wait in a queue, ordered by the jobs' "priority" settings
+  ## (highest first) and then by when they were meant to start.
+  #maxConcurrentJobs: 4
+
 resultSinks:
   #- &programSink
   #  type: program
@@ -64,10 +69,21 @@ jobs:
   #DailyBackup:
   #    cmd: backup daily  # shell command to execute
   #    time: '* * * * * *'  # SEC MIN HOUR MONTH_DAY MONTH WEEK_DAY.
-  #    onError: Continue  # what to do when the job has an error: Stop, Backoff, or Continue
+  #    watch: {path: /path/to/inbox, events: [create, write, move], debounce: 1s}  # also run when files here change (Linux only)
+  #    when: {pathMounted: /mnt/backup}  # run only if these conditions hold (cmd, fileExists, pathMounted, loadBelow)
+  #    priority: 10  # when runs are queued (cf. maxConcurrentJobs), higher priorities run first (default: 0)
+  #    resources: {cpuTime: 1h, memoryMax: 1g, nice: 10, ionice: idle}  # limits on what the job may use (also addressSpace, openFiles, cpuMax)
+  #    sandbox: {privateTmp: true, readOnly: [/home], noNetwork: true, noNewPrivs: true}  # restrict what the job may see and do (Linux only)
+  #    secrets: {API_TOKEN: .secrets/api-token}  # env vars read from files only you can access; their values are masked in output
+  #    lock: {name: db, policy: wait, timeout: 1h}  # don't run at the same time as other jobs with this lock (policy: wait or skip; system: true to share it with other users' jobs)
+  #    onError: Continue  # what to do when the job has an error: Stop, Backoff, Continue, backoff(max=32), stop-after(n=3), or cooldown(1h)
+  #    onStatusChange: echo "$JOBBER_JOB_NAME is $JOBBER_NEW_STATUS" >> ~/jobber-status.log  # command to run when the job's status changes
   #    notifyOnError: [*programSink]  # what to do with result when job has an error
   #    notifyOnFailure: [*systemEmailSink, *programSink]  # what to do with result when the job stops due to errors
   #    notifyOnSuccess: [*filesystemSink]  # what to do with result when the job succeeds
+  #    notifyOnWarning: [*programSink]  # what to do with result when the job succeeds with a warning
+  #    successExitCodes: [0]  # exit codes meaning success
+  #    warningExitCodes: [3]  # exit codes meaning success with a warning
 `
 
 func (self *JobManager) doInitCmd(cmd ipc.InitCmd) ipc.ICmdResp {
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+)
+
+func (self *JobManager) doKillCmd(cmd ipc.KillCmd) ipc.ICmdResp {
+	// find job
+	_, ok := self.jfile.Jobs[cmd.Job]
+	if !ok {
+		return ipc.NewErrorCmdResp(&common.Error{What: "No such job."})
+	}
+
+	// cancel its runs
+	numKilled := self.jobRunner.CancelRuns(cmd.Job)
+	if numKilled == 0 {
+		return ipc.NewErrorCmdResp(
+			&common.Error{What: "Job is not running."},
+		)
+	}
+
+	// make response
+	return ipc.KillCmdResp{NumKilled: numKilled}
+}
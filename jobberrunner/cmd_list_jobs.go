@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dshearer/jobber/ipc"
 	"github.com/dshearer/jobber/jobfile"
@@ -16,9 +17,35 @@ func resultSinksString(sinks []jobfile.ResultSink) string {
 	return strings.Join(strs, ",")
 }
 
+/*
+Describe the given job's lock, with its state (if any run of the job
+holds it or is waiting for it).
+*/
+func lockString(job *jobfile.Job, runs []RunningJob) string {
+	if job.Lock == nil {
+		return ""
+	}
+	state := ""
+	for _, run := range runs {
+		if run.Job.Name != job.Name || len(run.Lock) == 0 {
+			continue
+		}
+		if run.LockHeld {
+			state = " (held)"
+			break
+		}
+		state = " (waiting)"
+	}
+	return job.Lock.String() + state
+}
+
 func (self *JobManager) doListJobsCmd(cmd ipc.ListJobsCmd) ipc.ICmdResp {
 	// make job list
 	jobDescs := make([]ipc.JobDesc, 0)
+	now := time.Now()
+	runs := self.jobRunner.RunningJobs()
+	gJobStateLock.Lock()
+	defer gJobStateLock.Unlock()
 	for _, j := range self.jfile.Jobs {
 		jobDesc := ipc.JobDesc{
 			Name:   j.Name,
@@ -35,7 +62,16 @@ func (self *JobManager) doListJobsCmd(cmd ipc.ListJobsCmd) ipc.ICmdResp {
 			NotifyOnSuccess: resultSinksString(j.NotifyOnSuccess),
 			NotifyOnErr:     resultSinksString(j.NotifyOnError),
 			NotifyOnFail:    resultSinksString(j.NotifyOnFailure),
+			NotifyOnWarn:    resultSinksString(j.NotifyOnWarning),
 			ErrHandler:      j.ErrorHandler.String(),
+			Lock:            lockString(j, runs),
+		}
+		if j.At != nil || j.Watch != nil {
+			jobDesc.Schedule = j.ScheduleString()
+		}
+		if s := j.ActivityString(now); len(s) > 0 {
+			jobDesc.Status = s
+			jobDesc.NextRunTime = nil
 		}
 		if j.Paused {
 			jobDesc.Status += " (Paused)"
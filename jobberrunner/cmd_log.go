@@ -3,27 +3,43 @@ package main
 import (
 	"github.com/dshearer/jobber/common"
 	"github.com/dshearer/jobber/ipc"
+	"github.com/dshearer/jobber/jobfile"
 )
 
 func (self *JobManager) doLogCmd(cmd ipc.LogCmd) ipc.ICmdResp {
 	// make log list
 	var logDescs []ipc.LogDesc
-	entries, err := self.jfile.Prefs.RunLog.GetAll()
+	query := jobfile.RunLogQuery{
+		Jobs:   cmd.Jobs,
+		Fates:  cmd.Fates,
+		Since:  cmd.Since,
+		Until:  cmd.Until,
+		Limit:  cmd.Limit,
+		Cursor: cmd.Cursor,
+	}
+	entries, nextCursor, err := jobfile.QueryRunLog(
+		self.jfile.Prefs.RunLog, query)
 	if err != nil {
 		return ipc.NewErrorCmdResp(err)
 	}
 	for _, l := range entries {
 		logDesc := ipc.LogDesc{
-			Time:      l.Time,
-			Job:       l.JobName,
-			Succeeded: l.Fate == common.SubprocFateSucceeded, // deprecated
-			Fate:      l.Fate.String(),
-			ExecTime:  l.ExecTime,
-			Result:    l.Result.String(),
+			Time:          l.Time,
+			Job:           l.JobName,
+			Succeeded:     l.Fate == common.SubprocFateSucceeded, // deprecated
+			Fate:          l.Fate.String(),
+			ExecTime:      l.ExecTime,
+			Result:        l.Result.String(),
+			Manual:        l.Manual,
+			RunId:         l.RunId,
+			SkipReason:    l.SkipReason,
+			Attempt:       l.Attempt,
+			QueueDelay:    l.QueueDelay,
+			LimitExceeded: l.LimitExceeded,
 		}
 		logDescs = append(logDescs, logDesc)
 	}
 
 	// make response
-	return ipc.LogCmdResp{Logs: logDescs}
+	return ipc.LogCmdResp{Logs: logDescs, NextCursor: nextCursor}
 }
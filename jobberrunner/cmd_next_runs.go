@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+)
+
+const gMaxNextRuns = 1000
+
+func (self *JobManager) doNextRunsCmd(cmd ipc.NextRunsCmd) ipc.ICmdResp {
+	// find job
+	job, ok := self.jfile.Jobs[cmd.Job]
+	if !ok {
+		return ipc.NewErrorCmdResp(&common.Error{What: "No such job."})
+	}
+
+	if cmd.Count < 1 || cmd.Count > gMaxNextRuns {
+		return ipc.NewErrorCmdResp(&common.Error{
+			What: fmt.Sprintf("Count must be between 1 and %v.",
+				gMaxNextRuns),
+		})
+	}
+	from := time.Now()
+	if cmd.From != nil {
+		from = *cmd.From
+	}
+
+	// make response
+	gJobStateLock.Lock()
+	defer gJobStateLock.Unlock()
+	return ipc.NextRunsCmdResp{
+		Schedule: job.ScheduleString(),
+		Times:    job.NextRunTimes(from, cmd.Count),
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+func outputDescToIpc(desc jobfile.RunOutputDesc) ipc.RunOutputDesc {
+	return ipc.RunOutputDesc{
+		Run:       desc.RunId,
+		Time:      desc.Time,
+		Fate:      desc.Fate.String(),
+		StdoutLen: desc.StdoutLen,
+		StderrLen: desc.StderrLen,
+	}
+}
+
+func (self *JobManager) doOutputCmd(cmd ipc.OutputCmd) ipc.ICmdResp {
+	store := self.jfile.Prefs.RunOutputs
+	if store == nil {
+		msg := "Output is not being kept.  To keep it, add an \"output\" " +
+			"section to the \"runLog\" prefs in your jobfile."
+		return ipc.NewErrorCmdResp(&common.Error{What: msg})
+	}
+
+	// list runs
+	if cmd.List {
+		var resp ipc.OutputCmdResp
+		resp.Runs = make([]ipc.RunOutputDesc, 0)
+		for _, desc := range store.List(cmd.Job) {
+			resp.Runs = append(resp.Runs, outputDescToIpc(desc))
+		}
+		return resp
+	}
+
+	// get output
+	output, err := store.Get(cmd.Job, cmd.Run)
+	if err != nil {
+		return ipc.NewErrorCmdResp(err)
+	}
+	if output == nil {
+		var msg string
+		if len(cmd.Run) == 0 {
+			msg = fmt.Sprintf("No output kept for job \"%v\".", cmd.Job)
+		} else {
+			msg = fmt.Sprintf("No output kept for run %v of job \"%v\".",
+				cmd.Run, cmd.Job)
+		}
+		return ipc.NewErrorCmdResp(&common.Error{What: msg})
+	}
+	return ipc.OutputCmdResp{
+		Run:    outputDescToIpc(output.RunOutputDesc),
+		Stdout: output.Stdout,
+		Stderr: output.Stderr,
+	}
+}
@@ -32,6 +32,7 @@ func (self *JobManager) doPauseCmd(cmd ipc.PauseCmd) ipc.ICmdResp {
 		if !job.Paused {
 			job.Paused = true
 			numPaused += 1
+			self.Events.Publish(ipc.Event{Type: ipc.EventPaused, Job: job.Name})
 		}
 	}
 
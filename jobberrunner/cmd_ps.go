@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/dshearer/jobber/ipc"
+)
+
+func (self *JobManager) doPsCmd(cmd ipc.PsCmd) ipc.ICmdResp {
+	// make run list
+	runDescs := make([]ipc.RunningJobDesc, 0)
+	for _, run := range self.jobRunner.RunningJobs() {
+		runDesc := ipc.RunningJobDesc{
+			Job:       run.Job.Name,
+			RunId:     run.RunId,
+			Pid:       run.Pid,
+			StartTime: run.StartTime,
+			Manual:    run.Manual,
+			Lock:      run.Lock,
+			LockHeld:  run.LockHeld,
+			Queued:    run.Queued,
+		}
+		runDescs = append(runDescs, runDesc)
+	}
+
+	// make response
+	return ipc.PsCmdResp{Runs: runDescs}
+}
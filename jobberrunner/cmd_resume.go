@@ -32,6 +32,7 @@ func (self *JobManager) doResumeCmd(cmd ipc.ResumeCmd) ipc.ICmdResp {
 		if job.Paused {
 			job.Paused = false
 			numResumed += 1
+			self.Events.Publish(ipc.Event{Type: ipc.EventResumed, Job: job.Name})
 		}
 	}
 
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+)
+
+func (self *JobManager) doRunCmd(cmd ipc.RunCmd) ipc.ICmdResp {
+	// find job
+	job, ok := self.jfile.Jobs[cmd.Job]
+	if !ok {
+		return ipc.NewErrorCmdResp(&common.Error{What: "No such job."})
+	}
+
+	// run it
+	if err := self.jobRunner.RunNow(job); err != nil {
+		return ipc.NewErrorCmdResp(err)
+	}
+
+	// make response
+	return ipc.RunCmdResp{Ok: true}
+}
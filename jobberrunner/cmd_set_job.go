@@ -1,21 +1,135 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/dshearer/jobber/common"
 	"github.com/dshearer/jobber/ipc"
+	"github.com/dshearer/jobber/jobfile"
 )
 
+/*
+Make a job that has newJob's values for the fields that are set in
+newJob, and oldJob's values for the others.
+*/
+func mergeRawJobs(oldJob, newJob jobfile.JobRaw) jobfile.JobRaw {
+	merged := oldJob
+	if len(newJob.Cmd) > 0 {
+		merged.Cmd = newJob.Cmd
+	}
+	if len(newJob.Time) > 0 {
+		merged.Time = newJob.Time
+		merged.At = nil
+	}
+	if newJob.At != nil {
+		merged.At = newJob.At
+		merged.Time = ""
+	}
+	if newJob.ActiveFrom != nil {
+		merged.ActiveFrom = newJob.ActiveFrom
+	}
+	if newJob.ActiveUntil != nil {
+		merged.ActiveUntil = newJob.ActiveUntil
+	}
+	if newJob.MaxRuns != nil {
+		merged.MaxRuns = newJob.MaxRuns
+	}
+	if newJob.Priority != nil {
+		merged.Priority = newJob.Priority
+	}
+	if newJob.Retry != nil {
+		merged.Retry = newJob.Retry
+	}
+	if newJob.When != nil {
+		merged.When = newJob.When
+	}
+	if newJob.Watch != nil {
+		merged.Watch = newJob.Watch
+	}
+	if newJob.Lock != nil {
+		merged.Lock = newJob.Lock
+	}
+	if newJob.Resources != nil {
+		merged.Resources = newJob.Resources
+	}
+	if newJob.Sandbox != nil {
+		merged.Sandbox = newJob.Sandbox
+	}
+	if newJob.Secrets != nil {
+		merged.Secrets = newJob.Secrets
+	}
+	if newJob.OnError != nil {
+		merged.OnError = newJob.OnError
+	}
+	if newJob.OnStatusChange != nil {
+		merged.OnStatusChange = newJob.OnStatusChange
+	}
+	if newJob.NotifyOnSuccess != nil {
+		merged.NotifyOnSuccess = newJob.NotifyOnSuccess
+	}
+	if newJob.NotifyOnError != nil {
+		merged.NotifyOnError = newJob.NotifyOnError
+	}
+	if newJob.NotifyOnFailure != nil {
+		merged.NotifyOnFailure = newJob.NotifyOnFailure
+	}
+	if newJob.NotifyOnWarning != nil {
+		merged.NotifyOnWarning = newJob.NotifyOnWarning
+	}
+	if newJob.SuccessExitCodes != nil {
+		merged.SuccessExitCodes = newJob.SuccessExitCodes
+	}
+	if newJob.WarningExitCodes != nil {
+		merged.WarningExitCodes = newJob.WarningExitCodes
+	}
+	if newJob.OutputMatchers != nil {
+		merged.OutputMatchers = newJob.OutputMatchers
+	}
+	if newJob.Calendars != nil {
+		merged.Calendars = newJob.Calendars
+	}
+	return merged
+}
+
 func (self *JobManager) doSetJobCmd(cmd ipc.SetJobCmd) ipc.ICmdResp {
-	common.Logger.Println("doSetJobCmd")
-	// set job
-	rawDup := self.jfile.Raw.Dup()
-	common.Logger.Printf("After dup: %v", rawDup)
-	rawDup.Jobs[cmd.Job.Name] = cmd.Job.JobV3Raw
-
-	// install it
-	common.Logger.Println("Before replaceCurrJobfile")
-	self.replaceCurrJobfile(rawDup)
-	common.Logger.Println("After replaceCurrJobfile")
-
-	return ipc.SetJobCmdResp{Ok: true}
+	jobName := cmd.Job.Name
+	if len(jobName) == 0 {
+		return ipc.NewErrorCmdResp(&common.Error{What: "Job has no name."})
+	}
+
+	edit := func(text string) (string, error) {
+		// find existing job
+		var oldJob *jobfile.JobRaw
+		if len(text) > 0 {
+			raw, err := jobfile.ParseJobfileText(text)
+			if err != nil {
+				return "", err
+			}
+			if job, ok := raw.Jobs[jobName]; ok {
+				oldJob = &job
+			}
+		}
+
+		newJob := cmd.Job.JobV3Raw
+		if cmd.MustBeNew && oldJob != nil {
+			msg := fmt.Sprintf("There is already a job named \"%v\".", jobName)
+			return "", &common.Error{What: msg}
+		}
+		if cmd.Update {
+			if oldJob == nil {
+				return "", &common.Error{What: "No such job."}
+			}
+			newJob = mergeRawJobs(*oldJob, newJob)
+		}
+
+		return jobfile.SetJobInText(text, jobName, newJob)
+	}
+
+	oldText, newText, err := self.editJobfile(edit, cmd.DryRun,
+		cmd.ExpectedJobfile)
+	if err != nil {
+		return ipc.NewErrorCmdResp(err)
+	}
+
+	return ipc.SetJobCmdResp{Ok: true, OldJobfile: oldText, NewJobfile: newText}
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+/*
+Change the jobfile on disk by applying the given function to its
+contents, then reload it.  If the jobfile doesn't exist, the function
+gets "".
+
+The new contents are checked before anything is written.  If dryRun is
+true, nothing is written.  If expected is not nil and the current
+contents are different, nothing is written and an error is returned.
+
+Returns the old and new contents.
+*/
+func (self *JobManager) editJobfile(
+	edit func(text string) (string, error),
+	dryRun bool,
+	expected *string) (string, string, error) {
+
+	oldText, newText, err := self.writeJobfileEdit(edit, dryRun, expected)
+	if err != nil || dryRun {
+		return oldText, newText, err
+	}
+
+	// reload it
+	if err := self.loadJobfile(); err != nil {
+		return "", "", err
+	}
+	return oldText, newText, nil
+}
+
+/*
+Like editJobfile, but doesn't reload the jobfile: the caller must make
+the same change to the current jobs.
+*/
+func (self *JobManager) writeJobfileEdit(
+	edit func(text string) (string, error),
+	dryRun bool,
+	expected *string) (string, string, error) {
+
+	// read current jobfile
+	var oldText string
+	var perms os.FileMode = 0600
+	data, err := ioutil.ReadFile(self.jobfilePath)
+	if err == nil {
+		oldText = string(data)
+		if stat, err := os.Stat(self.jobfilePath); err == nil {
+			perms = stat.Mode().Perm()
+		}
+	} else if !os.IsNotExist(err) {
+		return "", "", err
+	}
+	if expected != nil && *expected != oldText {
+		msg := "The jobfile has changed since the change was prepared. " +
+			"Please try again."
+		return "", "", &common.Error{What: msg}
+	}
+
+	// edit it
+	newText, err := edit(oldText)
+	if err != nil {
+		return "", "", err
+	}
+
+	// check new jobfile
+	raw, err := jobfile.ParseJobfileText(newText)
+	if err != nil {
+		return "", "", &common.Error{What: "Invalid jobfile", Cause: err}
+	}
+	if errs := raw.Check(self.user); len(errs) > 0 {
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return "", "", &common.Error{
+			What: fmt.Sprintf("Invalid jobfile: %v", strings.Join(msgs, "; ")),
+		}
+	}
+
+	if dryRun {
+		return oldText, newText, nil
+	}
+
+	// write it
+	if err := writeFileAtomic(self.jobfilePath, []byte(newText), perms); err != nil {
+		return "", "", err
+	}
+	return oldText, newText, nil
+}
+
+/*
+Replace the file at the given path with a new one with the given
+contents, such that the file at that path always has either the old
+contents or the new.
+*/
+func writeFileAtomic(path string, data []byte, perms os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Chmod(perms); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net"
+	"os"
+
+	"github.com/dshearer/jobber/ipc"
+)
+
+/*
+Serves the event stream (cf. ipc.EventHub) on a Unix socket.
+*/
+type EventServer struct {
+	hub      *ipc.EventHub
+	listener *net.UnixListener
+	sockPath string
+}
+
+func NewEventServer(sockPath string, hub *ipc.EventHub) *EventServer {
+	return &EventServer{hub: hub, sockPath: sockPath}
+}
+
+func (self *EventServer) Launch() error {
+	// make socket
+	os.Remove(self.sockPath)
+	addr, err := net.ResolveUnixAddr("unix", self.sockPath)
+	if err != nil {
+		return err
+	}
+	self.listener, err = net.ListenUnix("unix", addr)
+	if err != nil {
+		return err
+	}
+
+	// serve connections
+	go ipc.ServeEvents(self.listener, self.hub)
+
+	return nil
+}
+
+func (self *EventServer) Stop() {
+	self.listener.Close()
+	os.Remove(self.sockPath)
+}
@@ -226,6 +226,111 @@ func (self *IpcService) DeleteJob(
 	return nil
 }
 
+func (self *IpcService) NextRuns(
+	cmd ipc.NextRunsCmd,
+	resp_p *ipc.NextRunsCmdResp) error {
+
+	// send command
+	respChan := make(chan ipc.ICmdResp, 1)
+	self.cmdChan <- CmdContainer{Cmd: cmd, RespChan: respChan, ServerType: self.serverType}
+
+	// get response
+	resp := <-respChan
+	if err := resp.Error(); err != nil {
+		return err
+	}
+	concreteResp, ok := resp.(ipc.NextRunsCmdResp)
+	if !ok {
+		return &common.Error{What: "Unexpected response type"}
+	}
+	*resp_p = concreteResp
+	return nil
+}
+
+func (self *IpcService) Run(
+	cmd ipc.RunCmd,
+	resp_p *ipc.RunCmdResp) error {
+
+	// send command
+	respChan := make(chan ipc.ICmdResp, 1)
+	self.cmdChan <- CmdContainer{Cmd: cmd, RespChan: respChan, ServerType: self.serverType}
+
+	// get response
+	resp := <-respChan
+	if err := resp.Error(); err != nil {
+		return err
+	}
+	concreteResp, ok := resp.(ipc.RunCmdResp)
+	if !ok {
+		return &common.Error{What: "Unexpected response type"}
+	}
+	*resp_p = concreteResp
+	return nil
+}
+
+func (self *IpcService) Ps(
+	cmd ipc.PsCmd,
+	resp_p *ipc.PsCmdResp) error {
+
+	// send command
+	respChan := make(chan ipc.ICmdResp, 1)
+	self.cmdChan <- CmdContainer{Cmd: cmd, RespChan: respChan, ServerType: self.serverType}
+
+	// get response
+	resp := <-respChan
+	if err := resp.Error(); err != nil {
+		return err
+	}
+	concreteResp, ok := resp.(ipc.PsCmdResp)
+	if !ok {
+		return &common.Error{What: "Unexpected response type"}
+	}
+	*resp_p = concreteResp
+	return nil
+}
+
+func (self *IpcService) Kill(
+	cmd ipc.KillCmd,
+	resp_p *ipc.KillCmdResp) error {
+
+	// send command
+	respChan := make(chan ipc.ICmdResp, 1)
+	self.cmdChan <- CmdContainer{Cmd: cmd, RespChan: respChan, ServerType: self.serverType}
+
+	// get response
+	resp := <-respChan
+	if err := resp.Error(); err != nil {
+		return err
+	}
+	concreteResp, ok := resp.(ipc.KillCmdResp)
+	if !ok {
+		return &common.Error{What: "Unexpected response type"}
+	}
+	*resp_p = concreteResp
+	return nil
+}
+
+func (self *IpcService) Output(
+	cmd ipc.OutputCmd,
+	resp_p *ipc.OutputCmdResp) error {
+
+	// send command
+	respChan := make(chan ipc.ICmdResp, 1)
+	self.cmdChan <- CmdContainer{Cmd: cmd, RespChan: respChan, ServerType: self.serverType}
+
+	// get response
+	resp := <-respChan
+	if err := resp.Error(); err != nil {
+		return err
+	}
+	concreteResp, ok := resp.(ipc.OutputCmdResp)
+	if !ok {
+		return &common.Error{What: "Unexpected response type"}
+	}
+	*resp_p = concreteResp
+	return nil
+}
+
 type IpcServer interface {
 	Launch() error
 	Stop()
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+)
+
+// acquireJobLock gets the lock of the given run's job (which must have
+// one), waiting for it if the job's lock policy says to.  System locks
+// are gotten from jobbermaster; others, from this thread's lock table.
+//
+// Returns a function that releases the lock, or, if the run should be
+// skipped, nil and the reason.  If getting the lock failed, the error is
+// returned too; it is context.Canceled if ctx was cancelled (in which
+// case the run shouldn't be recorded as skipped).
+func (self *JobRunnerThread) acquireJobLock(ctx context.Context,
+	run *RunningJob) (func(), string, error) {
+
+	parentCtx := ctx
+
+	spec := run.Job.Lock
+	self.runsLock.Lock()
+	run.Lock = spec.String()
+	holder := ipc.LockHolder{
+		User:  run.Job.User,
+		Job:   run.Job.Name,
+		RunId: run.RunId,
+	}
+	self.runsLock.Unlock()
+
+	if spec.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, spec.Timeout)
+		defer cancel()
+	}
+
+	var release func()
+	var current *ipc.LockHolder
+	var err error
+	if spec.System {
+		var held *ipc.SocketLock
+		held, current, err = ipc.DialLock(ctx, common.LockSocketPath(gUser),
+			ipc.LockRequest{Name: spec.Name, Holder: holder, Wait: spec.Wait})
+		if held != nil {
+			release = held.Release
+		}
+	} else {
+		release, current, err = self.locks.Acquire(ctx, spec.Name, holder,
+			spec.Wait)
+	}
+
+	if err != nil && parentCtx.Err() != nil {
+		/* (e.g., dialing jobbermaster can fail with a wrapped error) */
+		err = parentCtx.Err()
+	}
+	switch {
+	case current != nil:
+		return nil, fmt.Sprintf("lock %v held by %v", spec,
+			describeLockHolder(current, run.Job.User)), nil
+	case err == context.DeadlineExceeded:
+		return nil, fmt.Sprintf("timed out waiting for lock %v", spec), err
+	case err == context.Canceled:
+		return nil, fmt.Sprintf("cancelled while waiting for lock %v",
+			spec), err
+	case err != nil:
+		return nil, fmt.Sprintf("failed to get lock %v: %v", spec, err),
+			err
+	}
+
+	self.runsLock.Lock()
+	run.LockHeld = true
+	self.runsLock.Unlock()
+	return release, "", nil
+}
+
+func describeLockHolder(holder *ipc.LockHolder, ownUser string) string {
+	if len(holder.User) > 0 && holder.User != ownUser {
+		return fmt.Sprintf("job %v of user %v", holder.Job, holder.User)
+	}
+	return fmt.Sprintf("job %v", holder.Job)
+}
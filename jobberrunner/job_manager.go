@@ -37,6 +37,10 @@ type JobManager struct {
 	jobRunner           JobRunnerThread
 	testJobServer       *testjob.TestJobServer
 	Shell               string
+	Events              *ipc.EventHub
+
+	// one-shot jobs that have run and should be removed from the jobfile
+	firedOneShotJobs []string
 }
 
 func NewJobManager(jobfilePath string) *JobManager {
@@ -60,6 +64,9 @@ func NewJobManager(jobfilePath string) *JobManager {
 
 	jm.testJobServer = testjob.NewTestJobServer(jm.mainThreadCtx, jm.Shell, usr)
 
+	jm.Events = ipc.NewEventHub()
+	jm.jobRunner.Events = jm.Events
+
 	return &jm
 }
 
@@ -104,6 +111,9 @@ func (self *JobManager) replaceCurrJobfile(jfile *jobfile.JobFileRaw) {
 		self.jobRunner.Start(self.jfile.Jobs, self.Shell)
 		return
 	}
+	self.markFiredOneShotJobs()
+	self.countSuccessfulRuns()
+	self.jobRunner.MaxConcurrentJobs = self.jfile.Prefs.MaxConcurrentJobs
 
 	// set loggers
 	if len(self.jfile.Prefs.LogPath) > 0 {
@@ -114,6 +124,11 @@ func (self *JobManager) replaceCurrJobfile(jfile *jobfile.JobFileRaw) {
 
 	// start job-runner thread
 	self.jobRunner.Start(self.jfile.Jobs, self.Shell)
+
+	self.Events.Publish(ipc.Event{
+		Type:    ipc.EventReloaded,
+		NumJobs: len(self.jfile.Jobs),
+	})
 }
 
 func (self *JobManager) openJobfile(path string,
@@ -185,7 +200,7 @@ func (self *JobManager) loadJobfile() error {
 		return nil
 
 	} else {
-		if !self.jobRunner.Running {
+		if !self.jobRunner.isRunning() {
 			// start job-runner thread
 			self.jobRunner.Start(self.jfile.Jobs, self.Shell)
 		}
@@ -202,20 +217,99 @@ func (self *JobManager) handleRunRec(rec *jobfile.RunRec) {
 
 	// record in run log
 	newRunLogEntry := jobfile.RunLogEntry{
-		JobName:  rec.Job.Name,
-		Time:     rec.RunTime,
-		Fate:     rec.Fate,
-		Result:   rec.NewStatus,
-		ExecTime: rec.ExecTime,
+		JobName:       rec.Job.Name,
+		Time:          rec.RunTime,
+		Fate:          rec.Fate,
+		Result:        rec.NewStatus,
+		ExecTime:      rec.ExecTime,
+		Manual:        rec.Manual,
+		RunId:         rec.RunId,
+		SkipReason:    rec.SkipReason,
+		Attempt:       rec.Attempt,
+		QueueDelay:    rec.QueueDelay,
+		LimitExceeded: rec.LimitExceeded,
 	}
 	self.jfile.Prefs.RunLog.Put(newRunLogEntry)
 
+	// keep output
+	if self.jfile.Prefs.RunOutputs != nil &&
+		rec.Fate != common.SubprocFateSkipped {
+		output := jobfile.RunOutput{
+			RunOutputDesc: jobfile.RunOutputDesc{
+				JobName: rec.Job.Name,
+				RunId:   rec.RunId,
+				Time:    rec.RunTime,
+				Fate:    rec.Fate,
+			},
+			Stdout: rec.Stdout,
+			Stderr: rec.Stderr,
+		}
+		if err := self.jfile.Prefs.RunOutputs.Put(output); err != nil {
+			common.ErrLogger.Printf("Failed to store output of %v: %v",
+				rec.Job.Name, err)
+		}
+	}
+
+	// publish events
+	self.Events.Publish(ipc.Event{
+		Type:       ipc.EventRunFinished,
+		Job:        rec.Job.Name,
+		RunId:      rec.RunId,
+		Manual:     rec.Manual,
+		Fate:       rec.Fate.String(),
+		ExecTime:   rec.ExecTime,
+		Status:     rec.NewStatus.String(),
+		SkipReason: rec.SkipReason,
+		Attempt:    rec.Attempt,
+		WillRetry:  rec.WillRetry,
+		Limit:      rec.LimitExceeded,
+	})
+	if rec.WillRetry {
+		/*
+			The job will be run again after its retry delay (cf.
+			jobfile.RetryPolicy.DelayAfter), so its status hasn't
+			changed, the run doesn't count toward MaxRuns, and the
+			sinks are notified only of the final attempt.
+		*/
+		return
+	}
+	if rec.NewStatus != rec.OldStatus {
+		self.Events.Publish(ipc.Event{
+			Type:      ipc.EventStatusChanged,
+			Job:       rec.Job.Name,
+			RunId:     rec.RunId,
+			Status:    rec.NewStatus.String(),
+			OldStatus: rec.OldStatus.String(),
+		})
+		self.runStatusChangeHook(rec)
+	}
+
+	if (rec.Fate == common.SubprocFateSucceeded ||
+		rec.Fate == common.SubprocFateWarning) && !rec.Manual {
+		gJobStateLock.Lock()
+		rec.Job.SuccessfulRuns++
+		gJobStateLock.Unlock()
+	}
+	if rec.Job.At != nil && !rec.Manual &&
+		rec.Fate != common.SubprocFateSkipped {
+		/* cf. one_shot.go */
+		self.firedOneShotJobs = append(self.firedOneShotJobs, rec.Job.Name)
+	}
+
+	if rec.Fate == common.SubprocFateSkipped {
+		/* nothing ran, so there's nothing to notify anyone of */
+		return
+	}
+
 	/* NOTE: error-handler was already applied by the job, if necessary. */
 
 	var sinksToNotify []jobfile.ResultSink
 	if rec.Fate == common.SubprocFateSucceeded {
 		sinksToNotify = append(sinksToNotify, rec.Job.NotifyOnSuccess...)
-	} else if rec.Fate == common.SubprocFateFailed {
+	} else if rec.Fate == common.SubprocFateWarning {
+		sinksToNotify = append(sinksToNotify, rec.Job.NotifyOnWarning...)
+	} else if rec.Fate == common.SubprocFateFailed ||
+		rec.Fate == common.SubprocFateCancelled {
 		sinksToNotify = append(sinksToNotify, rec.Job.NotifyOnError...)
 	}
 	if rec.NewStatus == jobfile.JobFailed {
@@ -226,6 +320,37 @@ func (self *JobManager) handleRunRec(rec *jobfile.RunRec) {
 	}
 }
 
+/*
+Set the SuccessfulRuns field of the jobs that have a maxRuns, from the
+run log, so that the limit holds across restarts.
+*/
+func (self *JobManager) countSuccessfulRuns() {
+	for name, job := range self.jfile.Jobs {
+		if job.MaxRuns == 0 {
+			continue
+		}
+		query := jobfile.RunLogQuery{
+			Jobs: []string{name},
+			Fates: []string{
+				common.SubprocFateSucceeded.String(),
+				common.SubprocFateWarning.String(),
+			},
+			Since: job.ActiveFrom,
+		}
+		entries, _, err := jobfile.QueryRunLog(self.jfile.Prefs.RunLog,
+			query)
+		if err != nil {
+			common.ErrLogger.Printf("Failed to read run log: %v", err)
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.Manual {
+				job.SuccessfulRuns++
+			}
+		}
+	}
+}
+
 func (self *JobManager) runMainThread() {
 	self.mainThreadCtx, self.mainThreadCtxCancel =
 		context.WithCancel(context.Background())
@@ -245,6 +370,7 @@ func (self *JobManager) runMainThread() {
 		if err != nil {
 			common.ErrLogger.Printf("%v", err)
 		}
+		self.removeFiredOneShotJobs()
 
 	Loop:
 		for {
@@ -258,6 +384,7 @@ func (self *JobManager) runMainThread() {
 						"ended prematurely.")
 				}
 				self.handleRunRec(rec)
+				self.removeFiredOneShotJobs()
 
 			case cmd, ok := <-self.CmdChan:
 				if ok {
@@ -267,6 +394,7 @@ func (self *JobManager) runMainThread() {
 						self.mainThreadCtxCancel()
 						break Loop
 					}
+					self.removeFiredOneShotJobs()
 				} else {
 					common.ErrLogger.Println("Command channel was " +
 						"closed.")
@@ -329,6 +457,21 @@ func (self *JobManager) doCmd(
 	case ipc.DeleteJobCmd:
 		return self.doDeleteJobCmd(cmd)
 
+	case ipc.NextRunsCmd:
+		return self.doNextRunsCmd(cmd)
+
+	case ipc.RunCmd:
+		return self.doRunCmd(cmd)
+
+	case ipc.PsCmd:
+		return self.doPsCmd(cmd)
+
+	case ipc.KillCmd:
+		return self.doKillCmd(cmd)
+
+	case ipc.OutputCmd:
+		return self.doOutputCmd(cmd)
+
 	default:
 		return ipc.NewErrorCmdResp(
 			&common.Error{What: fmt.Sprintf("Unknown command: %v", cmd)},
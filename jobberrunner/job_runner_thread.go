@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
 	"github.com/dshearer/jobber/jobfile"
 )
 
@@ -25,10 +29,54 @@ import (
 //
 // At any time, the RunRec channel is closed iff the thread is not running.
 type JobRunnerThread struct {
-	Running            bool
+	Running            bool // guarded by runsLock (cf. isRunning)
 	runRecChan         chan *jobfile.RunRec
 	mainThreadDoneChan chan interface{}
+	ctx                context.Context
 	ctxCancel          context.CancelFunc
+	shell              string
+	jobThreadWaitGroup sync.WaitGroup
+
+	// where to publish run-started events (may be nil)
+	Events *ipc.EventHub
+
+	// max runs executing at once (0 means no limit); read by Start
+	MaxConcurrentJobs int
+	slots             *runSlots
+
+	// in-flight runs (accessed from multiple threads)
+	runsLock sync.Mutex
+	runs     map[string]*RunningJob // keyed by run ID
+
+	// locks of the user's jobs (cf. jobfile.LockSpec)
+	locks *ipc.LockTable
+}
+
+// RunningJob describes an in-flight run of a job.
+type RunningJob struct {
+	Job       *jobfile.Job
+	RunId     string
+	StartTime time.Time
+	Pid       int // 0 if the process hasn't started yet
+	Manual    bool
+	Lock      string // the job's lock ("" if none)
+	LockHeld  bool   // whether the run has its lock (else it's waiting)
+	Queued    bool   // whether the run is waiting for a slot (cf. MaxConcurrentJobs)
+	cancel    context.CancelFunc
+}
+
+// isRunning returns whether the thread is running.  Running is cleared
+// by the thread itself when it ends, so other threads must use this.
+func (self *JobRunnerThread) isRunning() bool {
+	self.runsLock.Lock()
+	defer self.runsLock.Unlock()
+	return self.Running
+}
+
+func (self *JobRunnerThread) setRunning(running bool) {
+	self.runsLock.Lock()
+	self.Running = running
+	self.runsLock.Unlock()
 }
 
 // RunRecChan returns a channel on which records of completed jobs are written.
@@ -45,39 +93,51 @@ func (self *JobRunnerThread) RunRecChan() <-chan *jobfile.RunRec {
 }
 
 func (self *JobRunnerThread) Start(jobs map[string]*jobfile.Job, shell string) {
-	if self.Running {
+	if self.isRunning() {
 		panic("JobRunnerThread already running.")
 	}
 
 	// NOTE: order of these is important:
-	self.Running = true
+	self.setRunning(true)
 	self.runRecChan = make(chan *jobfile.RunRec)
 
 	// make subcontext
 	ctx, cancel := context.WithCancel(context.Background())
+	self.ctx = ctx
 	self.ctxCancel = cancel
+	self.shell = shell
+	if self.locks == nil {
+		self.locks = ipc.NewLockTable()
+	}
+	self.slots = newRunSlots(self.MaxConcurrentJobs)
 
 	// make job queue
 	var jobQ JobQueue
 	jobQ.SetJobs(time.Now(), jobs)
 
+	// watch paths for jobs with "watch" blocks
+	watcherDone := self.startWatcher(ctx, jobs)
+
 	go func() {
 		// NOTE: order of these is important:
 		defer close(self.runRecChan)
-		defer func() { self.Running = false }()
+		defer self.setRunning(false)
 
-		var jobThreadWaitGroup sync.WaitGroup
 		for {
-			var job *jobfile.Job = jobQ.Pop(ctx, time.Now()) // sleeps
+			job, skippedBy := jobQ.Pop(ctx, time.Now()) // sleeps
 
-			if job != nil && !job.Paused {
+			if job != nil && skippedBy != nil {
+				if !job.Paused {
+					common.Logger.Printf("%v: %v (skipped: calendar %v)\n",
+						job.User, job.Cmd, skippedBy)
+					self.recordSkippedRun(job,
+						fmt.Sprintf("calendar %v", skippedBy))
+				}
+
+			} else if job != nil && !job.Paused {
 				// launch thread to run this job
 				common.Logger.Printf("%v: %v\n", job.User, job.Cmd)
-				jobThreadWaitGroup.Add(1)
-				go func(job *jobfile.Job) {
-					defer jobThreadWaitGroup.Done()
-					self.runRecChan <- RunJob(ctx, job, shell, false)
-				}(job)
+				self.launchJob(job, false, nil)
 
 			} else if job == nil {
 				/* We were canceled. */
@@ -91,13 +151,283 @@ func (self *JobRunnerThread) Start(jobs map[string]*jobfile.Job, shell string) {
 			wait for them to stop.
 		*/
 
-		// wait for run threads to stop
-		jobThreadWaitGroup.Wait()
+		// wait for run threads (and the thread that starts them) to stop
+		<-watcherDone
+		self.jobThreadWaitGroup.Wait()
 
 		// close run rec chan
 	}()
 }
 
+// launchJob starts a run of the given job.  extraEnv holds environment
+// variables ("NAME=value") for the job beyond the usual ones.
+func (self *JobRunnerThread) launchJob(job *jobfile.Job, manual bool,
+	extraEnv []string) {
+
+	ctx, cancel := context.WithCancel(self.ctx)
+	shell := self.shell
+	startTime := time.Now()
+	run := &RunningJob{
+		Job:       job,
+		RunId:     jobfile.NewRunId(startTime),
+		StartTime: startTime,
+		Manual:    manual,
+		cancel:    cancel,
+	}
+
+	// remember run
+	self.runsLock.Lock()
+	if self.runs == nil {
+		self.runs = make(map[string]*RunningJob)
+	}
+	self.runs[run.RunId] = run
+	self.runsLock.Unlock()
+
+	self.jobThreadWaitGroup.Add(1)
+	go func() {
+		defer self.jobThreadWaitGroup.Done()
+		defer cancel()
+		onStart := func(pid int) {
+			self.runsLock.Lock()
+			run.Pid = pid
+			runId := run.RunId
+			self.runsLock.Unlock()
+			self.Events.Publish(ipc.Event{
+				Type:   ipc.EventRunStarted,
+				Time:   time.Now(),
+				Job:    job.Name,
+				RunId:  runId,
+				Pid:    pid,
+				Manual: manual,
+			})
+		}
+
+		skip := func(reason string) {
+			common.Logger.Printf("%v: %v (skipped: %v)\n", job.User,
+				job.Cmd, reason)
+			rec := newSkippedRunRec(job, run.RunId, reason)
+			rec.Manual = manual
+
+			// forget run
+			self.runsLock.Lock()
+			delete(self.runs, run.RunId)
+			self.runsLock.Unlock()
+
+			self.runRecChan <- rec
+		}
+
+		// for runs cancelled (cf. CancelRuns) while not executing
+		cancelled := func(attempt int, when string) {
+			common.Logger.Printf("%v: %v (cancelled %v)\n", job.User,
+				job.Cmd, when)
+			rec := newCancelledRunRec(job, run.RunId, attempt)
+			rec.Manual = manual
+
+			// forget run
+			self.runsLock.Lock()
+			delete(self.runs, run.RunId)
+			self.runsLock.Unlock()
+
+			self.runRecChan <- rec
+		}
+
+		/*
+			If the job has a retry policy, it is run again (after a
+			delay) until it succeeds or runs out of attempts.  Each
+			attempt gets its own run ID and RunRec, but "run" stands
+			for all of them, so that they can be cancelled together.
+		*/
+		attempt := 0
+		if job.Retry != nil {
+			attempt = 1
+		}
+
+		// get lock
+		if job.Lock != nil {
+			release, reason, err := self.acquireJobLock(ctx, run)
+			if err == context.Canceled {
+				cancelled(attempt, "while waiting for lock")
+				return
+			} else if release == nil {
+				skip(reason)
+				return
+			}
+			defer release()
+		}
+
+		// check preconditions
+		if job.When != nil {
+			env := append(jobfile.RunEnv(job.Name, run.RunId), extraEnv...)
+			reason, err := job.When.Check(ctx, shell, env)
+			if err != nil {
+				cancelled(attempt, "while checking preconditions")
+				return
+			} else if len(reason) > 0 {
+				skip(reason)
+				return
+			}
+		}
+
+		for {
+			// wait until fewer than MaxConcurrentJobs runs are executing
+			self.runsLock.Lock()
+			run.Queued = true
+			self.runsLock.Unlock()
+			releaseSlot, queueDelay, err := self.slots.Acquire(ctx,
+				job.Priority, run.StartTime)
+			self.runsLock.Lock()
+			run.Queued = false
+			self.runsLock.Unlock()
+			if err != nil {
+				/* cancelled (cf. CancelRuns) before it got a slot */
+				cancelled(attempt, "while queued")
+				return
+			}
+			if queueDelay > 0 {
+				common.Logger.Printf("%v: %v (started after waiting %v "+
+					"for a slot)\n", job.User, job.Cmd, queueDelay)
+			}
+
+			rec := RunJob(ctx, job, run.RunId, attempt, extraEnv, shell, false,
+				onStart)
+			releaseSlot()
+			rec.Manual = manual
+			rec.QueueDelay = queueDelay
+			if !rec.WillRetry {
+				// forget run
+				self.runsLock.Lock()
+				delete(self.runs, run.RunId)
+				self.runsLock.Unlock()
+
+				self.runRecChan <- rec
+				return
+			}
+
+			// wait for next attempt
+			delay := job.Retry.DelayAfter(attempt, rand.Float64())
+			common.Logger.Printf("%v: %v (attempt %v failed; retrying in %v)\n",
+				job.User, job.Cmd, attempt, delay)
+			self.runRecChan <- rec
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				cancelled(attempt, "while waiting to retry")
+				return
+			}
+
+			// re-key run for next attempt
+			attempt++
+			self.runsLock.Lock()
+			delete(self.runs, run.RunId)
+			run.RunId = jobfile.NewRunId(time.Now())
+			run.Pid = 0
+			self.runs[run.RunId] = run
+			self.runsLock.Unlock()
+		}
+	}()
+}
+
+// recordSkippedRun writes a RunRec (with fate SubprocFateSkipped) for a
+// run of the given job that was skipped for the given reason.
+func (self *JobRunnerThread) recordSkippedRun(job *jobfile.Job, reason string) {
+	rec := newSkippedRunRec(job, jobfile.NewRunId(time.Now()), reason)
+
+	/* Don't block the scheduler while the run rec is handled. */
+	self.jobThreadWaitGroup.Add(1)
+	go func() {
+		defer self.jobThreadWaitGroup.Done()
+		self.runRecChan <- rec
+	}()
+}
+
+func newSkippedRunRec(job *jobfile.Job, runId string,
+	reason string) *jobfile.RunRec {
+
+	return &jobfile.RunRec{
+		Job:        job,
+		RunId:      runId,
+		RunTime:    time.Now(),
+		OldStatus:  job.Status,
+		NewStatus:  job.Status,
+		Fate:       common.SubprocFateSkipped,
+		SkipReason: reason,
+	}
+}
+
+// newCancelledRunRec makes a RunRec (with fate SubprocFateCancelled)
+// for a run of the given job that was cancelled before its command
+// started.
+func newCancelledRunRec(job *jobfile.Job, runId string,
+	attempt int) *jobfile.RunRec {
+
+	return &jobfile.RunRec{
+		Job:       job,
+		RunId:     runId,
+		RunTime:   time.Now(),
+		OldStatus: job.Status,
+		NewStatus: job.Status,
+		Fate:      common.SubprocFateCancelled,
+		Attempt:   attempt,
+	}
+}
+
+// RunningJobs returns descriptions of all in-flight runs, ordered by
+// start time.
+func (self *JobRunnerThread) RunningJobs() []RunningJob {
+	self.runsLock.Lock()
+	defer self.runsLock.Unlock()
+
+	runs := make([]RunningJob, 0, len(self.runs))
+	for _, run := range self.runs {
+		runs = append(runs, *run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartTime.Before(runs[j].StartTime)
+	})
+	return runs
+}
+
+// CancelRuns cancels all in-flight runs of the job with the given name.
+// Their RunRecs (with fate SubprocFateCancelled) are written to the
+// RunRec channel as usual.
+//
+// Returns the number of runs cancelled.
+func (self *JobRunnerThread) CancelRuns(jobName string) int {
+	self.runsLock.Lock()
+	defer self.runsLock.Unlock()
+
+	n := 0
+	for _, run := range self.runs {
+		if run.Job.Name == jobName {
+			run.cancel()
+			n++
+		}
+	}
+	return n
+}
+
+// RunNow starts a run of the given job immediately, outside of its
+// schedule.  The run is handled just like a scheduled one (and its
+// RunRec is written to the RunRec channel), except that it is marked
+// as manual.
+//
+// Returns an error if the job is paused or if the thread is not
+// running.  Must be called from the same thread that calls Cancel.
+func (self *JobRunnerThread) RunNow(job *jobfile.Job) error {
+	if !self.isRunning() || self.ctx.Err() != nil {
+		return &common.Error{What: "Job runner is not running."}
+	}
+	if job.Paused {
+		return &common.Error{What: "Job is paused."}
+	}
+
+	common.Logger.Printf("%v: %v (manual)\n", job.User, job.Cmd)
+	self.launchJob(job, true, nil)
+	return nil
+}
+
 // Cancel tells the thread to stop scheduling new jobs.
 // Note that it returns immediately; the thread may still
 // be running.
@@ -110,40 +440,36 @@ func (self *JobRunnerThread) Cancel() {
 func RunJob(
 	ctx context.Context,
 	job *jobfile.Job,
+	runId string,
+	attempt int,
+	extraEnv []string,
 	shell string,
-	testing bool) *jobfile.RunRec {
-
-	rec := &jobfile.RunRec{Job: job, RunTime: time.Now()}
-
-	// run
-	var execResult *common.ExecResult
-	execResult, err :=
-		common.ExecAndWaitContext(ctx, []string{shell, "-c", job.Cmd}, nil)
+	testing bool,
+	onStart func(pid int)) *jobfile.RunRec {
 
-	if err != nil {
-		/* unexpected error while trying to run job */
-		common.ErrLogger.Printf("Unexpected error from ExecAndWaitContext: %v\n", err)
-		rec.Err = err
-		return rec
+	rec := &jobfile.RunRec{
+		Job:       job,
+		RunId:     runId,
+		RunTime:   time.Now(),
+		OldStatus: job.Status,
+		Attempt:   attempt,
 	}
-	defer execResult.Close()
 
-	// get output
-	rec.Stdout, err = execResult.ReadStdout(jobfile.RunRecOutputMaxLen)
-	if err != nil {
-		common.ErrLogger.Printf("Failed to read job's stdout: %v\n", err)
-		rec.Err = err
-		return rec
-	}
-	rec.Stderr, err = execResult.ReadStderr(jobfile.RunRecOutputMaxLen)
+	// load secrets
+	secrets, err := jobfile.LoadSecrets(job.Secrets)
 	if err != nil {
-		common.ErrLogger.Printf("Failed to read job's stderr: %v\n", err)
-		rec.Err = err
-		return rec
+		/* the run fails without running the command */
+		rec.Fate = common.SubprocFateFailed
+		rec.Stderr = []byte(fmt.Sprintf("jobber: %v\n", err))
+	} else {
+		env := append(jobfile.RunEnv(job.Name, runId), extraEnv...)
+		env = append(env, secrets.Env...)
+		if err := runJobCmd(ctx, job, rec, env, shell, onStart,
+			secrets); err != nil {
+			rec.Err = err
+			return rec
+		}
 	}
-
-	// update run rec
-	rec.Fate = execResult.Fate
 	rec.NewStatus = jobfile.JobGood
 	rec.ExecTime = time.Since(rec.RunTime)
 
@@ -152,13 +478,27 @@ func RunJob(
 	}
 
 	// update job
-	switch execResult.Fate {
-	case common.SubprocFateSucceeded:
+	switch rec.Fate {
+	case common.SubprocFateSucceeded, common.SubprocFateWarning:
 		job.Status = jobfile.JobGood
+		job.ConsecutiveFailures = 0
 		break
 	case common.SubprocFateFailed:
+		if job.Retry != nil && attempt < job.Retry.Attempts {
+			/*
+				It will be retried, so don't apply the error-handler
+				yet.
+			*/
+			rec.WillRetry = true
+			break
+		}
 		/* job failed: apply error-handler (which sets job.Status) */
+		job.ConsecutiveFailures++
 		job.ErrorHandler.Handle(job)
+		if job.Status == jobfile.JobFailed {
+			/* (re)start its cooldown */
+			job.FailedTime = time.Now()
+		}
 		break
 	}
 	job.LastRunTime = rec.RunTime
@@ -168,3 +508,71 @@ func RunJob(
 
 	return rec
 }
+
+// runJobCmd runs the job's command and records its output and fate in
+// rec, with the secrets' values redacted from the output.  If the
+// command's limits or sandbox can't be set up, the run fails without
+// running it.
+// Returns an error only if the command couldn't be run for some other
+// reason.
+func runJobCmd(
+	ctx context.Context,
+	job *jobfile.Job,
+	rec *jobfile.RunRec,
+	env []string,
+	shell string,
+	onStart func(pid int),
+	secrets *jobfile.Secrets) error {
+
+	// run
+	execResult, err := common.ExecAndWaitWithParams(ctx, common.ExecParams{
+		Args:    []string{shell, "-c", job.Cmd},
+		Env:     env,
+		OnStart: onStart,
+		Limits:  job.Resources,
+		Sandbox: job.Sandbox,
+	})
+
+	if setupErr, ok := err.(*common.SetupError); ok {
+		/* the run fails without running the command */
+		rec.Fate = common.SubprocFateFailed
+		rec.Stderr = []byte(fmt.Sprintf("jobber: %v\n", setupErr))
+		return nil
+	} else if err != nil {
+		/* unexpected error while trying to run job */
+		common.ErrLogger.Printf("Unexpected error from ExecAndWaitContext: %v\n", err)
+		return err
+	}
+	defer execResult.Close()
+
+	// get output (redacting it before truncating it)
+	readMax := jobfile.RunRecOutputMaxLen + secrets.MaxLen()
+	rec.Stdout, err = execResult.ReadStdout(readMax)
+	if err != nil {
+		common.ErrLogger.Printf("Failed to read job's stdout: %v\n", err)
+		return err
+	}
+	rec.Stderr, err = execResult.ReadStderr(readMax)
+	if err != nil {
+		common.ErrLogger.Printf("Failed to read job's stderr: %v\n", err)
+		return err
+	}
+	rec.Stdout = truncateOutput(secrets.Redact(rec.Stdout))
+	rec.Stderr = truncateOutput(secrets.Redact(rec.Stderr))
+
+	// update run rec
+	rec.Fate = execResult.Fate
+	rec.LimitExceeded = execResult.LimitExceeded
+	if rec.Fate != common.SubprocFateCancelled &&
+		len(rec.LimitExceeded) == 0 {
+		rec.Fate = job.Judge(execResult.ExitCode, rec.Stdout, rec.Stderr)
+	}
+	return nil
+}
+
+func truncateOutput(data []byte) []byte {
+	if len(data) > jobfile.RunRecOutputMaxLen {
+		return data[:jobfile.RunRecOutputMaxLen]
+	}
+	return data
+}
@@ -0,0 +1,622 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/ipc"
+	"github.com/dshearer/jobber/jobfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunNowAndCancelRuns(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	job := &jobfile.Job{
+		Name:         "Sleeper",
+		Cmd:          "sleep 60",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+	}
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	/*
+	 * Call
+	 */
+	require.Nil(t, runner.RunNow(job))
+
+	// wait for it to start
+	var runs []RunningJob
+	for i := 0; i < 100; i++ {
+		runs = runner.RunningJobs()
+		if len(runs) == 1 && runs[0].Pid != 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, 1, len(runs))
+	require.Equal(t, job, runs[0].Job)
+	require.NotEqual(t, 0, runs[0].Pid)
+	require.True(t, runs[0].Manual)
+
+	numCancelled := runner.CancelRuns(job.Name)
+
+	/*
+	 * Test
+	 */
+	require.Equal(t, 1, numCancelled)
+	select {
+	case rec := <-runner.RunRecChan():
+		require.Equal(t, common.SubprocFateCancelled, rec.Fate)
+		require.True(t, rec.Manual)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run was not cancelled")
+	}
+	require.Equal(t, 0, len(runner.RunningJobs()))
+	require.Equal(t, 0, runner.CancelRuns(job.Name))
+}
+
+func TestRunNowPausedJob(t *testing.T) {
+	job := &jobfile.Job{Name: "Paused", Cmd: "true", Paused: true}
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	require.NotNil(t, runner.RunNow(job))
+	require.Equal(t, 0, len(runner.RunningJobs()))
+}
+
+func TestRunNowRetries(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	job := &jobfile.Job{
+		Name:         "Flaky",
+		Cmd:          "exit 1",
+		ErrorHandler: jobfile.StopErrorHandler{},
+		Retry: &jobfile.RetryPolicy{
+			Attempts:   3,
+			Delay:      10 * time.Millisecond,
+			Multiplier: 1,
+		},
+	}
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	/*
+	 * Call
+	 */
+	require.Nil(t, runner.RunNow(job))
+
+	/*
+	 * Test
+	 */
+	runIds := make(map[string]bool)
+	for attempt := 1; attempt <= 3; attempt++ {
+		select {
+		case rec := <-runner.RunRecChan():
+			require.Equal(t, common.SubprocFateFailed, rec.Fate)
+			require.Equal(t, attempt, rec.Attempt)
+			require.Equal(t, attempt < 3, rec.WillRetry)
+			require.True(t, rec.Manual)
+			runIds[rec.RunId] = true
+			if attempt < 3 {
+				/* the error-handler isn't applied until the last attempt */
+				require.Equal(t, jobfile.JobGood, rec.NewStatus)
+			} else {
+				require.Equal(t, jobfile.JobFailed, rec.NewStatus)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Attempt %v didn't finish", attempt)
+		}
+	}
+	require.Equal(t, 3, len(runIds))
+	require.Equal(t, 0, len(runner.RunningJobs()))
+}
+
+func TestCancelRunWaitingToRetry(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	job := &jobfile.Job{
+		Name:         "Flaky",
+		Cmd:          "exit 1",
+		ErrorHandler: jobfile.StopErrorHandler{},
+		Retry: &jobfile.RetryPolicy{
+			Attempts:   3,
+			Delay:      time.Hour,
+			Multiplier: 1,
+		},
+	}
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+	require.Nil(t, runner.RunNow(job))
+	var firstRec *jobfile.RunRec
+	select {
+	case firstRec = <-runner.RunRecChan():
+		require.True(t, firstRec.WillRetry)
+	case <-time.After(10 * time.Second):
+		t.Fatal("First attempt didn't finish")
+	}
+
+	/*
+	 * Call
+	 */
+	require.Equal(t, 1, runner.CancelRuns(job.Name))
+
+	/*
+	 * Test
+	 */
+	select {
+	case rec := <-runner.RunRecChan():
+		require.Equal(t, common.SubprocFateCancelled, rec.Fate)
+		require.Equal(t, firstRec.RunId, rec.RunId)
+		require.Equal(t, 1, rec.Attempt)
+		require.False(t, rec.WillRetry)
+		require.True(t, rec.Manual)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Cancelled run wasn't recorded")
+	}
+	require.Equal(t, 0, len(runner.RunningJobs()))
+}
+
+func TestRunNowWarningExitCode(t *testing.T) {
+	job := &jobfile.Job{
+		Name:             "Warner",
+		Cmd:              "exit 3",
+		ErrorHandler:     jobfile.StopErrorHandler{},
+		WarningExitCodes: []int{3},
+	}
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	require.Nil(t, runner.RunNow(job))
+	select {
+	case rec := <-runner.RunRecChan():
+		require.Equal(t, common.SubprocFateWarning, rec.Fate)
+		require.Equal(t, jobfile.JobGood, rec.NewStatus)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run didn't finish")
+	}
+}
+
+func TestRunNowGuardSkips(t *testing.T) {
+	job := &jobfile.Job{
+		Name:         "Guarded",
+		Cmd:          "true",
+		ErrorHandler: jobfile.StopErrorHandler{},
+		When:         &jobfile.Guard{Cmd: "exit 1"},
+	}
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	require.Nil(t, runner.RunNow(job))
+	select {
+	case rec := <-runner.RunRecChan():
+		require.Equal(t, common.SubprocFateSkipped, rec.Fate)
+		require.Equal(t, "when: cmd exited with 1", rec.SkipReason)
+		require.Equal(t, jobfile.JobGood, rec.NewStatus)
+		require.True(t, rec.Manual)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run didn't finish")
+	}
+	require.Equal(t, 0, len(runner.RunningJobs()))
+}
+
+func TestCancelRunCheckingGuard(t *testing.T) {
+	job := &jobfile.Job{
+		Name:         "Guarded",
+		Cmd:          "true",
+		ErrorHandler: jobfile.StopErrorHandler{},
+		When:         &jobfile.Guard{Cmd: "sleep 60"},
+	}
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+	require.Nil(t, runner.RunNow(job))
+	require.Eventually(t, func() bool {
+		return len(runner.RunningJobs()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, 1, runner.CancelRuns(job.Name))
+	select {
+	case rec := <-runner.RunRecChan():
+		require.Equal(t, common.SubprocFateCancelled, rec.Fate)
+		require.Equal(t, "", rec.SkipReason)
+		require.True(t, rec.Manual)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Cancelled run wasn't recorded")
+	}
+	require.Equal(t, 0, len(runner.RunningJobs()))
+}
+
+func TestRunNowLockSerializes(t *testing.T) {
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	holder := &jobfile.Job{
+		Name:         "Backup",
+		Cmd:          "sleep 1",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		Lock:         &jobfile.LockSpec{Name: "db", Wait: true},
+	}
+	waiter := &jobfile.Job{
+		Name:         "Vacuum",
+		Cmd:          "true",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		Lock:         &jobfile.LockSpec{Name: "db", Wait: true},
+	}
+	skipper := &jobfile.Job{
+		Name:         "Reindex",
+		Cmd:          "true",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		Lock:         &jobfile.LockSpec{Name: "db"},
+	}
+
+	require.Nil(t, runner.RunNow(holder))
+	require.Eventually(t, func() bool {
+		runs := runner.RunningJobs()
+		return len(runs) == 1 && runs[0].LockHeld
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, runner.RunNow(waiter))
+	require.Nil(t, runner.RunNow(skipper))
+
+	var order []string
+	for len(order) < 3 {
+		select {
+		case rec := <-runner.RunRecChan():
+			order = append(order, rec.Job.Name)
+			switch rec.Job.Name {
+			case "Reindex":
+				require.Equal(t, common.SubprocFateSkipped, rec.Fate)
+				require.Equal(t, "lock db held by job Backup",
+					rec.SkipReason)
+			default:
+				require.Equal(t, common.SubprocFateSucceeded, rec.Fate)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Runs didn't finish")
+		}
+	}
+	require.Equal(t, []string{"Reindex", "Backup", "Vacuum"}, order)
+}
+
+func TestCancelRunWaitingForLock(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	holder := &jobfile.Job{
+		Name:         "Backup",
+		Cmd:          "sleep 2",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		Lock:         &jobfile.LockSpec{Name: "db", Wait: true},
+	}
+	waiter := &jobfile.Job{
+		Name:         "Vacuum",
+		Cmd:          "true",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		Lock:         &jobfile.LockSpec{Name: "db", Wait: true},
+	}
+	require.Nil(t, runner.RunNow(holder))
+	require.Eventually(t, func() bool {
+		runs := runner.RunningJobs()
+		return len(runs) == 1 && runs[0].LockHeld
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, runner.RunNow(waiter))
+	require.Eventually(t, func() bool {
+		return len(runner.RunningJobs()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	/*
+	 * Call
+	 */
+	require.Equal(t, 1, runner.CancelRuns(waiter.Name))
+
+	/*
+	 * Test
+	 */
+	select {
+	case rec := <-runner.RunRecChan():
+		require.Equal(t, waiter.Name, rec.Job.Name)
+		require.Equal(t, common.SubprocFateCancelled, rec.Fate)
+		require.True(t, rec.Manual)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Cancelled run wasn't recorded")
+	}
+}
+
+func TestRunNowMaxConcurrentJobs(t *testing.T) {
+	runner := JobRunnerThread{MaxConcurrentJobs: 1}
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	first := &jobfile.Job{
+		Name:         "First",
+		Cmd:          "sleep 0.5",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+	}
+	second := &jobfile.Job{
+		Name:         "Second",
+		Cmd:          "true",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+	}
+	require.Nil(t, runner.RunNow(first))
+	require.Eventually(t, func() bool {
+		runs := runner.RunningJobs()
+		return len(runs) == 1 && runs[0].Pid != 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, runner.RunNow(second))
+	require.Eventually(t, func() bool {
+		for _, run := range runner.RunningJobs() {
+			if run.Job.Name == "Second" {
+				return run.Queued
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for _, name := range []string{"First", "Second"} {
+		select {
+		case rec := <-runner.RunRecChan():
+			require.Equal(t, name, rec.Job.Name)
+			require.Equal(t, common.SubprocFateSucceeded, rec.Fate)
+			if name == "First" {
+				require.Equal(t, time.Duration(0), rec.QueueDelay)
+			} else {
+				require.True(t, rec.QueueDelay > 0)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Runs didn't finish")
+		}
+	}
+}
+
+func TestCancelQueuedRun(t *testing.T) {
+	runner := JobRunnerThread{MaxConcurrentJobs: 1}
+	runner.Start(map[string]*jobfile.Job{}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	first := &jobfile.Job{
+		Name:         "First",
+		Cmd:          "sleep 0.5",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+	}
+	second := &jobfile.Job{
+		Name:         "Second",
+		Cmd:          "true",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+	}
+	require.Nil(t, runner.RunNow(first))
+	require.Eventually(t, func() bool {
+		runs := runner.RunningJobs()
+		return len(runs) == 1 && runs[0].Pid != 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, runner.RunNow(second))
+	require.Eventually(t, func() bool {
+		for _, run := range runner.RunningJobs() {
+			if run.Job.Name == "Second" {
+				return run.Queued
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	/* a queued run that's cancelled counts as cancelled, not skipped */
+	require.Equal(t, 1, runner.CancelRuns("Second"))
+	select {
+	case rec := <-runner.RunRecChan():
+		require.Equal(t, "Second", rec.Job.Name)
+		require.Equal(t, common.SubprocFateCancelled, rec.Fate)
+		require.Equal(t, "", rec.SkipReason)
+		require.True(t, rec.Manual)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run wasn't cancelled")
+	}
+}
+
+func TestRunJobRedactsSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobber-secrets-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	require.Nil(t, ioutil.WriteFile(path, []byte("s3cr3t\n"), 0600))
+
+	job := &jobfile.Job{
+		Name:         "Secretive",
+		Cmd:          "echo token=$API_TOKEN; echo $API_TOKEN >&2",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		Secrets: []jobfile.SecretSpec{
+			{EnvVar: "API_TOKEN", Path: path},
+		},
+	}
+	rec := RunJob(context.Background(), job, "", 0, nil, "/bin/sh", true,
+		nil)
+	require.Nil(t, rec.Err)
+	require.Equal(t, common.SubprocFateSucceeded, rec.Fate)
+	require.Equal(t, "token=[REDACTED]\n", string(rec.Stdout))
+	require.Equal(t, "[REDACTED]\n", string(rec.Stderr))
+
+	/* a secret cut off by the output limit is still redacted */
+	padding := jobfile.RunRecOutputMaxLen - 3
+	job.Cmd = fmt.Sprintf("head -c %v /dev/zero; echo $API_TOKEN", padding)
+	rec = RunJob(context.Background(), job, "", 0, nil, "/bin/sh", true,
+		nil)
+	require.Nil(t, rec.Err)
+	require.Equal(t, jobfile.RunRecOutputMaxLen, len(rec.Stdout))
+	require.Equal(t, "[RE", string(rec.Stdout[padding:]))
+
+	/* a secret file that others can read makes the run fail */
+	require.Nil(t, os.Chmod(path, 0644))
+	rec = RunJob(context.Background(), job, "", 0, nil, "/bin/sh", true,
+		nil)
+	require.Nil(t, rec.Err)
+	require.Equal(t, common.SubprocFateFailed, rec.Fate)
+	require.Contains(t, string(rec.Stderr),
+		"Failed to load secret API_TOKEN")
+	require.Equal(t, 0, len(rec.Stdout))
+}
+
+/*
+The job manager counts a job's successful runs while the job queue (on
+another thread) checks whether the job has run enough times.  Run with
+-race.
+*/
+func TestMaxRunsCountedConcurrently(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	dir, err := ioutil.TempDir("", "jobber-max-runs-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	jm := NewJobManager(filepath.Join(dir, ".jobber"))
+	defer jm.Cancel()
+
+	timeSpec, err := jobfile.ParseFullTimeSpec("*")
+	require.Nil(t, err)
+	job := &jobfile.Job{
+		Name:         "Often",
+		Cmd:          "true",
+		FullTimeSpec: *timeSpec,
+		MaxRuns:      2,
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+	}
+	jm.jfile.Jobs[job.Name] = job
+	jm.jobRunner.Start(jm.jfile.Jobs, "/bin/sh")
+	defer func() {
+		jm.jobRunner.Cancel()
+		for range jm.jobRunner.RunRecChan() {
+		}
+	}()
+
+	/*
+	 * Test
+	 */
+	for i := 0; i < job.MaxRuns; i++ {
+		select {
+		case rec := <-jm.jobRunner.RunRecChan():
+			require.Equal(t, common.SubprocFateSucceeded, rec.Fate)
+			jm.handleRunRec(rec)
+			jm.doListJobsCmd(ipc.ListJobsCmd{})
+			jm.doNextRunsCmd(ipc.NextRunsCmd{Job: job.Name, Count: 1})
+		case <-time.After(10 * time.Second):
+			t.Fatal("Job didn't run")
+		}
+	}
+
+	// it doesn't run again
+	select {
+	case rec := <-jm.jobRunner.RunRecChan():
+		t.Fatalf("Job ran too many times: %v", rec.RunId)
+	case <-time.After(2 * time.Second):
+	}
+	resp := jm.doListJobsCmd(ipc.ListJobsCmd{}).(ipc.ListJobsCmdResp)
+	require.Equal(t, jobfile.JobExpiredStr, resp.Jobs[0].Status)
+}
+
+/*
+The run log keeps only a prefix of long job names; the job manager must
+still count the runs of jobs with such names.
+*/
+func TestMaxRunsCountedForLongJobName(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	dir, err := ioutil.TempDir("", "jobber-max-runs-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	jm := NewJobManager(filepath.Join(dir, ".jobber"))
+	defer jm.Cancel()
+	jm.jfile.Prefs.RunLog, err = jobfile.NewFileRunLog(
+		filepath.Join(dir, "runlog"), 1<<20, 1)
+	require.Nil(t, err)
+
+	job := &jobfile.Job{
+		Name: "nightly-backup-of-the-photo-library-to-the-network-drive-" +
+			"in-the-basement",
+		Cmd:          "true",
+		MaxRuns:      3,
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+	}
+	jm.jfile.Jobs[job.Name] = job
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		err := jm.jfile.Prefs.RunLog.Put(jobfile.RunLogEntry{
+			JobName: job.Name,
+			Time:    now.Add(time.Duration(i-10) * time.Minute),
+			Fate:    common.SubprocFateSucceeded,
+			Result:  jobfile.JobGood,
+		})
+		require.Nil(t, err)
+	}
+
+	/*
+	 * Test
+	 */
+	jm.countSuccessfulRuns()
+
+	/*
+	 * Check
+	 */
+	require.Equal(t, 2, job.SuccessfulRuns)
+}
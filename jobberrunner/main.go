@@ -15,12 +15,16 @@ import (
 
 var gUser *user.User
 var gIpcServer IpcServer
+var gEventServer *EventServer
 var gJobManager *JobManager
 
 func quit(exitCode int) {
 	if gIpcServer != nil {
 		gIpcServer.Stop()
 	}
+	if gEventServer != nil {
+		gEventServer.Stop()
+	}
 	if gJobManager != nil {
 		gJobManager.Cancel()
 		gJobManager.Wait()
@@ -100,6 +104,7 @@ type argsS struct {
 	QuitSocket  *string `arg:"-q" help:"path to quit socket (used by jobbermaster to tell us to quit)"`
 	UnixSocket  *string `arg:"-u" help:"path to Unix socket on which to receive commands"`
 	TcpPort     *uint   `arg:"-p" help:"TCP port on which to receive commands"`
+	EventSocket *string `arg:"-e" help:"path to Unix socket on which to publish events"`
 	TempDir     *string `arg:"-t" help:"Path to dir to use as temp dir"`
 	JobfilePath string  `arg:"positional,required"`
 	Debug       bool    `arg:"-d" default:"false"`
@@ -110,6 +115,8 @@ func (argsS) Version() string {
 }
 
 func main() {
+	/* we may have been run just to apply a job's resource limits */
+	common.RunLimitsHelper()
 
 	// parse args
 	var args argsS
@@ -128,6 +135,10 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Quit socket path cannot be empty\n")
 		quit(1)
 	}
+	if args.EventSocket != nil && len(*args.EventSocket) == 0 {
+		fmt.Fprintf(os.Stderr, "Event socket path cannot be empty\n")
+		quit(1)
+	}
 	if args.TempDir != nil && len(*args.TempDir) == 0 {
 		fmt.Fprintf(os.Stderr, "Temp dir path cannot be empty\n")
 		quit(1)
@@ -184,6 +195,17 @@ func main() {
 		}
 	}
 
+	// make event server
+	if args.EventSocket != nil {
+		gEventServer = NewEventServer(*args.EventSocket, gJobManager.Events)
+		if err := gEventServer.Launch(); err != nil {
+			common.ErrLogger.Printf("Error: %v", err)
+			gEventServer = nil
+			quit(1)
+		}
+		common.Logger.Printf("Publishing events on %v", *args.EventSocket)
+	}
+
 	if args.QuitSocket != nil {
 		// listen for jobbermaster to tell us to quit
 		go quitOnJobbermasterDiscon(*args.QuitSocket)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -164,3 +165,133 @@ func TestNextRunTimeWithRandom(t *testing.T) {
 	 */
 	require.Contains(t, []int{2, 3, 4}, actualRunTime.Hour())
 }
+
+func TestNextTimes(t *testing.T) {
+	for _, testCase := range TestCases {
+		/*
+		 * Set up
+		 */
+		timeSpec, _ := jobfile.ParseFullTimeSpec(testCase.timeSpec)
+		require.NotNil(t, timeSpec)
+
+		/*
+		 * Call
+		 */
+		actual := timeSpec.NextTimes(testCase.startTime,
+			len(testCase.expRunTimes))
+
+		/*
+		 * Test
+		 */
+		msg := fmt.Sprintf("Time spec: %v", testCase.timeSpec)
+		require.Equal(t, testCase.expRunTimes, actual, msg)
+	}
+}
+
+func TestJobQueueCalendars(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	// a job that runs every Monday at 9:00
+	timeSpec, _ := jobfile.ParseFullTimeSpec("0 0 9 * * 1")
+	require.NotNil(t, timeSpec)
+	skipCal := &jobfile.Calendar{
+		Name: "freeze",
+		Ranges: []jobfile.CalendarRange{{
+			Start: myDate(2016, 1, 11, 0, 0, 0),
+			End:   myDate(2016, 1, 12, 0, 0, 0),
+		}},
+	}
+	shiftCal := &jobfile.Calendar{
+		Name: "holidays",
+		Ranges: []jobfile.CalendarRange{{
+			Start: myDate(2016, 1, 18, 0, 0, 0),
+			End:   myDate(2016, 1, 19, 0, 0, 0),
+		}},
+	}
+	job := &jobfile.Job{
+		Name:         "Weekly",
+		FullTimeSpec: *timeSpec,
+		Calendars: jobfile.JobCalendars{
+			Skip:  []*jobfile.Calendar{skipCal},
+			Shift: []*jobfile.Calendar{shiftCal},
+		},
+	}
+	var jobQ JobQueue
+	jobQ.SetJobs(myDate(2016, 1, 5, 0, 0, 0),
+		map[string]*jobfile.Job{job.Name: job})
+	ctx := context.Background()
+
+	/*
+	 * Call & test
+	 */
+	// the run on 11 Jan is skipped, and the one on 18 Jan is shifted
+	require.Equal(t, myDate(2016, 1, 19, 9, 0, 0), *job.NextRunTime)
+	poppedJob, skippedBy := jobQ.Pop(ctx, myDate(2016, 1, 11, 9, 0, 0))
+	require.Equal(t, job, poppedJob)
+	require.Equal(t, skipCal, skippedBy)
+
+	// the run on 18 Jan is moved to 19 Jan
+	require.Equal(t, myDate(2016, 1, 19, 9, 0, 0), *job.NextRunTime)
+	poppedJob, skippedBy = jobQ.Pop(ctx, myDate(2016, 1, 19, 9, 0, 0))
+	require.Equal(t, job, poppedJob)
+	require.Nil(t, skippedBy)
+	require.Nil(t, job.ShiftedRunTime)
+
+	// then it's back to normal
+	require.Equal(t, myDate(2016, 1, 25, 9, 0, 0), *job.NextRunTime)
+}
+
+func TestJobQueueOneShot(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	at := myDate(2016, 1, 4, 2, 0, 0)
+	job := &jobfile.Job{Name: "Once", At: &at}
+	var jobQ JobQueue
+	jobQ.SetJobs(myDate(2016, 1, 1, 0, 0, 0),
+		map[string]*jobfile.Job{job.Name: job})
+
+	/*
+	 * Call
+	 */
+	poppedJob, skippedBy := jobQ.Pop(context.Background(), at)
+
+	/*
+	 * Test
+	 */
+	require.Equal(t, job, poppedJob)
+	require.Nil(t, skippedBy)
+	require.True(t, job.AtFired)
+	require.Nil(t, job.NextRunTime)
+	require.True(t, jobQ.Empty())
+}
+
+func TestJobQueueMaxRuns(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	// a job that runs every Monday at 9:00, at most once
+	timeSpec, _ := jobfile.ParseFullTimeSpec("0 0 9 * * 1")
+	require.NotNil(t, timeSpec)
+	job := &jobfile.Job{Name: "Once", FullTimeSpec: *timeSpec, MaxRuns: 1}
+	var jobQ JobQueue
+	jobQ.SetJobs(myDate(2016, 1, 1, 0, 0, 0),
+		map[string]*jobfile.Job{job.Name: job})
+
+	/*
+	 * Call
+	 */
+	poppedJob, _ := jobQ.Pop(context.Background(), myDate(2016, 1, 4, 9, 0, 0))
+	job.SuccessfulRuns++
+
+	/*
+	 * Test
+	 */
+	require.Equal(t, job, poppedJob)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	poppedJob, _ = jobQ.Pop(ctx, myDate(2016, 1, 11, 9, 0, 0))
+	require.Nil(t, poppedJob)
+	require.True(t, jobQ.Empty())
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+/*
+One-shot jobs (those with an "at" time instead of a time spec) run
+once and are then removed from the jobfile.  If Jobber isn't running at
+a one-shot job's time, the job runs as soon as Jobber starts.
+
+A one-shot job whose run is skipped (e.g., due to a calendar, its
+"when" block, or its lock) is not removed, since it never ran: it stays
+in the jobfile, listed as expired, so that it can be given a new time
+(or run with "jobber run").  It doesn't fire again on its own.
+
+So that a one-shot job doesn't run again if its removal fails, or if
+Jobber restarts before it's removed, we look in the run log when we
+load the jobfile: a one-shot job that has a (non-manual) run since its
+time has already fired, and if that run wasn't skipped, it is removed.
+*/
+
+/*
+Mark the one-shot jobs in the current jobfile that have already fired,
+according to the run log, and remember to remove the ones that ran.
+*/
+func (self *JobManager) markFiredOneShotJobs() {
+	for name, job := range self.jfile.Jobs {
+		if job.At == nil {
+			continue
+		}
+		query := jobfile.RunLogQuery{
+			Jobs:  []string{name},
+			Since: job.At,
+		}
+		entries, _, err := jobfile.QueryRunLog(self.jfile.Prefs.RunLog,
+			query)
+		if err != nil {
+			common.ErrLogger.Printf("Failed to read run log: %v", err)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Manual {
+				continue
+			}
+			job.AtFired = true
+			if entry.Fate != common.SubprocFateSkipped {
+				self.firedOneShotJobs = append(self.firedOneShotJobs, name)
+				break
+			}
+		}
+	}
+}
+
+/*
+Remove the one-shot jobs that have run from the jobfile.  The jobfile
+isn't reloaded (which would cancel the other jobs' runs): we just drop
+the jobs from the current ones.
+*/
+func (self *JobManager) removeFiredOneShotJobs() {
+	for len(self.firedOneShotJobs) > 0 {
+		name := self.firedOneShotJobs[0]
+		self.firedOneShotJobs = self.firedOneShotJobs[1:]
+
+		job, ok := self.jfile.Jobs[name]
+		if !ok || job.At == nil {
+			continue
+		}
+		edit := func(text string) (string, error) {
+			return jobfile.DeleteJobFromText(text, name)
+		}
+		if _, _, err := self.writeJobfileEdit(edit, false, nil); err != nil {
+			common.ErrLogger.Printf("Failed to remove one-shot job %v "+
+				"from jobfile: %v", name, err)
+			continue
+		}
+
+		/*
+			The job queue may still have the job, but since it has fired
+			(and has no pending shifted run), it won't run again.
+		*/
+		gJobStateLock.Lock()
+		job.AtFired = true
+		job.ShiftedRunTime = nil
+		gJobStateLock.Unlock()
+		delete(self.jfile.Jobs, name)
+		common.Logger.Printf("Removed one-shot job %v, which has run", name)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/jobfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkippedOneShotJobIsKept(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	dir, err := ioutil.TempDir("", "jobber-one-shot-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	jm := NewJobManager(filepath.Join(dir, ".jobber"))
+	defer jm.Cancel()
+
+	at := time.Now().Add(-time.Minute)
+	job := &jobfile.Job{
+		Name:         "Once",
+		Cmd:          "true",
+		At:           &at,
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		AtFired:      true,
+	}
+	jm.jfile.Jobs[job.Name] = job
+
+	/*
+	 * Test
+	 */
+
+	// a skipped run doesn't use up the job...
+	jm.handleRunRec(newSkippedRunRec(job, jobfile.NewRunId(time.Now()),
+		"calendar holidays"))
+	require.Equal(t, 0, len(jm.firedOneShotJobs))
+	require.Equal(t, jobfile.JobExpiredStr, job.ActivityString(time.Now()))
+
+	// ...even after a reload
+	job.AtFired = false
+	jm.markFiredOneShotJobs()
+	require.True(t, job.AtFired)
+	require.Equal(t, 0, len(jm.firedOneShotJobs))
+
+	// but a real run does
+	rec := &jobfile.RunRec{
+		Job:     job,
+		RunId:   jobfile.NewRunId(time.Now()),
+		RunTime: time.Now(),
+		Fate:    common.SubprocFateSucceeded,
+	}
+	jm.handleRunRec(rec)
+	require.Equal(t, []string{"Once"}, jm.firedOneShotJobs)
+
+	jm.firedOneShotJobs = nil
+	job.AtFired = false
+	jm.markFiredOneShotJobs()
+	require.True(t, job.AtFired)
+	require.Equal(t, []string{"Once"}, jm.firedOneShotJobs)
+}
+
+func TestRemovingOneShotJobKeepsOtherRuns(t *testing.T) {
+	/*
+	 * Set up
+	 */
+	dir, err := ioutil.TempDir("", "jobber-one-shot-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	jobfilePath := filepath.Join(dir, ".jobber")
+	text := `version: 1.4
+jobs:
+    Long:
+        cmd: sleep 1
+        time: 0 0 0 1 1 *
+    Once:
+        cmd: "true"
+        at: 2099-01-01T00:00:00
+`
+	require.Nil(t, ioutil.WriteFile(jobfilePath, []byte(text), 0600))
+	jm := NewJobManager(jobfilePath)
+	require.Nil(t, jm.loadJobfile())
+	defer func() {
+		jm.jobRunner.Cancel()
+		for range jm.jobRunner.RunRecChan() {
+		}
+	}()
+	require.Equal(t, 2, len(jm.jfile.Jobs))
+
+	require.Nil(t, jm.jobRunner.RunNow(jm.jfile.Jobs["Long"]))
+	require.Eventually(t, func() bool {
+		runs := jm.jobRunner.RunningJobs()
+		return len(runs) == 1 && runs[0].Pid != 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	/*
+	 * Call
+	 */
+	jm.firedOneShotJobs = []string{"Once"}
+	jm.removeFiredOneShotJobs()
+
+	/*
+	 * Test
+	 */
+	_, ok := jm.jfile.Jobs["Once"]
+	require.False(t, ok)
+	data, err := ioutil.ReadFile(jobfilePath)
+	require.Nil(t, err)
+	require.NotContains(t, string(data), "Once")
+
+	select {
+	case rec := <-jm.jobRunner.RunRecChan():
+		require.Equal(t, "Long", rec.Job.Name)
+		require.Equal(t, common.SubprocFateSucceeded, rec.Fate)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run didn't finish")
+	}
+}
+
+func TestFiredOneShotJobFoundInFileRunLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobber-one-shot-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	jm := NewJobManager(filepath.Join(dir, ".jobber"))
+	defer jm.Cancel()
+	jm.jfile.Prefs.RunLog, err = jobfile.NewFileRunLog(
+		filepath.Join(dir, "runlog"), 1<<20, 1)
+	require.Nil(t, err)
+
+	/* the default name given by "jobber at" */
+	at := time.Now().Add(-time.Minute)
+	job := &jobfile.Job{
+		Name:         "at-" + at.Format("20060102-150405"),
+		Cmd:          "true",
+		At:           &at,
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		AtFired:      true,
+	}
+	jm.jfile.Jobs[job.Name] = job
+	jm.handleRunRec(&jobfile.RunRec{
+		Job:     job,
+		RunId:   jobfile.NewRunId(time.Now()),
+		RunTime: time.Now(),
+		Fate:    common.SubprocFateSucceeded,
+	})
+
+	// as after a restart
+	jm.firedOneShotJobs = nil
+	job.AtFired = false
+	jm.markFiredOneShotJobs()
+	require.True(t, job.AtFired)
+	require.Equal(t, []string{job.Name}, jm.firedOneShotJobs)
+}
@@ -3,41 +3,66 @@ package main
 import (
 	"container/heap"
 	"context"
+	"sync"
 	"time"
 
+	"github.com/dshearer/jobber/common"
 	"github.com/dshearer/jobber/jobfile"
 )
 
+/*
+ * Guards the parts of the jobs' state that the job queue (on the
+ * job-runner thread) shares with other threads, such as the job
+ * manager's: NextRunTime, ShiftedRunTime, AtFired, and SuccessfulRuns.
+ */
+var gJobStateLock sync.Mutex
+
 func nextRunTime(job *jobfile.Job, now time.Time) *time.Time {
-	/*
-	 * We test every second from now till 2 years from now,
-	 * looking for a time that satisfies the job's schedule
-	 * criteria.
-	 */
-
-	var year time.Duration = time.Hour * 24 * 365
-	var max time.Time = now.Add(2 * year)
-	for next := now; next.Before(max); next = next.Add(time.Second) {
-		if job.FullTimeSpec.Satisfied(next) {
-			return &next
-		}
-	}
+	return job.NextTime(now)
+}
 
-	return nil
+/*
+ * A job in the queue.  wakeTime is the earlier of nextFire (the job's
+ * next time according to its time spec) and the job's pending shifted
+ * run, if any.
+ */
+type queueItem struct {
+	job      *jobfile.Job
+	nextFire *time.Time
+	wakeTime time.Time
+}
+
+func newQueueItem(job *jobfile.Job, now time.Time) *queueItem {
+	item := &queueItem{job: job, nextFire: nextRunTime(job, now)}
+	job.NextRunTime = nil
+	if times := job.NextRunTimes(now, 1); len(times) > 0 {
+		job.NextRunTime = &times[0]
+	}
+	switch {
+	case item.nextFire == nil && job.ShiftedRunTime == nil:
+		return nil
+	case item.nextFire == nil:
+		item.wakeTime = *job.ShiftedRunTime
+	case job.ShiftedRunTime != nil && job.ShiftedRunTime.Before(*item.nextFire):
+		item.wakeTime = *job.ShiftedRunTime
+	default:
+		item.wakeTime = *item.nextFire
+	}
+	return item
 }
 
 /*
  * jobQueueImpl is a priority queue containing Jobs that sorts
- * them by next run time.
+ * them by wake time.
  */
-type jobQueueImpl []*jobfile.Job // implements heap.Interface
+type jobQueueImpl []*queueItem // implements heap.Interface
 
 func (q jobQueueImpl) Len() int {
 	return len(q)
 }
 
 func (q jobQueueImpl) Less(i, j int) bool {
-	return q[i].NextRunTime.Before(*q[j].NextRunTime)
+	return q[i].wakeTime.Before(q[j].wakeTime)
 }
 
 func (q jobQueueImpl) Swap(i, j int) {
@@ -45,7 +70,7 @@ func (q jobQueueImpl) Swap(i, j int) {
 }
 
 func (q *jobQueueImpl) Push(x interface{}) {
-	*q = append(*q, x.(*jobfile.Job))
+	*q = append(*q, x.(*queueItem))
 }
 
 func (q *jobQueueImpl) Pop() interface{} {
@@ -71,10 +96,11 @@ func (jq *JobQueue) SetJobs(now time.Time, jobs map[string]*jobfile.Job) {
 	jq.q = make(jobQueueImpl, 0)
 	heap.Init(&jq.q)
 
+	gJobStateLock.Lock()
+	defer gJobStateLock.Unlock()
 	for _, job := range jobs {
-		job.NextRunTime = nextRunTime(job, now)
-		if job.NextRunTime != nil {
-			heap.Push(&jq.q, job)
+		if item := newQueueItem(job, now); item != nil {
+			heap.Push(&jq.q, item)
 		}
 	}
 }
@@ -87,22 +113,26 @@ func (jq *JobQueue) Empty() bool {
  * Get the next job to run, after sleeping until the time it's supposed
  * to run.
  *
- * @return The next job to run, or nil if the context has been canceled.
+ * If the job's time falls in one of its skip calendars, the job is
+ * returned along with that calendar: the caller should record the
+ * skipped run rather than run the job.  If the job's time falls in one
+ * of its shift calendars, the run is moved to a later day.
+ *
+ * @return The next job to run (or skip), or nil if the context has been
+ * canceled.
  */
-func (jq *JobQueue) Pop(ctx context.Context, now time.Time) *jobfile.Job {
+func (jq *JobQueue) Pop(ctx context.Context, now time.Time) (
+	*jobfile.Job, *jobfile.Calendar) {
+
 	if jq.Empty() {
 		// just wait till the context has been canceled
 		<-ctx.Done()
-		return nil
+		return nil, nil
 
 	} else {
 		// get next-scheduled job
-		job := heap.Pop(&jq.q).(*jobfile.Job)
-
-		// var timeFmt = "Jan _2 15:04:05"
-
-		// common.Logger.Printf("Next job to run is %v, at %v.", job.Name,
-		// 	job.NextRunTime.Format(timeFmt))
+		item := heap.Pop(&jq.q).(*queueItem)
+		job := item.job
 
 		/*
 			Golang has a bug in its time package.  We must avoid using most of the
@@ -110,8 +140,8 @@ func (jq *JobQueue) Pop(ctx context.Context, now time.Time) *jobfile.Job {
 		*/
 
 		// sleep till it's time to run it
-		for now.UnixNano() < job.NextRunTime.UnixNano() {
-			nanoDiff := job.NextRunTime.UnixNano() - now.UnixNano()
+		for now.UnixNano() < item.wakeTime.UnixNano() {
+			nanoDiff := item.wakeTime.UnixNano() - now.UnixNano()
 			sleepDur := time.Duration(nanoDiff) * time.Nanosecond
 
 			afterChan := time.After(sleepDur)
@@ -119,20 +149,64 @@ func (jq *JobQueue) Pop(ctx context.Context, now time.Time) *jobfile.Job {
 			case now = <-afterChan:
 			case <-ctx.Done():
 				// abort!
-				heap.Push(&jq.q, job)
-				return nil
+				heap.Push(&jq.q, item)
+				return nil, nil
+			}
+		}
+
+		gJobStateLock.Lock()
+
+		// is it time for a shifted run?
+		run := false
+		if job.ShiftedRunTime != nil &&
+			job.ShiftedRunTime.UnixNano() <= item.wakeTime.UnixNano() {
+			job.ShiftedRunTime = nil
+			run = true
+		}
+
+		// is it time for a scheduled run?
+		var skippedBy *jobfile.Calendar
+		if item.nextFire != nil &&
+			item.nextFire.UnixNano() <= item.wakeTime.UnixNano() {
+			fireTime := *item.nextFire
+			if job.At != nil && !job.Paused {
+				/*
+					A paused one-shot job stays due (and so is checked
+					every second) until it's resumed.
+				*/
+				job.AtFired = true
+			}
+			if cal := job.SkippingCalendar(fireTime); cal != nil {
+				skippedBy = cal
+			} else if cal := job.ShiftingCalendar(fireTime); cal != nil {
+				shifted := job.ShiftTime(fireTime)
+				if shifted == nil {
+					common.ErrLogger.Printf("%v: no day to which to shift "+
+						"run at %v (calendar %v)", job.Name, fireTime, cal)
+				} else if job.ShiftedRunTime == nil ||
+					shifted.Before(*job.ShiftedRunTime) {
+					job.ShiftedRunTime = shifted
+				}
+			} else {
+				run = true
 			}
 		}
 
 		// schedule this job's next run
-		job.NextRunTime = nextRunTime(job, now.Add(time.Second))
-		if job.NextRunTime != nil {
-			heap.Push(&jq.q, job)
+		if next := newQueueItem(job, now.Add(time.Second)); next != nil {
+			heap.Push(&jq.q, next)
 		}
 
 		// decide whether we really should run this job
-		if job.ShouldRun() {
-			return job
+		if run && job.RunsExhausted() {
+			/* it succeeded enough times while this run was pending */
+			run = false
+		}
+		gJobStateLock.Unlock()
+		if run && job.ShouldRun(now) {
+			return job, nil
+		} else if !run && skippedBy != nil && job.Status != jobfile.JobFailed {
+			return job, skippedBy
 		} else {
 			// skip this job
 			return jq.Pop(ctx, now)
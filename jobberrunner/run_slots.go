@@ -0,0 +1,135 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// runSlots limits how many runs may execute at once (cf.
+// jobfile.UserPrefs.MaxConcurrentJobs).  Runs that are over the limit
+// wait in a queue, ordered by priority (highest first) and then by the
+// time they were meant to start (earliest first).
+type runSlots struct {
+	lock    sync.Mutex
+	max     int // 0 means no limit
+	running int
+	queue   runQueue
+	seq     uint64
+}
+
+type queuedRun struct {
+	priority  int
+	startTime time.Time
+	seq       uint64        // for FIFO order among equals
+	granted   chan struct{} // closed when the run gets a slot
+	index     int           // in the heap; -1 once removed
+}
+
+func newRunSlots(max int) *runSlots {
+	return &runSlots{max: max}
+}
+
+// Acquire waits for a free slot for a run with the given priority that
+// was meant to start at startTime.  Returns a function that frees the
+// slot and how long the run waited, or ctx.Err() if ctx is done first.
+func (self *runSlots) Acquire(ctx context.Context, priority int,
+	startTime time.Time) (func(), time.Duration, error) {
+
+	queuedAt := time.Now()
+
+	self.lock.Lock()
+	if self.max <= 0 || (self.running < self.max && self.queue.Len() == 0) {
+		self.running++
+		self.lock.Unlock()
+		return self.releaser(), 0, nil
+	}
+	self.seq++
+	run := &queuedRun{
+		priority:  priority,
+		startTime: startTime,
+		seq:       self.seq,
+		granted:   make(chan struct{}),
+	}
+	heap.Push(&self.queue, run)
+	self.lock.Unlock()
+
+	select {
+	case <-run.granted:
+		return self.releaser(), time.Since(queuedAt), nil
+
+	case <-ctx.Done():
+		self.lock.Lock()
+		select {
+		case <-run.granted:
+			/* we got it just now */
+			self.lock.Unlock()
+			self.release()
+		default:
+			heap.Remove(&self.queue, run.index)
+			self.lock.Unlock()
+		}
+		return nil, 0, ctx.Err()
+	}
+}
+
+func (self *runSlots) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(self.release)
+	}
+}
+
+func (self *runSlots) release() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.queue.Len() == 0 {
+		self.running--
+		return
+	}
+
+	/* hand the slot to the next run */
+	next := heap.Pop(&self.queue).(*queuedRun)
+	close(next.granted)
+}
+
+// runQueue implements heap.Interface.
+type runQueue []*queuedRun
+
+func (self runQueue) Len() int {
+	return len(self)
+}
+
+func (self runQueue) Less(i, j int) bool {
+	if self[i].priority != self[j].priority {
+		return self[i].priority > self[j].priority
+	}
+	if !self[i].startTime.Equal(self[j].startTime) {
+		return self[i].startTime.Before(self[j].startTime)
+	}
+	return self[i].seq < self[j].seq
+}
+
+func (self runQueue) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+	self[i].index = i
+	self[j].index = j
+}
+
+func (self *runQueue) Push(x interface{}) {
+	run := x.(*queuedRun)
+	run.index = len(*self)
+	*self = append(*self, run)
+}
+
+func (self *runQueue) Pop() interface{} {
+	old := *self
+	n := len(old)
+	run := old[n-1]
+	old[n-1] = nil
+	run.index = -1
+	*self = old[:n-1]
+	return run
+}
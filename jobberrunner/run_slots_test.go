@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSlotsOrder(t *testing.T) {
+	slots := newRunSlots(1)
+	release, delay, err := slots.Acquire(context.Background(), 0, time.Now())
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(0), delay)
+
+	/*
+		Queue runs with various priorities and start times.  They should
+		get the slot in order of priority, then start time.
+	*/
+	base := time.Now()
+	queued := []struct {
+		name      string
+		priority  int
+		startTime time.Time
+	}{
+		{"low", -1, base},
+		{"late", 0, base.Add(2 * time.Second)},
+		{"early", 0, base.Add(time.Second)},
+		{"high", 5, base.Add(3 * time.Second)},
+	}
+	order := make(chan string, len(queued))
+	for _, q := range queued {
+		go func(name string, priority int, startTime time.Time) {
+			release, delay, err := slots.Acquire(context.Background(),
+				priority, startTime)
+			require.Nil(t, err)
+			require.True(t, delay > 0)
+			order <- name
+			release()
+		}(q.name, q.priority, q.startTime)
+	}
+	require.Eventually(t, func() bool {
+		slots.lock.Lock()
+		defer slots.lock.Unlock()
+		return slots.queue.Len() == len(queued)
+	}, time.Second, time.Millisecond)
+
+	release()
+	var got []string
+	for range queued {
+		got = append(got, <-order)
+	}
+	require.Equal(t, []string{"high", "early", "late", "low"}, got)
+}
+
+func TestRunSlotsCancel(t *testing.T) {
+	slots := newRunSlots(1)
+	release, _, err := slots.Acquire(context.Background(), 0, time.Now())
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	_, _, err = slots.Acquire(ctx, 0, time.Now())
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Equal(t, 0, slots.queue.Len())
+
+	/* the slot is free again once released */
+	release()
+	release() // must be harmless
+	release, delay, err := slots.Acquire(context.Background(), 0, time.Now())
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(0), delay)
+	release()
+	require.Equal(t, 0, slots.running)
+}
+
+func TestRunSlotsNoLimit(t *testing.T) {
+	slots := newRunSlots(0)
+	for i := 0; i < 10; i++ {
+		_, delay, err := slots.Acquire(context.Background(), 0, time.Now())
+		require.Nil(t, err)
+		require.Equal(t, time.Duration(0), delay)
+	}
+}
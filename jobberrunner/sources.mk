@@ -2,22 +2,40 @@ RUNNER_SOURCES := \
 	jobberrunner/cmd_cat.go \
 	jobberrunner/cmd_delete_job.go \
 	jobberrunner/cmd_init.go \
+	jobberrunner/cmd_kill.go \
 	jobberrunner/cmd_list_jobs.go \
 	jobberrunner/cmd_log.go \
+	jobberrunner/cmd_next_runs.go \
+	jobberrunner/cmd_output.go \
 	jobberrunner/cmd_pause.go \
+	jobberrunner/cmd_ps.go \
 	jobberrunner/cmd_reload.go \
 	jobberrunner/cmd_resume.go \
+	jobberrunner/cmd_run.go \
 	jobberrunner/cmd_set_job.go \
 	jobberrunner/cmd_test_job.go \
+	jobberrunner/edit_jobfile.go \
+	jobberrunner/event_server.go \
 	jobberrunner/ipc_server.go \
+	jobberrunner/job_locks.go \
 	jobberrunner/job_manager.go \
 	jobberrunner/job_runner_thread.go \
 	jobberrunner/main.go \
+	jobberrunner/one_shot.go \
 	jobberrunner/queue.go \
+	jobberrunner/run_slots.go \
+	jobberrunner/status_hook.go \
 	jobberrunner/sources.mk \
 	jobberrunner/testjob/test_job_server.go \
 	jobberrunner/testjob/test_job_thread.go \
+	jobberrunner/watcher.go \
+	jobberrunner/watcher_linux.go \
+	jobberrunner/watcher_other.go \
 
 RUNNER_TEST_SOURCES := \
 	jobberrunner/cmd_init_test.go \
-	jobberrunner/next_run_time_test.go
+	jobberrunner/job_runner_thread_test.go \
+	jobberrunner/next_run_time_test.go \
+	jobberrunner/one_shot_test.go \
+	jobberrunner/run_slots_test.go \
+	jobberrunner/watcher_test.go
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+/*
+A job's "onStatusChange" command is run (by the shell, like the job
+itself) whenever a run changes the job's status --- e.g., when its error
+handler marks it as Failed, or when it recovers.  Besides the variables
+given to the job, it gets these:
+*/
+const (
+	OldStatusEnvVar = "JOBBER_OLD_STATUS"
+	NewStatusEnvVar = "JOBBER_NEW_STATUS"
+)
+
+/*
+Run rec's job's onStatusChange command, if it has one.  This doesn't
+wait for the command to finish.
+*/
+func (self *JobManager) runStatusChangeHook(rec *jobfile.RunRec) {
+	job := rec.Job
+	if len(job.OnStatusChange) == 0 {
+		return
+	}
+	params := common.ExecParams{
+		Args: []string{self.Shell, "-c", job.OnStatusChange},
+		Env: append(jobfile.RunEnv(job.Name, rec.RunId),
+			OldStatusEnvVar+"="+rec.OldStatus.String(),
+			NewStatusEnvVar+"="+rec.NewStatus.String()),
+	}
+	go func() {
+		execResult, err := common.ExecAndWaitWithParams(nil, params)
+		if err != nil {
+			common.ErrLogger.Printf("Failed to run onStatusChange for %v: %v",
+				job.Name, err)
+			return
+		}
+		defer execResult.Close()
+		if execResult.Fate != common.SubprocFateSucceeded {
+			stderrBytes, _ := execResult.ReadStderr(jobfile.RunRecOutputMaxLen)
+			stderr, _ := jobfile.SafeBytesToStr(stderrBytes)
+			common.ErrLogger.Printf("onStatusChange for %v failed: %v",
+				job.Name, stderr)
+		}
+	}()
+}
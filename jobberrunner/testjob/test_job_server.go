@@ -113,6 +113,8 @@ func (self *TestJobServer) serve(job *jobfile.Job, listener net.Listener, sockDi
 	switch result.Fate {
 	case common.SubprocFateSucceeded:
 		conn.Write([]byte("Job succeeded\n"))
+	case common.SubprocFateWarning:
+		conn.Write([]byte("Job succeeded with a warning\n"))
 	case common.SubprocFateFailed:
 		conn.Write([]byte("Job failed\n"))
 	case common.SubprocFateCancelled:
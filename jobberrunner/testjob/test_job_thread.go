@@ -1,8 +1,10 @@
 package testjob
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"os"
 	"os/exec"
 	"time"
 
@@ -20,6 +22,27 @@ type testJobThread struct {
 
 	runRecChan chan *jobfile.RunRec
 	cancelChan chan interface{}
+
+	// copies of the output (for the job's output matchers)
+	stdoutCopy cappedBuffer
+	stderrCopy cappedBuffer
+}
+
+// cappedBuffer keeps the first jobfile.RunRecOutputMaxLen bytes written
+// to it.
+type cappedBuffer struct {
+	bytes.Buffer
+}
+
+func (self *cappedBuffer) Write(p []byte) (int, error) {
+	if room := jobfile.RunRecOutputMaxLen - self.Len(); room > 0 {
+		if len(p) > room {
+			self.Buffer.Write(p[:room])
+		} else {
+			self.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
 }
 
 // Run spawns the thread that runs the job.
@@ -27,22 +50,38 @@ func (self *testJobThread) Run(ctx context.Context, job *jobfile.Job, shell stri
 	// make channels
 	self.runRecChan = make(chan *jobfile.RunRec)
 
+	// load secrets
+	/*
+		(The output goes straight to the user who is testing the job,
+		so it is not redacted.)
+	*/
+	secrets, err := jobfile.LoadSecrets(job.Secrets)
+	if err != nil {
+		return err
+	}
+
 	// make subproc
+	startTime := time.Now()
+	runId := jobfile.NewRunId(startTime)
 	cmd := exec.CommandContext(ctx, shell, "-c", job.Cmd)
-	cmd.Stdout = self.Stdout
-	cmd.Stderr = self.Stderr
+	cmd.Stdout = io.MultiWriter(self.Stdout, &self.stdoutCopy)
+	cmd.Stderr = io.MultiWriter(self.Stderr, &self.stderrCopy)
+	cmd.Env = append(os.Environ(), jobfile.RunEnv(job.Name, runId)...)
+	cmd.Env = append(cmd.Env, secrets.Env...)
 
 	// launch subproc
 	if err := cmd.Start(); err != nil {
 		return err
 	}
-	go self.runThread(ctx, job, cmd)
+	go self.runThread(ctx, job, runId, startTime, cmd)
 
 	return nil
 }
 
-func (self *testJobThread) runThread(ctx context.Context, job *jobfile.Job, cmd *exec.Cmd) {
-	rec := jobfile.RunRec{Job: job, RunTime: time.Now()}
+func (self *testJobThread) runThread(ctx context.Context, job *jobfile.Job,
+	runId string, startTime time.Time, cmd *exec.Cmd) {
+
+	rec := jobfile.RunRec{Job: job, RunId: runId, RunTime: startTime}
 
 	// clean up
 	defer close(self.runRecChan)
@@ -68,12 +107,11 @@ func (self *testJobThread) runThread(ctx context.Context, job *jobfile.Job, cmd
 	}
 
 	// report result
-	if waitErr == nil {
-		rec.Fate = common.SubprocFateSucceeded
-	} else if ctx.Err() != nil {
+	if ctx.Err() != nil && waitErr != nil {
 		rec.Fate = common.SubprocFateCancelled
 	} else {
-		rec.Fate = common.SubprocFateFailed
+		rec.Fate = job.Judge(cmd.ProcessState.ExitCode(),
+			self.stdoutCopy.Bytes(), self.stderrCopy.Bytes())
 	}
 	rec.NewStatus = jobfile.JobGood
 	rec.ExecTime = time.Since(rec.RunTime)
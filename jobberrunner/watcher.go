@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+/*
+Jobs with a "watch" block are run when their paths change.  The
+platform-specific watchPaths reports the changes; here we debounce them
+and launch the jobs.
+*/
+
+// A path to watch, for watchPaths.
+type watchedPath struct {
+	id     int // index of the job in the caller's list
+	path   string
+	events jobfile.WatchEvents
+}
+
+// A change to a watched path, reported by watchPaths.
+type pathChange struct {
+	id   int    // cf. watchedPath.id
+	path string // the file that changed
+}
+
+// startWatcher starts a thread that runs the jobs that have "watch"
+// blocks when their paths change.  Returns a channel that is closed when
+// the thread has stopped (after ctx is cancelled).
+func (self *JobRunnerThread) startWatcher(ctx context.Context,
+	jobs map[string]*jobfile.Job) <-chan interface{} {
+
+	done := make(chan interface{})
+
+	var watchedJobs []*jobfile.Job
+	var paths []watchedPath
+	for _, job := range jobs {
+		if job.Watch == nil {
+			continue
+		}
+		paths = append(paths, watchedPath{
+			id:     len(watchedJobs),
+			path:   job.Watch.Path,
+			events: job.Watch.Events,
+		})
+		watchedJobs = append(watchedJobs, job)
+	}
+	if len(paths) == 0 {
+		close(done)
+		return done
+	}
+
+	changes := make(chan pathChange)
+	watchDone := make(chan interface{})
+	go func() {
+		defer close(watchDone)
+		if err := watchPaths(ctx, paths, changes); err != nil {
+			common.ErrLogger.Printf("Cannot watch paths: %v", err)
+		}
+	}()
+
+	go func() {
+		defer close(done)
+
+		type pendingRun struct {
+			timer *time.Timer
+			path  string
+		}
+		pending := make(map[int]*pendingRun)
+		fire := make(chan int)
+		defer func() {
+			for _, p := range pending {
+				p.timer.Stop()
+			}
+		}()
+
+		for {
+			select {
+			case <-watchDone:
+				return
+
+			case change := <-changes:
+				/* wait for the changes to stop */
+				id := change.id
+				debounce := watchedJobs[id].Watch.Debounce
+				if p, ok := pending[id]; ok {
+					p.timer.Reset(debounce)
+					p.path = change.path
+					continue
+				}
+				pending[id] = &pendingRun{
+					path: change.path,
+					timer: time.AfterFunc(debounce, func() {
+						select {
+						case fire <- id:
+						case <-ctx.Done():
+						}
+					}),
+				}
+
+			case id := <-fire:
+				p, ok := pending[id]
+				if !ok {
+					continue
+				}
+				delete(pending, id)
+				self.runTriggeredJob(watchedJobs[id], p.path)
+			}
+		}
+	}()
+
+	return done
+}
+
+// runTriggeredJob runs the given job because the given path changed,
+// unless the job shouldn't run now.
+func (self *JobRunnerThread) runTriggeredJob(job *jobfile.Job, path string) {
+	now := time.Now()
+	gJobStateLock.Lock()
+	exhausted := job.RunsExhausted()
+	gJobStateLock.Unlock()
+	if self.ctx.Err() != nil || job.Paused || exhausted ||
+		!job.InWindow(now) || !job.ShouldRun(now) {
+		return
+	}
+	common.Logger.Printf("%v: %v (triggered by %v)\n", job.User, job.Cmd,
+		path)
+	self.launchJob(job, false,
+		[]string{jobfile.WatchPathEnvVar + "=" + path})
+}
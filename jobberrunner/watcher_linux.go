@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/jobfile"
+)
+
+var gInotifyMasks = map[jobfile.WatchEvents]uint32{
+	jobfile.WatchCreate: syscall.IN_CREATE,
+	jobfile.WatchWrite:  syscall.IN_CLOSE_WRITE,
+	jobfile.WatchModify: syscall.IN_MODIFY,
+	jobfile.WatchDelete: syscall.IN_DELETE | syscall.IN_DELETE_SELF,
+	jobfile.WatchMove: syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+		syscall.IN_MOVE_SELF,
+	jobfile.WatchAttrib: syscall.IN_ATTRIB,
+}
+
+func inotifyMask(events jobfile.WatchEvents) uint32 {
+	var mask uint32
+	for event, m := range gInotifyMasks {
+		if events&event != 0 {
+			mask |= m
+		}
+	}
+	return mask
+}
+
+/*
+Watch the given paths with inotify until ctx is cancelled, sending
+changes to them to the given channel.  Paths that can't be watched
+(e.g., because they don't exist) are logged and ignored.
+*/
+func watchPaths(ctx context.Context, paths []watchedPath,
+	changes chan<- pathChange) error {
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return &common.Error{What: "Failed to init inotify", Cause: err}
+	}
+	/*
+		Because the fd is non-blocking, os.File uses the runtime's
+		poller, so closing the file interrupts a pending Read.
+	*/
+	file := os.NewFile(uintptr(fd), "inotify")
+	defer file.Close()
+
+	// add watches
+	watches := make(map[int32][]watchedPath) // keyed by watch descriptor
+	for _, p := range paths {
+		/* IN_MASK_ADD, so that jobs watching the same path don't clash */
+		wd, err := syscall.InotifyAddWatch(fd, p.path,
+			inotifyMask(p.events)|syscall.IN_MASK_ADD)
+		if err != nil {
+			common.ErrLogger.Printf("Cannot watch %v: %v", p.path, err)
+			continue
+		}
+		watches[int32(wd)] = append(watches[int32(wd)], p)
+	}
+	if len(watches) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	// stop reading when cancelled
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return &common.Error{What: "Failed to read inotify events",
+				Cause: err}
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+
+				syscall.SizeofInotifyEvent+int(event.Len)]
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			for _, p := range watches[event.Wd] {
+				if event.Mask&inotifyMask(p.events) == 0 {
+					continue
+				}
+				path := p.path
+				if len(name) > 0 {
+					path = filepath.Join(p.path, name)
+				}
+				select {
+				case changes <- pathChange{id: p.id, path: path}:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
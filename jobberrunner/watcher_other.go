@@ -0,0 +1,15 @@
+// +build !linux
+
+package main
+
+import (
+	"context"
+
+	"github.com/dshearer/jobber/common"
+)
+
+func watchPaths(ctx context.Context, paths []watchedPath,
+	changes chan<- pathChange) error {
+
+	return &common.Error{What: "Watching paths is supported only on Linux"}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/dshearer/jobber/jobfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchTriggersJob(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Watching paths is supported only on Linux")
+	}
+
+	/*
+	 * Set up
+	 */
+	dir, err := ioutil.TempDir("", "jobber-watch-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	job := &jobfile.Job{
+		Name:         "Inbox",
+		Cmd:          "echo \"$" + jobfile.WatchPathEnvVar + "\"",
+		ErrorHandler: jobfile.ContinueErrorHandler{},
+		Unscheduled:  true,
+		Watch: &jobfile.WatchSpec{
+			Path:     dir,
+			Events:   jobfile.WatchCreate | jobfile.WatchWrite,
+			Debounce: 100 * time.Millisecond,
+		},
+	}
+	var runner JobRunnerThread
+	runner.Start(map[string]*jobfile.Job{job.Name: job}, "/bin/sh")
+	defer func() {
+		runner.Cancel()
+		for range runner.RunRecChan() {
+		}
+	}()
+
+	/*
+	 * Call
+	 */
+	// (give the watcher time to start)
+	time.Sleep(100 * time.Millisecond)
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(dir, name)
+		require.Nil(t, ioutil.WriteFile(path, []byte("data"), 0600))
+	}
+
+	/*
+	 * Test
+	 */
+	select {
+	case rec := <-runner.RunRecChan():
+		require.Equal(t, common.SubprocFateSucceeded, rec.Fate)
+		require.Equal(t, filepath.Join(dir, "b"),
+			strings.TrimSpace(string(rec.Stdout)))
+	case <-time.After(10 * time.Second):
+		t.Fatal("Job wasn't triggered")
+	}
+
+	// the changes were debounced into one run
+	select {
+	case rec := <-runner.RunRecChan():
+		t.Fatalf("Unexpected run: %v", rec.Describe())
+	case <-time.After(300 * time.Millisecond):
+	}
+}
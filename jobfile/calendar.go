@@ -0,0 +1,626 @@
+package jobfile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+A calendar is a named set of dates and times (e.g., holidays) that jobs
+can refer to.  Calendars are defined in the prefs section, either by
+listing dates or by naming an iCalendar (.ics) or CSV (.csv) file:
+
+	[prefs]
+	calendars:
+	  holidays:
+	    file: holidays.ics
+	  freeze:
+	    dates:
+	      - 2024-12-24/2025-01-02
+	      - 2025-03-01T18:00/2025-03-02T06:00
+
+A job can then say that runs falling in a calendar are to be skipped
+or shifted to the next day that isn't in any of the job's calendars:
+
+	[jobs]
+	report:
+	  cmd: ./report.sh
+	  time: 0 0 9 * * MON-FRI
+	  calendars:
+	    skip: [freeze]
+	    shift: [holidays]
+
+Dates without times are interpreted in the local time zone and cover
+the whole day.  A range "A/B" includes B if B is a date, and excludes
+it if B is a date-time.
+*/
+
+type CalendarRaw struct {
+	Dates []string `yaml:"dates,omitempty"`
+	File  *string  `yaml:"file,omitempty"` // .ics, .ical, or .csv
+}
+
+type JobCalendarsRaw struct {
+	Skip  []string `json:"skip" yaml:"skip,omitempty"`
+	Shift []string `json:"shift" yaml:"shift,omitempty"`
+}
+
+/*
+A range of times [Start, End).  If Yearly is true, the range recurs
+every year, up to and including the one starting at LastStart (or
+forever, if LastStart is zero).
+*/
+type CalendarRange struct {
+	Start     time.Time
+	End       time.Time
+	Yearly    bool
+	LastStart time.Time
+}
+
+func (self CalendarRange) Contains(t time.Time) bool {
+	if !self.Yearly {
+		return !t.Before(self.Start) && t.Before(self.End)
+	}
+
+	// try the occurrences that could contain t
+	for year := t.Year() - 1; year <= t.Year(); year++ {
+		years := year - self.Start.Year()
+		if years < 0 {
+			continue
+		}
+		start := self.Start.AddDate(years, 0, 0)
+		if !self.LastStart.IsZero() && start.After(self.LastStart) {
+			continue
+		}
+		end := self.End.AddDate(years, 0, 0)
+		if !t.Before(start) && t.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+type Calendar struct {
+	Name   string
+	Ranges []CalendarRange
+}
+
+func (self *Calendar) String() string {
+	return self.Name
+}
+
+/*
+Whether t is in one of the calendar's ranges.
+*/
+func (self *Calendar) Contains(t time.Time) bool {
+	for _, r := range self.Ranges {
+		if r.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+The calendars referred to by a job.
+*/
+type JobCalendars struct {
+	Skip  []*Calendar // runs during these are skipped
+	Shift []*Calendar // runs during these are moved to the next free day
+}
+
+func (self JobCalendars) Empty() bool {
+	return len(self.Skip) == 0 && len(self.Shift) == 0
+}
+
+func calendarContaining(cals []*Calendar, t time.Time) *Calendar {
+	for _, cal := range cals {
+		if cal.Contains(t) {
+			return cal
+		}
+	}
+	return nil
+}
+
+/*
+Returns the job's skip calendar containing t, or nil.
+*/
+func (self *Job) SkippingCalendar(t time.Time) *Calendar {
+	return calendarContaining(self.Calendars.Skip, t)
+}
+
+/*
+Returns the job's shift calendar containing t, or nil.
+*/
+func (self *Job) ShiftingCalendar(t time.Time) *Calendar {
+	return calendarContaining(self.Calendars.Shift, t)
+}
+
+/*
+How many days ahead we look for a day to which to shift a run.
+*/
+const gMaxShiftDays = 366
+
+/*
+Returns the time to which a run at t that falls in a shift calendar is
+moved: the same time of day on the first later day on which that time
+is in none of the job's calendars.  Returns nil if there is no such day
+within a year (or within the job's active window).
+*/
+func (self *Job) ShiftTime(t time.Time) *time.Time {
+	for i := 1; i <= gMaxShiftDays; i++ {
+		next := t.AddDate(0, 0, i)
+		if !self.InWindow(next) {
+			return nil
+		}
+		if self.SkippingCalendar(next) == nil &&
+			self.ShiftingCalendar(next) == nil {
+			return &next
+		}
+	}
+	return nil
+}
+
+/*
+How many times from the job's time spec we look at when computing its
+next runs.  This bounds the work done for jobs whose calendars block
+most of their times.
+*/
+const gMaxCalendarFires = 10000
+
+/*
+Returns the job's next n runs at or after now, taking its calendars
+(and any pending shifted run) into account.
+*/
+func (self *Job) NextRunTimes(now time.Time, n int) []time.Time {
+	if self.Calendars.Empty() && self.ShiftedRunTime == nil &&
+		self.At == nil && self.ActiveFrom == nil &&
+		self.ActiveUntil == nil && self.MaxRuns == 0 && !self.Unscheduled {
+		return self.FullTimeSpec.NextTimes(now, n)
+	}
+	if self.MaxRuns > 0 && self.MaxRuns-self.SuccessfulRuns < n {
+		/* assume that the remaining runs succeed */
+		n = self.MaxRuns - self.SuccessfulRuns
+	}
+
+	var times []time.Time
+	add := func(t time.Time) {
+		i := sort.Search(len(times), func(i int) bool {
+			return !times[i].Before(t)
+		})
+		if i < len(times) && times[i].Equal(t) {
+			return
+		}
+		times = append(times, time.Time{})
+		copy(times[i+1:], times[i:])
+		times[i] = t
+	}
+
+	if self.ShiftedRunTime != nil && !self.ShiftedRunTime.Before(now) {
+		add(*self.ShiftedRunTime)
+	}
+	from := now
+	for i := 0; i < gMaxCalendarFires; i++ {
+		next := self.NextTime(from)
+		if next == nil {
+			break
+		}
+		if len(times) >= n && next.After(times[n-1]) {
+			/* later times (and shifts thereof) can't be among the first n */
+			break
+		}
+		from = next.Add(time.Second)
+
+		if self.SkippingCalendar(*next) != nil {
+			// skip
+		} else if self.ShiftingCalendar(*next) != nil {
+			if shifted := self.ShiftTime(*next); shifted != nil {
+				add(*shifted)
+			}
+		} else {
+			add(*next)
+		}
+		if self.At != nil {
+			/* one-shot jobs fire just once */
+			break
+		}
+	}
+
+	if len(times) > n {
+		times = times[:n]
+	}
+	return times
+}
+
+var gCalendarNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+const (
+	gCalendarDateFmt = "2006-01-02"
+	gICalDateFmt     = "20060102"
+	gICalDateTimeFmt = "20060102T150405"
+)
+
+var gCalendarDateTimeFmts = []string{
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+}
+
+/*
+Parse a date or date-time.  isDate tells whether it was just a date.
+*/
+func parseCalendarTime(s string) (t time.Time, isDate bool, err error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation(gCalendarDateFmt, s, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	for _, layout := range gCalendarDateTimeFmts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, false, nil
+		}
+	}
+	msg := fmt.Sprintf("Invalid date or time: \"%v\" (expected, e.g., "+
+		"\"2024-12-25\" or \"2024-12-25T09:00\")", s)
+	return time.Time{}, false, &common.Error{What: msg}
+}
+
+/*
+Parse a date, date-time, or range "A/B" of either.  A date covers the
+whole day.
+*/
+func parseCalendarRange(s string) (CalendarRange, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) > 2 {
+		msg := fmt.Sprintf("Invalid date range: \"%v\"", s)
+		return CalendarRange{}, &common.Error{What: msg}
+	}
+
+	start, startIsDate, err := parseCalendarTime(parts[0])
+	if err != nil {
+		return CalendarRange{}, err
+	}
+	if len(parts) == 1 {
+		if !startIsDate {
+			msg := fmt.Sprintf("A time must be part of a range: \"%v\"", s)
+			return CalendarRange{}, &common.Error{What: msg}
+		}
+		return CalendarRange{Start: start, End: start.AddDate(0, 0, 1)}, nil
+	}
+
+	end, endIsDate, err := parseCalendarTime(parts[1])
+	if err != nil {
+		return CalendarRange{}, err
+	}
+	if endIsDate {
+		end = end.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		msg := fmt.Sprintf("Date range ends before it starts: \"%v\"", s)
+		return CalendarRange{}, &common.Error{What: msg}
+	}
+	return CalendarRange{Start: start, End: end}, nil
+}
+
+/*
+Parse a calendar in CSV format.  Each row has a date (or date-time) and
+optionally an end date (or date-time), which may be followed by other
+columns (e.g., a description), which are ignored.  Empty lines and lines
+starting with "#" are ignored, as is a first row that doesn't start with
+a date (a header).
+*/
+func ParseCsvCalendar(r io.Reader) ([]CalendarRange, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	var ranges []CalendarRange
+	for rowNbr := 1; ; rowNbr++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s := strings.TrimSpace(row[0])
+		if len(row) > 1 && len(strings.TrimSpace(row[1])) > 0 {
+			s += "/" + strings.TrimSpace(row[1])
+		}
+		r, err := parseCalendarRange(s)
+		if err != nil {
+			if rowNbr == 1 {
+				/* header */
+				continue
+			}
+			return nil, &common.Error{
+				What: fmt.Sprintf("Row %v", rowNbr), Cause: err,
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+/*
+An iCalendar content line: "NAME;PARAM=VAL:VALUE".
+*/
+type icalProp struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func parseICalProp(line string) (icalProp, bool) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return icalProp{}, false
+	}
+	prop := icalProp{value: line[colon+1:], params: make(map[string]string)}
+	parts := strings.Split(line[:colon], ";")
+	prop.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return prop, true
+}
+
+func parseICalTime(prop icalProp) (t time.Time, isDate bool, err error) {
+	value := strings.TrimSpace(prop.value)
+	if prop.params["VALUE"] == "DATE" || len(value) == len(gICalDateFmt) {
+		t, err = time.ParseInLocation(gICalDateFmt, value, time.Local)
+		return t, true, err
+	}
+
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		value = strings.TrimSuffix(value, "Z")
+		loc = time.UTC
+	} else if tzid, ok := prop.params["TZID"]; ok {
+		loc, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+	}
+	t, err = time.ParseInLocation(gICalDateTimeFmt, value, loc)
+	return t, false, err
+}
+
+/*
+Apply an RRULE to a range.  Only yearly recurrence (as used for
+holidays) is supported.
+*/
+func applyICalRRule(rule string, r *CalendarRange) error {
+	params := make(map[string]string)
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = kv[1]
+		}
+	}
+	if params["FREQ"] != "YEARLY" {
+		msg := fmt.Sprintf("Unsupported RRULE \"%v\": only FREQ=YEARLY "+
+			"is supported", rule)
+		return &common.Error{What: msg}
+	}
+	for key := range params {
+		switch key {
+		case "FREQ", "COUNT", "UNTIL", "WKST":
+		case "INTERVAL":
+			if params[key] != "1" {
+				msg := fmt.Sprintf("Unsupported RRULE \"%v\": INTERVAL "+
+					"must be 1", rule)
+				return &common.Error{What: msg}
+			}
+		default:
+			msg := fmt.Sprintf("Unsupported RRULE \"%v\": %v is not "+
+				"supported", rule, key)
+			return &common.Error{What: msg}
+		}
+	}
+
+	r.Yearly = true
+	if count, ok := params["COUNT"]; ok {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return &common.Error{What: fmt.Sprintf("Invalid COUNT in RRULE \"%v\"", rule)}
+		}
+		r.LastStart = r.Start.AddDate(n-1, 0, 0)
+	} else if until, ok := params["UNTIL"]; ok {
+		t, _, err := parseICalTime(icalProp{value: until})
+		if err != nil {
+			return &common.Error{What: fmt.Sprintf("Invalid UNTIL in RRULE \"%v\"", rule)}
+		}
+		r.LastStart = t
+	}
+	return nil
+}
+
+/*
+Parse a calendar in iCalendar format.  Each VEVENT becomes a range from
+its DTSTART to its DTEND (or, for all-day events without a DTEND, to
+the end of the day).
+*/
+func ParseICalCalendar(r io.Reader) ([]CalendarRange, error) {
+	// unfold lines
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && len(line) > 0 &&
+			(line[0] == ' ' || line[0] == '\t') {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var ranges []CalendarRange
+	var event map[string]icalProp
+	for lineNbr, line := range lines {
+		prop, ok := parseICalProp(line)
+		if !ok {
+			continue
+		}
+		lineErr := func(err error) error {
+			return &common.Error{
+				What: fmt.Sprintf("Line %v", lineNbr+1), Cause: err,
+			}
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.ToUpper(prop.value) == "VEVENT":
+			event = make(map[string]icalProp)
+
+		case prop.name == "END" && strings.ToUpper(prop.value) == "VEVENT":
+			if event == nil {
+				continue
+			}
+			dtstart, ok := event["DTSTART"]
+			if !ok {
+				return nil, lineErr(&common.Error{What: "Event has no DTSTART"})
+			}
+			start, startIsDate, err := parseICalTime(dtstart)
+			if err != nil {
+				return nil, lineErr(err)
+			}
+			r := CalendarRange{Start: start, End: start}
+			if dtend, ok := event["DTEND"]; ok {
+				if r.End, _, err = parseICalTime(dtend); err != nil {
+					return nil, lineErr(err)
+				}
+			} else if startIsDate {
+				r.End = start.AddDate(0, 0, 1)
+			}
+			if rrule, ok := event["RRULE"]; ok {
+				if err := applyICalRRule(rrule.value, &r); err != nil {
+					return nil, lineErr(err)
+				}
+			}
+			ranges = append(ranges, r)
+			event = nil
+
+		case event != nil:
+			event[prop.name] = prop
+		}
+	}
+	return ranges, nil
+}
+
+func (self CalendarRaw) filePath(usr *user.User) (string, error) {
+	/*
+	   Relative paths are interpreted as relative to the user's
+	   home dir.
+	*/
+	path := *self.File
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	if len(usr.HomeDir) == 0 {
+		errMsg := fmt.Sprintf("User has no home directory, so "+
+			"cannot interpret relative calendar file path %v", path)
+		return "", &common.Error{What: errMsg}
+	}
+	return filepath.Join(usr.HomeDir, path), nil
+}
+
+func (self CalendarRaw) ToCalendar(usr *user.User, name string) (*Calendar, error) {
+	if !gCalendarNameRegexp.MatchString(name) {
+		msg := fmt.Sprintf("Invalid calendar name \"%v\": it may contain "+
+			"only letters, digits, '_', '.', and '-'", name)
+		return nil, &common.Error{What: msg}
+	}
+	if len(self.Dates) == 0 && self.File == nil {
+		return nil, &common.Error{What: "Calendar needs \"dates\" or \"file\""}
+	}
+
+	cal := Calendar{Name: name}
+	for _, s := range self.Dates {
+		r, err := parseCalendarRange(s)
+		if err != nil {
+			return nil, err
+		}
+		cal.Ranges = append(cal.Ranges, r)
+	}
+
+	if self.File != nil {
+		path, err := self.filePath(usr)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		var ranges []CalendarRange
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ics", ".ical":
+			ranges, err = ParseICalCalendar(f)
+		case ".csv":
+			ranges, err = ParseCsvCalendar(f)
+		default:
+			msg := fmt.Sprintf("Unknown calendar file type: %v (expected "+
+				".ics, .ical, or .csv)", path)
+			return nil, &common.Error{What: msg}
+		}
+		if err != nil {
+			return nil, &common.Error{What: path, Cause: err}
+		}
+		cal.Ranges = append(cal.Ranges, ranges...)
+	}
+	return &cal, nil
+}
+
+func (self *JobCalendarsRaw) ToJobCalendars(
+	calendars map[string]*Calendar) (JobCalendars, error) {
+
+	var result JobCalendars
+	if self == nil {
+		return result, nil
+	}
+	lookup := func(names []string) ([]*Calendar, error) {
+		var cals []*Calendar
+		for _, name := range names {
+			cal, ok := calendars[name]
+			if !ok {
+				msg := fmt.Sprintf("No such calendar: \"%v\"", name)
+				return nil, &common.Error{What: msg}
+			}
+			cals = append(cals, cal)
+		}
+		return cals, nil
+	}
+	var err error
+	if result.Skip, err = lookup(self.Skip); err != nil {
+		return result, err
+	}
+	if result.Shift, err = lookup(self.Shift); err != nil {
+		return result, err
+	}
+	return result, nil
+}
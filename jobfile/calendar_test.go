@@ -0,0 +1,293 @@
+package jobfile
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func localDate(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.Local)
+}
+
+func TestParseCalendarRange(t *testing.T) {
+	cases := []struct {
+		input string
+		start time.Time
+		end   time.Time
+	}{
+		{"2024-12-25", localDate(2024, 12, 25, 0, 0), localDate(2024, 12, 26, 0, 0)},
+		{"2024-12-24/2024-12-26", localDate(2024, 12, 24, 0, 0), localDate(2024, 12, 27, 0, 0)},
+		{"2025-03-01T18:00/2025-03-02T06:00", localDate(2025, 3, 1, 18, 0), localDate(2025, 3, 2, 6, 0)},
+		{"2025-03-01 18:00/2025-03-02", localDate(2025, 3, 1, 18, 0), localDate(2025, 3, 3, 0, 0)},
+		{"2025-03-01T18:00:00Z/2025-03-01T19:00:00Z",
+			time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC),
+			time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		r, err := parseCalendarRange(c.input)
+		require.Nil(t, err, "%v: %v", c.input, err)
+		require.True(t, c.start.Equal(r.Start), "%v: %v", c.input, r.Start)
+		require.True(t, c.end.Equal(r.End), "%v: %v", c.input, r.End)
+	}
+
+	for _, input := range []string{
+		"",
+		"2024-12-32",
+		"12/25/2024",
+		"2024-12-25T09:00",
+		"2024-12-26/2024-12-25",
+		"2024-12-25/2024-12-26/2024-12-27",
+	} {
+		_, err := parseCalendarRange(input)
+		require.NotNil(t, err, "%v", input)
+	}
+}
+
+const gTestICal = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Christmas\r\n" +
+	"DTSTART;VALUE=DATE:20201225\r\n" +
+	"RRULE:FREQ=YEARLY\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Company\r\n" +
+	"  retreat\r\n" +
+	"DTSTART;VALUE=DATE:20240610\r\n" +
+	"DTEND;VALUE=DATE:20240613\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Maintenance\r\n" +
+	"DTSTART:20240701T020000Z\r\n" +
+	"DTEND:20240701T040000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Founders' day\r\n" +
+	"DTSTART;VALUE=DATE:20200301\r\n" +
+	"RRULE:FREQ=YEARLY;COUNT=3\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICalCalendar(t *testing.T) {
+	ranges, err := ParseICalCalendar(strings.NewReader(gTestICal))
+	require.Nil(t, err, "%v", err)
+	require.Equal(t, 4, len(ranges))
+	cal := Calendar{Name: "test", Ranges: ranges}
+
+	contained := []time.Time{
+		localDate(2020, 12, 25, 0, 0),
+		localDate(2031, 12, 25, 23, 59),
+		localDate(2024, 6, 12, 12, 0),
+		time.Date(2024, 7, 1, 3, 0, 0, 0, time.UTC),
+		localDate(2022, 3, 1, 9, 0),
+	}
+	for _, tm := range contained {
+		require.True(t, cal.Contains(tm), "%v", tm)
+	}
+
+	notContained := []time.Time{
+		localDate(2019, 12, 25, 9, 0),
+		localDate(2031, 12, 26, 0, 0),
+		localDate(2024, 6, 13, 0, 0),
+		time.Date(2024, 7, 1, 4, 0, 0, 0, time.UTC),
+		localDate(2023, 3, 1, 9, 0),
+	}
+	for _, tm := range notContained {
+		require.False(t, cal.Contains(tm), "%v", tm)
+	}
+}
+
+func TestParseICalCalendarErrors(t *testing.T) {
+	inputs := []string{
+		"BEGIN:VEVENT\nSUMMARY:No start\nEND:VEVENT\n",
+		"BEGIN:VEVENT\nDTSTART:2024\nEND:VEVENT\n",
+		"BEGIN:VEVENT\nDTSTART:20240101\nRRULE:FREQ=WEEKLY\nEND:VEVENT\n",
+		"BEGIN:VEVENT\nDTSTART:20240101\nRRULE:FREQ=YEARLY;BYMONTH=1\nEND:VEVENT\n",
+	}
+	for _, input := range inputs {
+		_, err := ParseICalCalendar(strings.NewReader(input))
+		require.NotNil(t, err, "%v", input)
+	}
+}
+
+func TestParseCsvCalendar(t *testing.T) {
+	input := `start,end,description
+# comment
+2024-12-25,,Christmas
+2024-12-31, 2025-01-01 ,"New Year's"
+2025-03-01T18:00,2025-03-02T06:00
+`
+	ranges, err := ParseCsvCalendar(strings.NewReader(input))
+	require.Nil(t, err, "%v", err)
+	require.Equal(t, []CalendarRange{
+		{Start: localDate(2024, 12, 25, 0, 0), End: localDate(2024, 12, 26, 0, 0)},
+		{Start: localDate(2024, 12, 31, 0, 0), End: localDate(2025, 1, 2, 0, 0)},
+		{Start: localDate(2025, 3, 1, 18, 0), End: localDate(2025, 3, 2, 6, 0)},
+	}, ranges)
+
+	_, err = ParseCsvCalendar(strings.NewReader("2024-12-25\nChristmas\n"))
+	require.NotNil(t, err)
+}
+
+func TestJobNextRunTimes(t *testing.T) {
+	/*
+		A job that runs every Monday at 9:00.  The run on 15 Jan 2024
+		is shifted to the next day, and the one on 22 Jan is skipped.
+	*/
+	timeSpec, err := ParseFullTimeSpec("0 0 9 * * MON")
+	require.Nil(t, err)
+	job := Job{
+		FullTimeSpec: *timeSpec,
+		Calendars: JobCalendars{
+			Skip: []*Calendar{{
+				Name: "freeze",
+				Ranges: []CalendarRange{{
+					Start: localDate(2024, 1, 22, 0, 0),
+					End:   localDate(2024, 1, 23, 0, 0),
+				}},
+			}},
+			Shift: []*Calendar{{
+				Name: "holidays",
+				Ranges: []CalendarRange{{
+					Start: localDate(2024, 1, 15, 0, 0),
+					End:   localDate(2024, 1, 16, 0, 0),
+				}},
+			}},
+		},
+	}
+
+	require.Equal(t,
+		[]time.Time{
+			localDate(2024, 1, 8, 9, 0),
+			localDate(2024, 1, 16, 9, 0),
+			localDate(2024, 1, 29, 9, 0),
+		},
+		job.NextRunTimes(localDate(2024, 1, 8, 0, 0), 3))
+
+	// a pending shifted run comes first
+	shifted := localDate(2024, 1, 3, 9, 0)
+	job.ShiftedRunTime = &shifted
+	require.Equal(t,
+		[]time.Time{shifted, localDate(2024, 1, 8, 9, 0)},
+		job.NextRunTimes(localDate(2024, 1, 1, 12, 0), 2))
+}
+
+func TestJobNextRunTimesShiftIntoRun(t *testing.T) {
+	/*
+		A daily job whose runs on holidays are shifted onto its
+		regular runs: it should run just once on those days.
+	*/
+	timeSpec, err := ParseFullTimeSpec("0 0 9")
+	require.Nil(t, err)
+	job := Job{
+		FullTimeSpec: *timeSpec,
+		Calendars: JobCalendars{
+			Shift: []*Calendar{&gTestHolidays},
+		},
+	}
+
+	require.Equal(t,
+		[]time.Time{
+			localDate(2024, 12, 24, 9, 0),
+			localDate(2024, 12, 26, 9, 0),
+			localDate(2024, 12, 27, 9, 0),
+			localDate(2024, 12, 28, 9, 0),
+			localDate(2024, 12, 29, 9, 0),
+			localDate(2024, 12, 30, 9, 0),
+			localDate(2025, 1, 2, 9, 0),
+			localDate(2025, 1, 3, 9, 0),
+		},
+		job.NextRunTimes(localDate(2024, 12, 24, 0, 0), 8))
+}
+
+func TestOneShotJobNextRunTimes(t *testing.T) {
+	at := localDate(2026, 11, 1, 2, 0)
+	job := Job{At: &at}
+
+	// before its time
+	require.Equal(t, []time.Time{at},
+		job.NextRunTimes(localDate(2026, 10, 30, 0, 0), 3))
+
+	// after its time, but it hasn't fired yet
+	now := localDate(2026, 11, 2, 0, 0)
+	require.Equal(t, []time.Time{now}, job.NextRunTimes(now, 3))
+
+	// shifted by a calendar
+	job.Calendars.Shift = []*Calendar{{
+		Name: "holidays",
+		Ranges: []CalendarRange{{
+			Start: localDate(2026, 11, 1, 0, 0),
+			End:   localDate(2026, 11, 2, 0, 0),
+		}},
+	}}
+	require.Equal(t, []time.Time{localDate(2026, 11, 2, 2, 0)},
+		job.NextRunTimes(localDate(2026, 10, 30, 0, 0), 3))
+
+	// after it has fired
+	job.AtFired = true
+	require.Equal(t, 0, len(job.NextRunTimes(now, 3)))
+}
+
+func TestJobActiveWindow(t *testing.T) {
+	/*
+		A job that runs every day at 9:00, but only from 2 Nov 2026
+		until the end of 4 Nov.
+	*/
+	timeSpec, err := ParseFullTimeSpec("0 0 9")
+	require.Nil(t, err)
+	from := localDate(2026, 11, 2, 0, 0)
+	until := localDate(2026, 11, 5, 0, 0)
+	job := Job{
+		FullTimeSpec: *timeSpec,
+		ActiveFrom:   &from,
+		ActiveUntil:  &until,
+	}
+
+	require.Equal(t,
+		[]time.Time{
+			localDate(2026, 11, 2, 9, 0),
+			localDate(2026, 11, 3, 9, 0),
+			localDate(2026, 11, 4, 9, 0),
+		},
+		job.NextRunTimes(localDate(2026, 10, 1, 0, 0), 5))
+	require.Equal(t, JobNotYetActiveStr,
+		job.ActivityString(localDate(2026, 11, 1, 0, 0)))
+	require.Equal(t, "", job.ActivityString(localDate(2026, 11, 4, 23, 0)))
+	require.Equal(t, JobExpiredStr,
+		job.ActivityString(localDate(2026, 11, 5, 0, 0)))
+	require.Nil(t, job.NextTime(localDate(2026, 11, 4, 9, 0).Add(time.Second)))
+}
+
+func TestJobMaxRuns(t *testing.T) {
+	timeSpec, err := ParseFullTimeSpec("0 0 9")
+	require.Nil(t, err)
+	job := Job{FullTimeSpec: *timeSpec, MaxRuns: 3, SuccessfulRuns: 1}
+	now := localDate(2026, 11, 1, 0, 0)
+
+	require.Equal(t,
+		[]time.Time{
+			localDate(2026, 11, 1, 9, 0),
+			localDate(2026, 11, 2, 9, 0),
+		},
+		job.NextRunTimes(now, 5))
+	require.Equal(t, "", job.ActivityString(now))
+
+	job.SuccessfulRuns = 3
+	require.True(t, job.RunsExhausted())
+	require.Nil(t, job.NextTime(now))
+	require.Equal(t, JobExpiredStr, job.ActivityString(now))
+}
+
+func TestUnscheduledJobNextRunTimes(t *testing.T) {
+	job := Job{
+		Unscheduled: true,
+		Watch:       &WatchSpec{Path: "/var/inbox"},
+	}
+	now := localDate(2026, 11, 1, 0, 0)
+	require.Nil(t, job.NextTime(now))
+	require.Equal(t, 0, len(job.NextRunTimes(now, 3)))
+	require.Equal(t, "watch /var/inbox", job.ScheduleString())
+}
@@ -0,0 +1,592 @@
+package jobfile
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+These functions convert between crontabs (in the format read by
+"crontab" -- i.e., user crontabs, without a user field) and jobs.
+*/
+
+/*
+The result of converting a crontab to jobs.
+*/
+type CrontabImport struct {
+	Jobs map[string]JobRaw
+
+	// job names, in the order in which the jobs appear in the crontab
+	Names []string
+
+	// things in the crontab that could not be converted exactly
+	Warnings []string
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string // names for the values min, min+1, ...
+}
+
+var (
+	gCronMinField  = cronField{name: "minute", min: 0, max: 59}
+	gCronHourField = cronField{name: "hour", min: 0, max: 23}
+	gCronMdayField = cronField{name: "month day", min: 1, max: 31}
+	gCronMonField  = cronField{
+		name: "month",
+		min:  1,
+		max:  12,
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul",
+			"aug", "sep", "oct", "nov", "dec"},
+	}
+	gCronWdayField = cronField{
+		name:  "weekday",
+		min:   0,
+		max:   7, // 7 is Sunday, as is 0
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"},
+	}
+)
+
+var gCronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var gCronEnvLineRegex = regexp.MustCompile(
+	`^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
+
+/*
+Convert a crontab to jobs.  Each job gets a name made from its command.
+
+Environment assignments are put at the start of the commands of the
+jobs that follow them, and a SHELL assignment makes the commands run
+with that shell.  As in cron, the first unescaped "%" in a command
+starts the command's stdin, and later ones are newlines.
+
+Returns an error if the crontab has a line that cannot be parsed.
+*/
+func ImportCrontab(text string) (*CrontabImport, error) {
+	result := CrontabImport{Jobs: make(map[string]JobRaw)}
+	var env []string
+	shell := ""
+
+	warn := func(lineNbr int, msg string) {
+		result.Warnings = append(result.Warnings,
+			fmt.Sprintf("line %v: %v", lineNbr, msg))
+	}
+	addJob := func(baseName string, job JobRaw) string {
+		name := baseName
+		for i := 2; ; i++ {
+			if _, ok := result.Jobs[name]; !ok {
+				break
+			}
+			name = fmt.Sprintf("%v-%v", baseName, i)
+		}
+		result.Jobs[name] = job
+		result.Names = append(result.Names, name)
+		return name
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		lineNbr := i + 1
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		// environment assignment
+		if m := gCronEnvLineRegex.FindStringSubmatch(line); m != nil {
+			name, value := m[1], unquoteCronEnvValue(m[2])
+			switch name {
+			case "SHELL":
+				if value == "/bin/sh" {
+					shell = ""
+				} else {
+					shell = value
+				}
+			case "MAILTO", "MAILFROM", "CRON_TZ":
+				warn(lineNbr, fmt.Sprintf("%v is not supported; use "+
+					"notifyOnError, etc., to be told about runs", name))
+			default:
+				env = append(env, fmt.Sprintf("export %v=%v", name,
+					shellQuote(value)))
+			}
+			continue
+		}
+
+		// split into time spec and command
+		var spec, cmd string
+		if line[0] == '@' {
+			macro := strings.Fields(line)[0]
+			if macro == "@reboot" {
+				warn(lineNbr, "Jobber cannot run jobs at boot; skipping")
+				continue
+			}
+			var ok bool
+			spec, ok = gCronMacros[macro]
+			cmd = cronCmdAfterFields(line, 1)
+			if !ok || len(cmd) == 0 {
+				msg := fmt.Sprintf("Line %v: invalid entry", lineNbr)
+				return nil, &common.Error{What: msg}
+			}
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) < 6 {
+				msg := fmt.Sprintf("Line %v: invalid entry", lineNbr)
+				return nil, &common.Error{What: msg}
+			}
+			spec = strings.Join(fields[:5], " ")
+			cmd = cronCmdAfterFields(line, 5)
+		}
+
+		// convert time spec
+		specFields := strings.Fields(spec)
+		convFields := make([]string, 5)
+		for j, field := range []cronField{gCronMinField, gCronHourField,
+			gCronMdayField, gCronMonField, gCronWdayField} {
+
+			conv, err := field.toJobber(specFields[j])
+			if err != nil {
+				msg := fmt.Sprintf("Line %v: %v", lineNbr, err)
+				return nil, &common.Error{What: msg}
+			}
+			convFields[j] = conv
+		}
+		if !cronDaysMatchJobber(specFields[2], specFields[4],
+			convFields[2], convFields[4]) {
+			warn(lineNbr, "cron runs this entry only when both the month "+
+				"day and the weekday match, but Jobber will run it when "+
+				"either matches")
+		}
+
+		// convert command
+		job := JobRaw{
+			Cmd:  convertCronCmd(cmd, env, shell),
+			Time: "0 " + strings.Join(convFields, " "),
+		}
+		addJob(cronJobName(cmd), job)
+	}
+	return &result, nil
+}
+
+/*
+Cron and Jobber both run a job when either the month day or the weekday
+matches, if both are restricted, and otherwise when both match.  But
+cron considers a field restricted if it doesn't start with "*", while
+Jobber considers it restricted if it isn't "*".  Return whether the two
+agree on the given fields.
+*/
+func cronDaysMatchJobber(cronMday, cronWday, jobberMday,
+	jobberWday string) bool {
+
+	cronOr := !strings.HasPrefix(cronMday, "*") &&
+		!strings.HasPrefix(cronWday, "*")
+	jobberOr := jobberMday != TimeWildcard && jobberWday != TimeWildcard
+	return cronOr == jobberOr
+}
+
+/*
+Get the part of a crontab line after the first n fields.
+*/
+func cronCmdAfterFields(line string, n int) string {
+	rest := line
+	for i := 0; i < n; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			return ""
+		}
+		rest = rest[end:]
+	}
+	return strings.TrimSpace(rest)
+}
+
+func unquoteCronEnvValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 &&
+		(value[0] == '"' || value[0] == '\'') &&
+		value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+/*
+Convert a crontab field to a jobber time spec field.
+*/
+func (self cronField) toJobber(field string) (string, error) {
+	if field == "*" {
+		return "*", nil
+	}
+	if strings.HasPrefix(field, "*/") && self.max != 7 {
+		if _, err := strconv.Atoi(field[2:]); err == nil {
+			return field, nil
+		}
+	}
+
+	// get values
+	valSet := make(map[int]bool)
+	for _, elem := range strings.Split(field, ",") {
+		vals, err := self.eval(elem)
+		if err != nil {
+			return "", err
+		}
+		for _, v := range vals {
+			if self.max == 7 && v == 7 {
+				v = 0
+			}
+			valSet[v] = true
+		}
+	}
+	var vals []int
+	for v := range valSet {
+		vals = append(vals, v)
+	}
+	sort.Ints(vals)
+
+	// make spec
+	if len(vals) == 1 {
+		return strconv.Itoa(vals[0]), nil
+	}
+	if len(vals) == vals[len(vals)-1]-vals[0]+1 {
+		return fmt.Sprintf("%v-%v", vals[0], vals[len(vals)-1]), nil
+	}
+	var strs []string
+	for _, v := range vals {
+		strs = append(strs, strconv.Itoa(v))
+	}
+	return strings.Join(strs, ","), nil
+}
+
+/*
+Get the values denoted by one element of a comma-separated crontab
+field.
+*/
+func (self cronField) eval(elem string) ([]int, error) {
+	bad := func() error {
+		msg := fmt.Sprintf("Invalid %v: \"%v\"", self.name, elem)
+		return &common.Error{What: msg}
+	}
+
+	// step
+	step := 1
+	if parts := strings.SplitN(elem, "/", 2); len(parts) == 2 {
+		var err error
+		step, err = strconv.Atoi(parts[1])
+		if err != nil || step < 1 {
+			return nil, bad()
+		}
+		elem = parts[0]
+	}
+
+	// range
+	var start, end int
+	if elem == "*" {
+		start, end = self.min, self.max
+		if self.max == 7 {
+			end = 6
+		}
+	} else if parts := strings.SplitN(elem, "-", 2); len(parts) == 2 {
+		var ok1, ok2 bool
+		start, ok1 = self.value(parts[0])
+		end, ok2 = self.value(parts[1])
+		if !ok1 || !ok2 || start > end {
+			return nil, bad()
+		}
+	} else {
+		var ok bool
+		start, ok = self.value(elem)
+		if !ok {
+			return nil, bad()
+		}
+		end = start
+		if step > 1 {
+			// "N/STEP" means "N-MAX/STEP"
+			end = self.max
+		}
+	}
+
+	var vals []int
+	for v := start; v <= end; v += step {
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+func (self cronField) value(s string) (int, bool) {
+	for i, name := range self.names {
+		if strings.ToLower(s) == name {
+			return self.min + i, true
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < self.min || v > self.max {
+		return 0, false
+	}
+	return v, true
+}
+
+/*
+Make a jobber command from a crontab command, handling "%" as cron
+does.
+*/
+func convertCronCmd(cmd string, env []string, shell string) string {
+	// split at first unescaped "%"
+	var cmdPart, stdinPart strings.Builder
+	var inStdin, hasStdin bool
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		dest := &cmdPart
+		if inStdin {
+			dest = &stdinPart
+		}
+		switch {
+		case c == '\\' && i+1 < len(cmd) && cmd[i+1] == '%':
+			dest.WriteByte('%')
+			i++
+		case c == '%' && !inStdin:
+			inStdin, hasStdin = true, true
+		case c == '%':
+			dest.WriteByte('\n')
+		default:
+			dest.WriteByte(c)
+		}
+	}
+
+	jobCmd := strings.TrimSpace(cmdPart.String())
+	if len(shell) > 0 {
+		jobCmd = fmt.Sprintf("%v -c %v", shell, shellQuote(jobCmd))
+	}
+	if hasStdin {
+		stdin := stdinPart.String()
+		delim := "JOBBER_STDIN"
+		for strings.Contains(stdin, delim) {
+			delim += "_"
+		}
+		jobCmd = fmt.Sprintf("%v <<'%v'\n%v\n%v", jobCmd, delim, stdin,
+			delim)
+	}
+	if len(env) > 0 {
+		jobCmd = strings.Join(env, "\n") + "\n" + jobCmd
+	}
+	return jobCmd
+}
+
+/*
+Make a job name from a command: the base name of the program it runs.
+*/
+func cronJobName(cmd string) string {
+	for _, word := range strings.Fields(cmd) {
+		if strings.Contains(word, "=") {
+			// skip env assignments
+			continue
+		}
+		var name strings.Builder
+		for _, c := range path.Base(word) {
+			if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+				(c >= '0' && c <= '9') || c == '-' || c == '_' {
+				name.WriteRune(c)
+			}
+		}
+		if name.Len() > 0 && name.Len() <= 32 {
+			return name.String()
+		} else if name.Len() > 32 {
+			return name.String()[:32]
+		}
+		break
+	}
+	return "job"
+}
+
+/*
+Convert jobs to a crontab.  Jobs that cannot be expressed in a crontab
+are left out, and parts of jobs that cannot be expressed (like error
+handlers and result sinks) are dropped; both are described in the
+returned warnings.
+*/
+func ExportCrontab(jobs map[string]JobRaw) (string, []string) {
+	var names []string
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines, warnings []string
+	warn := func(jobName string, msg string) {
+		warnings = append(warnings, fmt.Sprintf("%v: %v", jobName, msg))
+	}
+	lines = append(lines, "# Exported from Jobber")
+	for _, name := range names {
+		job := jobs[name]
+		if job.At != nil {
+			warn(name, "cron cannot run one-shot jobs; skipping")
+			continue
+		}
+		if job.Watch != nil && len(job.Time) == 0 {
+			warn(name, "cron cannot run jobs triggered only by changes "+
+				"to files; skipping")
+			continue
+		}
+
+		// convert time spec
+		spec, randomized, err := jobTimeToCron(job.Time)
+		if err != nil {
+			warn(name, fmt.Sprintf("%v; skipping", err))
+			continue
+		}
+		if randomized {
+			warn(name, fmt.Sprintf("cron has no random time specs, so "+
+				"the time was fixed at \"%v\"", spec))
+		}
+		fields := strings.Fields(spec)
+		if !cronDaysMatchJobber(fields[2], fields[4], fields[2], fields[4]) {
+			warn(name, "cron would run this job only when both the month "+
+				"day and the weekday match, but Jobber runs it when either "+
+				"matches; skipping")
+			continue
+		}
+
+		// convert command
+		if strings.Contains(strings.TrimRight(job.Cmd, "\n"), "\n") {
+			warn(name, "cron cannot run multi-line commands; skipping")
+			continue
+		}
+		cmd := strings.Replace(strings.TrimSpace(job.Cmd), "%", `\%`, -1)
+
+		// check other features
+		if job.OnError != nil && *job.OnError != ErrorHandlerContinueName {
+			warn(name, fmt.Sprintf("cron has no error handlers, so "+
+				"onError (%v) was dropped", *job.OnError))
+		}
+		if len(job.NotifyOnSuccess) > 0 || len(job.NotifyOnError) > 0 ||
+			len(job.NotifyOnFailure) > 0 || len(job.NotifyOnWarning) > 0 {
+			warn(name, "cron has no result sinks, so notifyOnSuccess, "+
+				"notifyOnError, notifyOnFailure, and notifyOnWarning were "+
+				"dropped")
+		}
+		if job.SuccessExitCodes != nil || job.WarningExitCodes != nil ||
+			job.OutputMatchers != nil {
+			warn(name, "cron judges runs only by exit code 0, so "+
+				"successExitCodes, warningExitCodes, and outputMatchers "+
+				"were dropped")
+		}
+		if job.Calendars != nil {
+			warn(name, "cron has no calendars, so calendars were dropped")
+		}
+		if job.ActiveFrom != nil || job.ActiveUntil != nil ||
+			job.MaxRuns != nil {
+			warn(name, "cron has no active windows or run limits, so "+
+				"activeFrom, activeUntil, and maxRuns were dropped")
+		}
+		if job.OnStatusChange != nil {
+			warn(name, "cron has no status hooks, so onStatusChange was "+
+				"dropped")
+		}
+		if job.Watch != nil {
+			warn(name, "cron has no watch triggers, so watch was dropped")
+		}
+		if job.When != nil {
+			warn(name, "cron has no preconditions, so when was dropped")
+		}
+		if job.Retry != nil {
+			warn(name, "cron has no retries, so retry was dropped")
+		}
+		if job.Lock != nil {
+			warn(name, "cron has no locks, so lock was dropped")
+		}
+		if job.Resources != nil {
+			warn(name, "cron has no resource limits, so resources was "+
+				"dropped")
+		}
+		if job.Sandbox != nil {
+			warn(name, "cron has no sandboxes, so sandbox was dropped")
+		}
+		if job.Secrets != nil {
+			warn(name, "cron has no secrets, so secrets was dropped")
+		}
+		if job.Priority != nil {
+			warn(name, "cron has no priorities, so priority was dropped")
+		}
+
+		lines = append(lines, "", "# "+name, spec+" "+cmd)
+	}
+	return strings.Join(lines, "\n") + "\n", warnings
+}
+
+var gWrappedRangeRegex = regexp.MustCompile(`^(\d+)-(\d+)$`)
+
+/*
+Convert a jobber time spec to a crontab time spec.  Random fields are
+replaced with a randomly picked value, in which case the second return
+value is true.  Names (like "MON") and wrapped weekday ranges (like
+"FRI-MON") are replaced with numbers.
+*/
+func jobTimeToCron(timeStr string) (string, bool, error) {
+	fullSpec, err := ParseFullTimeSpec(timeStr)
+	if err != nil {
+		return "", false, err
+	}
+	fullSpec.Derandomize()
+
+	fields := strings.Fields(timeStr)
+	for len(fields) < 6 {
+		fields = append(fields, TimeWildcard)
+	}
+	specs := []TimeSpec{fullSpec.Sec, fullSpec.Min, fullSpec.Hour,
+		fullSpec.Mday, fullSpec.Mon, fullSpec.Wday}
+	randomized := false
+	for i, spec := range specs {
+		switch s := spec.(type) {
+		case *RandomTimeSpec:
+			fields[i] = strconv.Itoa(*s.PickedValue())
+			randomized = true
+
+		case DateTimeSpec:
+			msg := fmt.Sprintf("cron does not understand \"%v\"", fields[i])
+			return "", false, &common.Error{What: msg}
+
+		case OneValTimeSpec:
+			fields[i] = strconv.Itoa(s.val)
+
+		case SetTimeSpec:
+			numeric := strings.Trim(fields[i], "0123456789*/,-") == ""
+			wrapped := false
+			if m := gWrappedRangeRegex.FindStringSubmatch(fields[i]); m != nil {
+				start, _ := strconv.Atoi(m[1])
+				end, _ := strconv.Atoi(m[2])
+				wrapped = start > end
+			}
+			if !numeric || wrapped {
+				var strs []string
+				for _, v := range s.vals {
+					strs = append(strs, strconv.Itoa(v))
+				}
+				fields[i] = strings.Join(strs, ",")
+			}
+		}
+	}
+
+	// seconds
+	if fields[0] != "0" {
+		return "", false, &common.Error{What: "cron can only run jobs at " +
+			"the start of a minute"}
+	}
+	return strings.Join(fields[1:], " "), randomized, nil
+}
@@ -0,0 +1,109 @@
+package jobfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const gTestCrontab = `# m h dom mon dow command
+SHELL=/bin/bash
+PATH="/usr/local/bin:/usr/bin:/bin"
+MAILTO=me@example.com
+
+*/15 * * * * /usr/local/bin/backup.sh --quick
+0 9-17 * jan-mar,dec Mon-Fri /usr/bin/report
+@daily  /usr/local/bin/backup.sh --full
+@reboot /usr/bin/startup
+5 4 1,15 * 0,7 mail -s "hi 50\% off" bob%Hello%Bob
+0 0 */2 * 1 date
+`
+
+func TestImportCrontab(t *testing.T) {
+	result, err := ImportCrontab(gTestCrontab)
+	require.Nil(t, err, "%v", err)
+
+	require.Equal(t,
+		[]string{"backupsh", "report", "backupsh-2", "mail", "date"},
+		result.Names)
+	env := "export PATH='/usr/local/bin:/usr/bin:/bin'\n"
+
+	require.Equal(t, JobRaw{
+		Time: "0 */15 * * * *",
+		Cmd:  env + "/bin/bash -c '/usr/local/bin/backup.sh --quick'",
+	}, result.Jobs["backupsh"])
+	require.Equal(t, "0 0 9-17 * 1,2,3,12 1-5", result.Jobs["report"].Time)
+	require.Equal(t, "0 0 0 * * *", result.Jobs["backupsh-2"].Time)
+
+	require.Equal(t, "0 5 4 1,15 * 0", result.Jobs["mail"].Time)
+	require.Equal(t,
+		env+"/bin/bash -c 'mail -s \"hi 50% off\" bob' <<'JOBBER_STDIN'\n"+
+			"Hello\nBob\nJOBBER_STDIN",
+		result.Jobs["mail"].Cmd)
+
+	/*
+		Warnings for MAILTO, @reboot, and "date" (which cron runs only
+		on even days that are Mondays)
+	*/
+	require.Equal(t, 3, len(result.Warnings), "%v", result.Warnings)
+	require.True(t, strings.HasPrefix(result.Warnings[0], "line 4:"))
+	require.True(t, strings.HasPrefix(result.Warnings[1], "line 9:"))
+	require.True(t, strings.HasPrefix(result.Warnings[2], "line 11:"))
+
+	// the jobs are valid
+	for name, job := range result.Jobs {
+		_, err := ParseFullTimeSpec(job.Time)
+		require.Nil(t, err, "%v: %v", name, err)
+	}
+}
+
+func TestImportCrontabErrors(t *testing.T) {
+	badCrontabs := []string{
+		"* * * *  echo hi\n",
+		"60 * * * * echo hi\n",
+		"* * * foo * echo hi\n",
+		"5-1 * * * * echo hi\n",
+		"@sometimes echo hi\n",
+		"@daily\n",
+	}
+	for _, crontab := range badCrontabs {
+		_, err := ImportCrontab(crontab)
+		require.NotNil(t, err, "%v", crontab)
+	}
+}
+
+func TestExportCrontab(t *testing.T) {
+	stop := ErrorHandlerStopName
+	jobs := map[string]JobRaw{
+		"A": {Time: "0 */5 * * * 1-5", Cmd: "echo 100%"},
+		"B": {Time: "0 0 12", Cmd: "backup", OnError: &stop},
+		"C": {Time: "* * * * * *", Cmd: "every second"},
+		"D": {Time: "0 0 0 */2 * 1", Cmd: "both days"},
+		"E": {Time: "0 0 0", Cmd: "line 1\nline 2\n"},
+		"F": {Time: "0 R0-5 3", Cmd: "random"},
+		"G": {Time: "0 0 9 * * MON-FRI", Cmd: "weekdays"},
+		"H": {Time: "0 0 9 L", Cmd: "last day"},
+	}
+	crontab, warnings := ExportCrontab(jobs)
+
+	lines := strings.Split(crontab, "\n")
+	var entries []string
+	for _, line := range lines {
+		if len(line) > 0 && line[0] != '#' {
+			entries = append(entries, line)
+		}
+	}
+	require.Equal(t, 4, len(entries), "%v", crontab)
+	require.Equal(t, `*/5 * * * 1-5 echo 100\%`, entries[0])
+	require.Equal(t, "0 12 * * * backup", entries[1])
+	require.Regexp(t, "^[0-5] 3 \\* \\* \\* random$", entries[2])
+	require.Equal(t, "0 9 * * 1,2,3,4,5 weekdays", entries[3])
+
+	// warnings for B's error handler, C, D, E, F's random spec, and H
+	require.Equal(t, 6, len(warnings), "%v", warnings)
+	for i, prefix := range []string{"B:", "C:", "D:", "E:", "F:", "H:"} {
+		require.True(t, strings.HasPrefix(warnings[i], prefix), "%v",
+			warnings[i])
+	}
+}
@@ -0,0 +1,260 @@
+package jobfile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dshearer/jobber/common"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+These functions edit the text of a jobfile, rather than a parsed
+jobfile, so that they can preserve the comments and layout of the
+parts of the jobfile that they don't change.  They only work on
+jobfiles of version 1.4 and later.
+*/
+
+const gDefaultYamlIndent = 2
+
+/*
+Parse the text of a jobfile of version 1.4 or later.
+*/
+func ParseJobfileText(text string) (*JobFileRaw, error) {
+	if err := checkEditableText(text); err != nil {
+		return nil, err
+	}
+	return parseV3JobfileData([]byte(text))
+}
+
+/*
+Return the text of a jobfile that is like the given one, except that
+it has the given job (replacing any existing job with the same name).
+The given text may be empty, in which case a new jobfile is made.
+*/
+func SetJobInText(text string, jobName string, job JobRaw) (string, error) {
+	if len(strings.TrimSpace(text)) == 0 {
+		text = fmt.Sprintf("version: %v\n\n%v:\n", SemVer{Major: 1, Minor: 4},
+			JobsSectName)
+	}
+	if err := checkEditableText(text); err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(text, "\n")
+	jobsIdx, err := findJobsSection(lines)
+	if err != nil {
+		return "", err
+	}
+	if jobsIdx < 0 {
+		// add jobs section
+		if len(lines[len(lines)-1]) == 0 {
+			lines = lines[:len(lines)-1]
+		}
+		lines = append(lines, "", JobsSectName+":", "")
+		jobsIdx = len(lines) - 2
+	}
+
+	// serialize job
+	indent := jobsChildIndent(lines, jobsIdx)
+	jobLines, err := jobToLines(jobName, job, indent)
+	if err != nil {
+		return "", err
+	}
+
+	found := findKeyPath(lines, []string{JobsSectName, jobName})
+	var newLines []string
+	if len(found) == 2 {
+		// replace existing job
+		start := found[1]
+		end := yamlBlockEnd(lines, start)
+		newLines = append(newLines, lines[:start]...)
+		newLines = append(newLines, jobLines...)
+		newLines = append(newLines, lines[end:]...)
+	} else {
+		// append new job to end of jobs section
+		end := yamlBlockEnd(lines, jobsIdx)
+		newLines = append(newLines, lines[:end]...)
+		if hasJobs(lines, jobsIdx) &&
+			len(strings.TrimSpace(lines[end-1])) > 0 {
+			newLines = append(newLines, "")
+		}
+		newLines = append(newLines, jobLines...)
+		newLines = append(newLines, lines[end:]...)
+	}
+	return ensureTrailingNewline(strings.Join(newLines, "\n")), nil
+}
+
+/*
+Return the text of a jobfile that is like the given one, except that it
+doesn't have the job with the given name.  Returns an error if there is
+no such job.
+*/
+func DeleteJobFromText(text string, jobName string) (string, error) {
+	if err := checkEditableText(text); err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(text, "\n")
+	found := findKeyPath(lines, []string{JobsSectName, jobName})
+	if len(found) != 2 {
+		msg := fmt.Sprintf("No job named \"%v\" in jobfile", jobName)
+		return "", &common.Error{What: msg}
+	}
+	jobsIndent := indentOf(lines[found[0]])
+	start := found[1]
+	end := yamlBlockEnd(lines, start)
+
+	// also remove comments right before the job
+	isBlank := func(line string) bool {
+		return len(strings.TrimSpace(line)) == 0
+	}
+	for start > 0 && isYamlFiller(lines[start-1]) && !isBlank(lines[start-1]) &&
+		indentOf(lines[start-1]) == indentOf(lines[found[1]]) {
+		start--
+	}
+
+	// also remove blank lines that separated the job from its neighbor
+	next := end
+	for next < len(lines) && isBlank(lines[next]) {
+		next++
+	}
+	if next < len(lines) && indentOf(lines[next]) > jobsIndent {
+		end = next
+	} else {
+		for start > 0 && isBlank(lines[start-1]) {
+			start--
+		}
+	}
+
+	newLines := append(append([]string{}, lines[:start]...), lines[end:]...)
+	return ensureTrailingNewline(strings.Join(newLines, "\n")), nil
+}
+
+func checkEditableText(text string) error {
+	version, err := jobfileVersionOfData([]byte(text))
+	if err != nil {
+		return err
+	}
+	if version.Compare(SemVer{Major: 1, Minor: 4}) < 0 {
+		msg := fmt.Sprintf("Cannot edit jobfiles older than version 1.4 "+
+			"(this one is version %v)", version)
+		return &common.Error{What: msg}
+	}
+	return nil
+}
+
+/*
+Find the line with the "jobs" key.  Returns -1 if there is no such
+line.  If the jobs section is written in flow style, makes it block
+style if it is empty and returns an error otherwise.
+*/
+func findJobsSection(lines []string) (int, error) {
+	found := findKeyPath(lines, []string{JobsSectName})
+	if len(found) == 0 {
+		return -1, nil
+	}
+	idx := found[0]
+
+	// check value
+	line := lines[idx]
+	colonIdx := strings.Index(line, ":")
+	value := strings.TrimSpace(line[colonIdx+1:])
+	if commentIdx := strings.Index(value, " #"); commentIdx >= 0 {
+		value = strings.TrimSpace(value[:commentIdx])
+	} else if strings.HasPrefix(value, "#") {
+		value = ""
+	}
+	switch value {
+	case "":
+		return idx, nil
+	case "~", "null", "{}":
+		lines[idx] = line[:colonIdx+1]
+		return idx, nil
+	default:
+		msg := fmt.Sprintf("Cannot edit \"%v\" section written in flow "+
+			"style", JobsSectName)
+		return -1, &common.Error{What: msg}
+	}
+}
+
+/*
+Return whether the jobs section has any jobs.
+*/
+func hasJobs(lines []string, jobsIdx int) bool {
+	jobsIndent := indentOf(lines[jobsIdx])
+	for i := jobsIdx + 1; i < len(lines); i++ {
+		if isYamlFiller(lines[i]) {
+			continue
+		}
+		return indentOf(lines[i]) > jobsIndent
+	}
+	return false
+}
+
+/*
+Get the indentation of the jobs in the jobs section.
+*/
+func jobsChildIndent(lines []string, jobsIdx int) int {
+	jobsIndent := indentOf(lines[jobsIdx])
+	for i := jobsIdx + 1; i < len(lines); i++ {
+		if isYamlFiller(lines[i]) {
+			continue
+		}
+		if indent := indentOf(lines[i]); indent > jobsIndent {
+			return indent
+		}
+		break
+	}
+	return jobsIndent + gDefaultYamlIndent
+}
+
+/*
+Get the index of the line just after the block that starts with the
+key on the given line.  The block includes all the following lines
+that are indented more than the key, as well as comments among them
+and comments right after them that are indented more than the key.
+*/
+func yamlBlockEnd(lines []string, keyIdx int) int {
+	keyIndent := indentOf(lines[keyIdx])
+	end := keyIdx + 1
+	for i := keyIdx + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if len(trimmed) == 0 {
+			continue
+		}
+		if trimmed[0] == '#' {
+			if indentOf(lines[i]) > keyIndent {
+				end = i + 1
+			}
+			continue
+		}
+		if indentOf(lines[i]) <= keyIndent {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+func jobToLines(jobName string, job JobRaw, indent int) ([]string, error) {
+	data, err := yaml.Marshal(map[string]JobRaw{jobName: job})
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Repeat(" ", indent)
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i := range lines {
+		if len(lines[i]) > 0 {
+			lines[i] = prefix + lines[i]
+		}
+	}
+	return lines, nil
+}
+
+func ensureTrailingNewline(s string) string {
+	if !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+	return s
+}
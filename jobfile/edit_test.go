@@ -0,0 +1,258 @@
+package jobfile
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const gEditTestJobfile = `version: 1.4
+
+# My prefs
+prefs:
+  logPath: .jobber-log
+
+jobs:
+  # Backs things up
+  Backup:
+    cmd: backup  # inline comment
+    time: 0 0 14
+
+  # Cleans things up
+  Cleanup:
+    cmd: |
+      rm -rf /tmp/junk
+
+      echo done
+    time: 0 0 15
+    # trailing comment
+
+# The end
+`
+
+type SetJobInTextTestCase struct {
+	Input   string
+	JobName string
+	Job     JobRaw
+	Output  string
+	Error   bool
+}
+
+var gSetJobInTextTestCases = []SetJobInTextTestCase{
+	// replace job
+	{
+		Input:   gEditTestJobfile,
+		JobName: "Backup",
+		Job:     JobRaw{Cmd: "backup --all", Time: "0 0 13"},
+		Output: `version: 1.4
+
+# My prefs
+prefs:
+  logPath: .jobber-log
+
+jobs:
+  # Backs things up
+  Backup:
+    cmd: backup --all
+    time: 0 0 13
+
+  # Cleans things up
+  Cleanup:
+    cmd: |
+      rm -rf /tmp/junk
+
+      echo done
+    time: 0 0 15
+    # trailing comment
+
+# The end
+`,
+	},
+
+	// add job
+	{
+		Input:   gEditTestJobfile,
+		JobName: "New",
+		Job:     JobRaw{Cmd: "echo new", Time: "0 0 1"},
+		Output: `version: 1.4
+
+# My prefs
+prefs:
+  logPath: .jobber-log
+
+jobs:
+  # Backs things up
+  Backup:
+    cmd: backup  # inline comment
+    time: 0 0 14
+
+  # Cleans things up
+  Cleanup:
+    cmd: |
+      rm -rf /tmp/junk
+
+      echo done
+    time: 0 0 15
+    # trailing comment
+
+  New:
+    cmd: echo new
+    time: 0 0 1
+
+# The end
+`,
+	},
+
+	// add job to empty jobs section with commented-out examples
+	{
+		Input: `version: 1.4
+
+jobs:
+  #Example:
+  #  cmd: echo hi
+`,
+		JobName: "New",
+		Job:     JobRaw{Cmd: "echo new", Time: "0 0 1"},
+		Output: `version: 1.4
+
+jobs:
+  #Example:
+  #  cmd: echo hi
+  New:
+    cmd: echo new
+    time: 0 0 1
+`,
+	},
+
+	// add job to jobfile without jobs section
+	{
+		Input:   "version: 1.4\n",
+		JobName: "New",
+		Job:     JobRaw{Cmd: "echo new", Time: "0 0 1"},
+		Output: `version: 1.4
+
+jobs:
+  New:
+    cmd: echo new
+    time: 0 0 1
+`,
+	},
+
+	// add job to flow-style empty jobs section
+	{
+		Input:   "version: 1.4\njobs: {}\n",
+		JobName: "New",
+		Job:     JobRaw{Cmd: "echo new", Time: "0 0 1"},
+		Output: `version: 1.4
+jobs:
+  New:
+    cmd: echo new
+    time: 0 0 1
+`,
+	},
+
+	// add job to empty jobfile
+	{
+		Input:   "",
+		JobName: "New",
+		Job:     JobRaw{Cmd: "echo new", Time: "0 0 1"},
+		Output: `version: 1.4
+
+jobs:
+  New:
+    cmd: echo new
+    time: 0 0 1
+`,
+	},
+
+	// non-empty flow-style jobs section
+	{
+		Input:   "version: 1.4\njobs: {A: {cmd: a, time: '0'}}\n",
+		JobName: "New",
+		Job:     JobRaw{Cmd: "echo new", Time: "0 0 1"},
+		Error:   true,
+	},
+
+	// old jobfile format
+	{
+		Input: `[jobs]
+- name: A
+  cmd: a
+  time: 0
+`,
+		JobName: "New",
+		Job:     JobRaw{Cmd: "echo new", Time: "0 0 1"},
+		Error:   true,
+	},
+}
+
+func TestSetJobInText(t *testing.T) {
+	for _, testCase := range gSetJobInTextTestCases {
+		fmt.Printf("Input:\n%v\n", testCase.Input)
+
+		output, err := SetJobInText(testCase.Input, testCase.JobName,
+			testCase.Job)
+
+		if testCase.Error {
+			require.NotNil(t, err)
+			continue
+		}
+		require.Nil(t, err, "%v", err)
+		require.Equal(t, testCase.Output, output)
+
+		// make sure result is valid
+		raw, err := ParseJobfileText(output)
+		require.Nil(t, err, "%v", err)
+		require.Equal(t, testCase.Job, raw.Jobs[testCase.JobName])
+	}
+}
+
+func TestDeleteJobFromText(t *testing.T) {
+	// delete first job
+	output, err := DeleteJobFromText(gEditTestJobfile, "Backup")
+	require.Nil(t, err, "%v", err)
+	require.Equal(t, `version: 1.4
+
+# My prefs
+prefs:
+  logPath: .jobber-log
+
+jobs:
+  # Cleans things up
+  Cleanup:
+    cmd: |
+      rm -rf /tmp/junk
+
+      echo done
+    time: 0 0 15
+    # trailing comment
+
+# The end
+`, output)
+
+	// delete last job
+	output, err = DeleteJobFromText(gEditTestJobfile, "Cleanup")
+	require.Nil(t, err, "%v", err)
+	require.Equal(t, `version: 1.4
+
+# My prefs
+prefs:
+  logPath: .jobber-log
+
+jobs:
+  # Backs things up
+  Backup:
+    cmd: backup  # inline comment
+    time: 0 0 14
+
+# The end
+`, output)
+	raw, err := ParseJobfileText(output)
+	require.Nil(t, err, "%v", err)
+	require.Equal(t, 1, len(raw.Jobs))
+
+	// delete nonexistent job
+	_, err = DeleteJobFromText(gEditTestJobfile, "Nope")
+	require.NotNil(t, err)
+}
@@ -2,20 +2,41 @@ package jobfile
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dshearer/jobber/common"
 )
 
 const (
-	ErrorHandlerStopName     = "Stop"
-	ErrorHandlerBackoffName  = "Backoff"
-	ErrorHandlerContinueName = "Continue"
+	ErrorHandlerStopName      = "Stop"
+	ErrorHandlerBackoffName   = "Backoff"
+	ErrorHandlerContinueName  = "Continue"
+	ErrorHandlerStopAfterName = "stop-after"
+	ErrorHandlerCooldownName  = "cooldown"
 
 	MaxBackoffWait = 8
 )
 
+/*
+An error handler decides what happens to a job when it fails (after any
+retries), by setting its status.
+
+Handlers that can mark a job as Failed may have a cooldown: a Failed job
+is run again (at its next scheduled time) once the cooldown has passed
+since it failed.  If that run succeeds, the job is Good again.
+*/
 type ErrorHandler interface {
 	Handle(job *Job)
+
+	/*
+		How long after a job is marked Failed it should be tried again
+		(0 means never).
+	*/
+	Cooldown() time.Duration
+
 	fmt.Stringer
 }
 
@@ -25,21 +46,77 @@ func (self ContinueErrorHandler) Handle(job *Job) {
 	job.Status = JobGood
 }
 
+func (self ContinueErrorHandler) Cooldown() time.Duration {
+	return 0
+}
+
 func (self ContinueErrorHandler) String() string {
 	return ErrorHandlerContinueName
 }
 
-type StopErrorHandler struct{}
+/*
+Marks the job as Failed.  With a cooldown, this is "cooldown(DURATION)".
+*/
+type StopErrorHandler struct {
+	CooldownDur time.Duration
+}
 
 func (self StopErrorHandler) Handle(job *Job) {
 	job.Status = JobFailed
 }
 
+func (self StopErrorHandler) Cooldown() time.Duration {
+	return self.CooldownDur
+}
+
 func (self StopErrorHandler) String() string {
+	if self.CooldownDur > 0 {
+		return fmt.Sprintf("%v(%v)", ErrorHandlerCooldownName, self.CooldownDur)
+	}
 	return ErrorHandlerStopName
 }
 
-type BackoffErrorHandler struct{}
+/*
+Marks the job as Failed after N consecutive failures.
+*/
+type StopAfterErrorHandler struct {
+	N           int
+	CooldownDur time.Duration
+}
+
+func (self StopAfterErrorHandler) Handle(job *Job) {
+	if job.ConsecutiveFailures >= self.N {
+		job.Status = JobFailed
+	} else {
+		job.Status = JobGood
+	}
+}
+
+func (self StopAfterErrorHandler) Cooldown() time.Duration {
+	return self.CooldownDur
+}
+
+func (self StopAfterErrorHandler) String() string {
+	return formatErrorHandler(ErrorHandlerStopAfterName,
+		fmt.Sprintf("n=%v", self.N), self.CooldownDur)
+}
+
+/*
+Skips chances to run after failures, and eventually marks the job as
+Failed.  Max is the largest number of chances to skip (0 means
+MaxBackoffWait).
+*/
+type BackoffErrorHandler struct {
+	Max         int
+	CooldownDur time.Duration
+}
+
+func (self BackoffErrorHandler) max() int {
+	if self.Max == 0 {
+		return MaxBackoffWait
+	}
+	return self.Max
+}
 
 func (self BackoffErrorHandler) Handle(job *Job) {
 	/*
@@ -49,7 +126,7 @@ func (self BackoffErrorHandler) Handle(job *Job) {
 	   (i.e., job.Status == JobGood), then N will be 1;
 	   otherwise, N will be twice the amount of chances
 	   previously skipped.  If N is greater than
-	   MaxBackoffWait, however, we mark this job as
+	   self.max(), however, we mark this job as
 	   "Failed" and don't run it again.
 
 	   We use two variables: backoffLevel and skipsLeft.
@@ -60,13 +137,17 @@ func (self BackoffErrorHandler) Handle(job *Job) {
 	   and returns false if skipsLeft > 0, true otherwise.
 	*/
 
-	if job.Status == JobGood {
+	switch job.Status {
+	case JobFailed:
+		/* it failed again after its cooldown */
+		return
+	case JobGood:
 		job.Status = JobBackoff
 		job.backoffLevel = 1
-	} else {
+	default:
 		job.backoffLevel *= 2
 	}
-	if job.backoffLevel > MaxBackoffWait {
+	if job.backoffLevel > self.max() {
 		// give up
 		job.Status = JobFailed
 		job.backoffLevel = 0
@@ -76,19 +157,156 @@ func (self BackoffErrorHandler) Handle(job *Job) {
 	}
 }
 
+func (self BackoffErrorHandler) Cooldown() time.Duration {
+	return self.CooldownDur
+}
+
 func (self BackoffErrorHandler) String() string {
-	return ErrorHandlerBackoffName
+	if self.Max == 0 && self.CooldownDur == 0 {
+		return ErrorHandlerBackoffName
+	}
+	return formatErrorHandler(strings.ToLower(ErrorHandlerBackoffName),
+		fmt.Sprintf("max=%v", self.max()), self.CooldownDur)
+}
+
+func formatErrorHandler(name string, arg string, cooldown time.Duration) string {
+	if cooldown > 0 {
+		arg += fmt.Sprintf(", cooldown=%v", cooldown)
+	}
+	return fmt.Sprintf("%v(%v)", name, arg)
 }
 
-func GetErrorHandler(name string) (ErrorHandler, error) {
+var gErrorHandlerRegexp = regexp.MustCompile(`^\s*([A-Za-z-]+)\s*(?:\((.*)\))?\s*$`)
+
+/*
+Parse an error handler's spec: a name ("Stop", "Backoff", "Continue",
+"stop-after", or "cooldown"; case doesn't matter), optionally followed
+by arguments in parentheses:
+
+	backoff(max=32)           like Backoff, but skip up to 32 chances
+	stop-after(n=3)           become Failed after 3 consecutive failures
+	cooldown(1h)              like Stop, but try again after 1h
+	backoff(max=32, cooldown=1h)
+
+Stop, Backoff, and stop-after also take a "cooldown" argument.  The
+main argument may be given without its name (e.g., "stop-after(3)").
+*/
+func GetErrorHandler(spec string) (ErrorHandler, error) {
+	m := gErrorHandlerRegexp.FindStringSubmatch(spec)
+	if m == nil {
+		return nil, &common.Error{What: "Invalid error handler: " + spec}
+	}
+	name := strings.ToLower(m[1])
+	args, err := parseErrorHandlerArgs(m[2])
+	if err != nil {
+		return nil, &common.Error{What: "Invalid error handler: " + spec,
+			Cause: err}
+	}
+
+	/* the name of the positional argument, and of the others allowed */
+	var mainArg string
+	allowed := map[string]bool{"cooldown": true}
 	switch name {
-	case ErrorHandlerStopName:
-		return StopErrorHandler{}, nil
-	case ErrorHandlerBackoffName:
-		return BackoffErrorHandler{}, nil
-	case ErrorHandlerContinueName:
+	case strings.ToLower(ErrorHandlerContinueName):
+		allowed = nil
+	case strings.ToLower(ErrorHandlerStopName):
+	case strings.ToLower(ErrorHandlerBackoffName):
+		mainArg = "max"
+	case ErrorHandlerStopAfterName:
+		mainArg = "n"
+	case ErrorHandlerCooldownName:
+		mainArg = "cooldown"
+	default:
+		return nil, &common.Error{What: "Invalid error handler: " + spec}
+	}
+	if value, ok := args[""]; ok && len(mainArg) > 0 {
+		if _, ok := args[mainArg]; ok {
+			msg := fmt.Sprintf("Invalid error handler: %v: repeated "+
+				"argument", spec)
+			return nil, &common.Error{What: msg}
+		}
+		args[mainArg] = value
+		delete(args, "")
+	}
+	if len(mainArg) > 0 {
+		allowed[mainArg] = true
+	}
+	for key := range args {
+		if !allowed[key] {
+			msg := fmt.Sprintf("Invalid error handler: %v: unexpected "+
+				"argument", spec)
+			return nil, &common.Error{What: msg}
+		}
+	}
+
+	// parse args
+	var cooldown time.Duration
+	if s, ok := args["cooldown"]; ok {
+		cooldown, err = time.ParseDuration(s)
+		if err != nil || cooldown <= 0 {
+			msg := fmt.Sprintf("Invalid error handler: %v: invalid cooldown",
+				spec)
+			return nil, &common.Error{What: msg, Cause: err}
+		}
+	}
+	var n int
+	if s, ok := args[mainArg]; ok && mainArg != "cooldown" {
+		n, err = strconv.Atoi(s)
+		if err != nil || n < 1 {
+			msg := fmt.Sprintf("Invalid error handler: %v: %v must be a "+
+				"positive integer", spec, mainArg)
+			return nil, &common.Error{What: msg}
+		}
+	}
+
+	switch name {
+	case strings.ToLower(ErrorHandlerContinueName):
 		return ContinueErrorHandler{}, nil
+	case strings.ToLower(ErrorHandlerStopName):
+		return StopErrorHandler{CooldownDur: cooldown}, nil
+	case strings.ToLower(ErrorHandlerBackoffName):
+		return BackoffErrorHandler{Max: n, CooldownDur: cooldown}, nil
+	case ErrorHandlerStopAfterName:
+		if n == 0 {
+			msg := fmt.Sprintf("Invalid error handler: %v: missing n", spec)
+			return nil, &common.Error{What: msg}
+		}
+		return StopAfterErrorHandler{N: n, CooldownDur: cooldown}, nil
 	default:
-		return nil, &common.Error{What: "Invalid error handler: " + name}
+		if cooldown == 0 {
+			msg := fmt.Sprintf("Invalid error handler: %v: missing "+
+				"duration", spec)
+			return nil, &common.Error{What: msg}
+		}
+		return StopErrorHandler{CooldownDur: cooldown}, nil
+	}
+}
+
+/*
+Parse the comma-separated arguments of an error handler spec, each of
+which is either "KEY=VALUE" or (for the main argument) just "VALUE".
+The latter is returned with key "".
+*/
+func parseErrorHandlerArgs(s string) (map[string]string, error) {
+	args := make(map[string]string)
+	if len(strings.TrimSpace(s)) == 0 {
+		return args, nil
+	}
+	for _, arg := range strings.Split(s, ",") {
+		var key, value string
+		if i := strings.Index(arg, "="); i >= 0 {
+			key = strings.ToLower(strings.TrimSpace(arg[:i]))
+			value = strings.TrimSpace(arg[i+1:])
+		} else {
+			value = strings.TrimSpace(arg)
+		}
+		if len(value) == 0 {
+			return nil, &common.Error{What: "Empty argument"}
+		}
+		if _, ok := args[key]; ok {
+			return nil, &common.Error{What: "Repeated argument"}
+		}
+		args[key] = value
 	}
+	return args, nil
 }
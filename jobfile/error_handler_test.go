@@ -0,0 +1,98 @@
+package jobfile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetErrorHandler(t *testing.T) {
+	cases := []struct {
+		spec    string
+		handler ErrorHandler
+		str     string
+	}{
+		{"Stop", StopErrorHandler{}, "Stop"},
+		{"Backoff", BackoffErrorHandler{}, "Backoff"},
+		{"Continue", ContinueErrorHandler{}, "Continue"},
+		{"continue()", ContinueErrorHandler{}, "Continue"},
+		{"backoff(max=32)", BackoffErrorHandler{Max: 32}, "backoff(max=32)"},
+		{"Backoff(4, cooldown=1h)",
+			BackoffErrorHandler{Max: 4, CooldownDur: time.Hour},
+			"backoff(max=4, cooldown=1h0m0s)"},
+		{"stop-after(n=3)", StopAfterErrorHandler{N: 3}, "stop-after(n=3)"},
+		{" stop-after( 3 ) ", StopAfterErrorHandler{N: 3}, "stop-after(n=3)"},
+		{"cooldown(1h)", StopErrorHandler{CooldownDur: time.Hour},
+			"cooldown(1h0m0s)"},
+		{"stop(cooldown=30m)", StopErrorHandler{CooldownDur: 30 * time.Minute},
+			"cooldown(30m0s)"},
+	}
+	for _, c := range cases {
+		handler, err := GetErrorHandler(c.spec)
+		require.Nil(t, err, "%v: %v", c.spec, err)
+		require.Equal(t, c.handler, handler, c.spec)
+		require.Equal(t, c.str, handler.String(), c.spec)
+
+		// the string form parses to the same handler
+		again, err := GetErrorHandler(handler.String())
+		require.Nil(t, err, "%v: %v", handler, err)
+		require.Equal(t, handler, again, c.spec)
+	}
+
+	for _, spec := range []string{
+		"",
+		"Explode",
+		"Stop(3)",
+		"Continue(cooldown=1h)",
+		"backoff(max=0)",
+		"backoff(max=x)",
+		"backoff(2, max=3)",
+		"backoff(min=3)",
+		"stop-after",
+		"stop-after(n=3",
+		"cooldown",
+		"cooldown(soon)",
+		"cooldown(-1h)",
+	} {
+		_, err := GetErrorHandler(spec)
+		require.NotNil(t, err, "%v", spec)
+	}
+}
+
+func TestBackoffErrorHandlerMax(t *testing.T) {
+	job := Job{ErrorHandler: BackoffErrorHandler{Max: 2}}
+	expectedStatuses := []JobStatus{JobBackoff, JobBackoff, JobFailed, JobFailed}
+	for _, expected := range expectedStatuses {
+		job.ErrorHandler.Handle(&job)
+		require.Equal(t, expected, job.Status)
+	}
+}
+
+func TestStopAfterErrorHandler(t *testing.T) {
+	job := Job{ErrorHandler: StopAfterErrorHandler{N: 3}}
+	for i := 1; i <= 3; i++ {
+		job.ConsecutiveFailures = i
+		job.ErrorHandler.Handle(&job)
+		if i < 3 {
+			require.Equal(t, JobGood, job.Status, "%v", i)
+		} else {
+			require.Equal(t, JobFailed, job.Status, "%v", i)
+		}
+	}
+}
+
+func TestShouldRunAfterCooldown(t *testing.T) {
+	failedTime := localDate(2026, 11, 1, 2, 0)
+	job := Job{
+		ErrorHandler: StopErrorHandler{CooldownDur: time.Hour},
+		Status:       JobFailed,
+		FailedTime:   failedTime,
+	}
+	require.False(t, job.ShouldRun(failedTime.Add(59*time.Minute)))
+	require.True(t, job.ShouldRun(failedTime.Add(time.Hour)))
+
+	// without a cooldown, a Failed job never runs
+	job.ErrorHandler = StopErrorHandler{}
+	require.False(t, job.ShouldRun(failedTime.Add(1000*time.Hour)))
+}
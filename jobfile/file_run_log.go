@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dshearer/jobber/common"
 )
@@ -54,7 +55,9 @@ func (self *fileRunLog) String() string {
 
 /*
 A backing file consists of one or more gLogEntryLen-byte entries separated by
-'\n'.
+'\n'.  (Backing files made by older versions of Jobber have
+gLegacyLogEntryLen-byte entries instead.  We can still read them, and
+we convert the current backing file to the new format when we open it.)
 
 m = number of entries in current backing file
 s = smallest entry index in a backing file
@@ -90,12 +93,22 @@ Backing file 0     Backing file 1     Backing file 2      Backing file k
 */
 
 const (
-	gMaxJobNameLen int64 = 16
-	gLogEntryLen   int64 = 64
+	gMaxJobNameLen       int64 = 64
+	gLegacyMaxJobNameLen int64 = 16 // in entries made by older versions
+	gLogEntryLen         int64 = 256
+	gLegacyLogEntryLen   int64 = 64
 )
 
+/*
+The max length of a one-shot job's name.  (We tell whether a one-shot
+job has fired by looking for its runs in the run log, which keeps only
+this much of job names.)
+*/
+const MaxOneShotJobNameLen = int(gMaxJobNameLen)
+
 type backingFileDtor struct {
 	path         string
+	entryLen     int64 // gLogEntryLen or gLegacyLogEntryLen
 	nbrEntries   int
 	earliestTime time.Time // of 1st entry
 	latestTime   time.Time // of last entry
@@ -110,7 +123,7 @@ func (self *backingFileDtor) offsetOfEntry(idx int) int64 {
 	if idx >= self.nbrEntries {
 		panic("Invalid entry index")
 	}
-	return int64(self.nbrEntries-idx-1) * (gLogEntryLen + 1)
+	return int64(self.nbrEntries-idx-1) * (self.entryLen + 1)
 }
 
 /*
@@ -121,7 +134,7 @@ func (self *backingFileDtor) readEntryBytes(idx int,
 	f *os.File) ([]byte, error) {
 
 	offset := self.offsetOfEntry(idx)
-	buf := make([]byte, gLogEntryLen)
+	buf := make([]byte, self.entryLen)
 	if _, err := f.ReadAt(buf, offset); err != nil {
 		msg := fmt.Sprintf("Failed to read backing file %v", self.path)
 		return nil, &common.Error{What: msg, Cause: err}
@@ -239,7 +252,7 @@ func (self *backingFileDtor) popEntryF(f *os.File) (*RunLogEntry, error) {
 	self.nbrEntries--
 
 	// calc new file len
-	newFileLen := int64(self.nbrEntries-1)*(gLogEntryLen+1) + gLogEntryLen
+	newFileLen := int64(self.nbrEntries-1)*(self.entryLen+1) + self.entryLen
 
 	// truncate file
 	if err = f.Truncate(newFileLen); err != nil {
@@ -254,7 +267,7 @@ Return whether this file can take another entry.
 */
 func (self *backingFileDtor) isFull(maxFileLen int64) bool {
 	// compute new entry's len
-	newEntryLen := gLogEntryLen
+	newEntryLen := self.entryLen
 	if self.nbrEntries > 0 {
 		newEntryLen += 1
 	}
@@ -262,10 +275,10 @@ func (self *backingFileDtor) isFull(maxFileLen int64) bool {
 	// compute backing file's current len
 	var fileLen int64 = 0
 	if self.nbrEntries == 1 {
-		fileLen = gLogEntryLen
+		fileLen = self.entryLen
 	} else if self.nbrEntries > 1 {
-		fileLen = int64(self.nbrEntries-1)*(gLogEntryLen+1) +
-			gLogEntryLen
+		fileLen = int64(self.nbrEntries-1)*(self.entryLen+1) +
+			self.entryLen
 	}
 
 	return fileLen+newEntryLen > maxFileLen
@@ -275,7 +288,12 @@ func (self *backingFileDtor) isFull(maxFileLen int64) bool {
 Returns nil if there is no such file.
 */
 func makeBackingFileDtor(path string, startIdx int) (*backingFileDtor, error) {
-	dtor := backingFileDtor{path: path, nbrEntries: 0, startIdx: startIdx}
+	dtor := backingFileDtor{
+		path:       path,
+		entryLen:   gLogEntryLen,
+		nbrEntries: 0,
+		startIdx:   startIdx,
+	}
 
 	// open file
 	f, err := os.Open(path)
@@ -294,25 +312,34 @@ func makeBackingFileDtor(path string, startIdx int) (*backingFileDtor, error) {
 		return nil, err
 	}
 
+	// detect format
+	isLegacy, err := isLegacyBackingFile(f, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	if isLegacy {
+		dtor.entryLen = gLegacyLogEntryLen
+	}
+
 	// count entries
 	if stat.Size() == 0 {
 		dtor.nbrEntries = 0
-	} else if stat.Size() < gLogEntryLen {
+	} else if stat.Size() < dtor.entryLen {
 		msg := fmt.Sprintf(
 			"Invalid log file: %v: size is less than entry size",
 			path,
 		)
 		return nil, &common.Error{What: msg}
 	} else {
-		remainder := stat.Size() - gLogEntryLen
-		if remainder%(gLogEntryLen+1) != 0 {
+		remainder := stat.Size() - dtor.entryLen
+		if remainder%(dtor.entryLen+1) != 0 {
 			msg := fmt.Sprintf(
 				"Invalid log file: %v: size is not multiple of entry size",
 				path,
 			)
 			return nil, &common.Error{What: msg}
 		} else {
-			dtor.nbrEntries = int(remainder/(gLogEntryLen+1) + 1)
+			dtor.nbrEntries = int(remainder/(dtor.entryLen+1) + 1)
 		}
 	}
 
@@ -335,6 +362,72 @@ func makeBackingFileDtor(path string, startIdx int) (*backingFileDtor, error) {
 	return &dtor, nil
 }
 
+/*
+Return whether the given backing file has gLegacyLogEntryLen-byte
+entries.  In such a file, there is either exactly one entry, or the
+byte after the first entry is '\n'.  In a file with gLogEntryLen-byte
+entries, that byte is part of the first entry, and entries never
+contain '\n'.
+*/
+func isLegacyBackingFile(f *os.File, size int64) (bool, error) {
+	if size == gLegacyLogEntryLen {
+		return true, nil
+	}
+	if size <= gLegacyLogEntryLen {
+		return false, nil
+	}
+	var buf [1]byte
+	if _, err := f.ReadAt(buf[:], gLegacyLogEntryLen); err != nil {
+		return false, err
+	}
+	return buf[0] == '\n', nil
+}
+
+/*
+Rewrite the given backing file so that it uses gLogEntryLen-byte
+entries.
+*/
+func convertLegacyBackingFile(dtor *backingFileDtor) error {
+	f, err := os.Open(dtor.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tmpF, err := ioutil.TempFile(common.TempDirPath(), "newBackingFile")
+	if err != nil {
+		msg := "Failed to make temp file"
+		return &common.Error{What: msg, Cause: err}
+	}
+	newDtor := backingFileDtor{
+		path:     tmpF.Name(),
+		entryLen: gLogEntryLen,
+		startIdx: dtor.startIdx,
+	}
+	for i := dtor.nbrEntries - 1; i >= 0; i-- {
+		entry, err := dtor.readEntry(i, f)
+		if err == nil {
+			err = newDtor.pushEntryF(entry, tmpF)
+		}
+		if err != nil {
+			tmpF.Close()
+			os.Remove(tmpF.Name())
+			return err
+		}
+	}
+	tmpF.Close()
+	f.Close()
+
+	if err := renameRobust(newDtor.path, dtor.path); err != nil {
+		os.Remove(newDtor.path)
+		msg := "Failed to replace old backing file with new"
+		return &common.Error{What: msg, Cause: err}
+	}
+	newDtor.path = dtor.path
+	*dtor = newDtor
+	return nil
+}
+
 /*
 Makes an index of all the backing files.
 
@@ -354,12 +447,17 @@ func (self *fileRunLog) makeIndex() error {
 	}
 	if dtor == nil {
 		/* There is no current backing file.  Make one. */
-		dtor = &backingFileDtor{path: self.filePath}
+		dtor = &backingFileDtor{path: self.filePath, entryLen: gLogEntryLen}
 		tmp := [0]byte{}
 		err := ioutil.WriteFile(self.filePath, tmp[:], 0600)
 		if err != nil {
 			return err
 		}
+	} else if dtor.entryLen != gLogEntryLen {
+		/* We append to the current backing file, so it must be new-style. */
+		if err := convertLegacyBackingFile(dtor); err != nil {
+			return err
+		}
 	}
 	self.index = append(self.index, *dtor)
 
@@ -886,13 +984,8 @@ func encodeRunLogEntry(entry *RunLogEntry) string {
 	}
 	jobName := entry.JobName[:n]
 
-	// encode any newlines in the job name
-	jobNameParts := strings.Split(jobName, "\n")
-	encodedJobName := strings.Join(jobNameParts, "\\n")
-
-	// encode any tabs in the job name
-	jobNameParts = strings.Split(encodedJobName, "\t")
-	encodedJobName = strings.Join(jobNameParts, "\\t")
+	// encode any newlines and tabs in the job name
+	encodedJobName := escapeRunLogField(jobName)
 
 	// encode time as Unix Epoch in nanoseconds
 	encodedTime := entry.Time.UnixNano()
@@ -906,14 +999,208 @@ func encodeRunLogEntry(entry *RunLogEntry) string {
 		entry.Result,
 		entry.ExecTime,
 	)
+
+	/*
+		Optional fields are encoded as "key=value" after the positional
+		ones.  Readers ignore keys they don't know.  If they don't all
+		fit in the entry, the skip reason is truncated; if that isn't
+		enough, fields that don't fit are dropped.
+	*/
+	fields := encodeRunLogEntryOptFields(entry)
+	fitRunLogSkipReason(fields, int(gLogEntryLen)-len(tmp))
+	for _, field := range fields {
+		if int64(len(tmp)+1+len(field)) > gLogEntryLen {
+			continue
+		}
+		tmp += "\t" + field
+	}
+
 	suffix := strings.Repeat(" ", int(gLogEntryLen)-len(tmp))
 	return fmt.Sprintf("%v%v", tmp, suffix)
 }
 
+/*
+Whether a run log entry is for the job with the given name.  File run
+logs keep only the first gMaxJobNameLen bytes of job names (or, in
+entries made by older versions, the first gLegacyMaxJobNameLen).
+*/
+func runLogEntryIsFor(entry *RunLogEntry, jobName string) bool {
+	if entry.JobName == jobName {
+		return true
+	}
+	n := len(entry.JobName)
+	if int64(n) != gMaxJobNameLen && int64(n) != gLegacyMaxJobNameLen {
+		return false
+	}
+	return len(jobName) > n && jobName[:n] == entry.JobName
+}
+
+var gRunLogFieldEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\n", "\\n",
+	"\t", "\\t",
+)
+
+/*
+Encode any backslashes, newlines, and tabs in a field of a run log
+entry, as well as any spaces at its end (which would otherwise be taken
+for the entry's padding).
+*/
+func escapeRunLogField(s string) string {
+	trimmed := strings.TrimRight(s, " ")
+	return gRunLogFieldEscaper.Replace(trimmed) +
+		strings.Repeat("\\s", len(s)-len(trimmed))
+}
+
+func unescapeRunLogField(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case '\\':
+				b.WriteByte('\\')
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 's':
+				b.WriteByte(' ')
+			default:
+				/* not an escape sequence */
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+const gRunLogTruncatedSuffix = "..."
+
+/*
+If the optional fields don't fit in room bytes (including the tabs that
+precede them), truncate the skip reason (if any) so that they do.
+*/
+func fitRunLogSkipReason(fields []string, room int) {
+	skipIdx := -1
+	total := 0
+	for i, field := range fields {
+		total += 1 + len(field)
+		if strings.HasPrefix(field, gRunLogSkipKey+"=") {
+			skipIdx = i
+		}
+	}
+	if total <= room || skipIdx < 0 {
+		return
+	}
+
+	field := fields[skipIdx]
+	prefixLen := len(gRunLogSkipKey) + 1
+	keep := len(field) - (total - room) - len(gRunLogTruncatedSuffix)
+	if keep <= prefixLen {
+		/* not even a bit of it fits */
+		fields[skipIdx] = field[:prefixLen] + gRunLogTruncatedSuffix
+		return
+	}
+
+	/* don't cut a character or an escape sequence in half */
+	cut := prefixLen
+	for cut < keep {
+		n := 2
+		if field[cut] != '\\' {
+			_, n = utf8.DecodeRuneInString(field[cut:])
+		}
+		if cut+n > keep {
+			break
+		}
+		cut += n
+	}
+	fields[skipIdx] = field[:cut] + gRunLogTruncatedSuffix
+}
+
+const (
+	gRunLogManualKey  = "manual"
+	gRunLogRunIdKey   = "id"
+	gRunLogSkipKey    = "skip"
+	gRunLogAttemptKey = "attempt"
+	gRunLogQueuedKey  = "queued"
+	gRunLogLimitKey   = "limit"
+)
+
+func encodeRunLogEntryOptFields(entry *RunLogEntry) []string {
+	var fields []string
+	if entry.Manual {
+		fields = append(fields, gRunLogManualKey+"=1")
+	}
+	if len(entry.RunId) > 0 {
+		fields = append(fields, gRunLogRunIdKey+"="+entry.RunId)
+	}
+	if len(entry.SkipReason) > 0 {
+		fields = append(fields,
+			gRunLogSkipKey+"="+escapeRunLogField(entry.SkipReason))
+	}
+	if entry.Attempt > 0 {
+		fields = append(fields,
+			fmt.Sprintf("%v=%v", gRunLogAttemptKey, entry.Attempt))
+	}
+	if entry.QueueDelay > 0 {
+		fields = append(fields, fmt.Sprintf("%v=%v", gRunLogQueuedKey,
+			entry.QueueDelay.Round(time.Millisecond)))
+	}
+	if len(entry.LimitExceeded) > 0 {
+		fields = append(fields,
+			gRunLogLimitKey+"="+escapeRunLogField(entry.LimitExceeded))
+	}
+	return fields
+}
+
+func decodeRunLogEntryOptField(field string, entry *RunLogEntry) error {
+	parts := strings.SplitN(field, "=", 2)
+	if len(parts) != 2 {
+		msg := fmt.Sprintf("Invalid field in log entry line: \"%v\"", field)
+		return &common.Error{What: msg}
+	}
+	switch parts[0] {
+	case gRunLogManualKey:
+		entry.Manual = parts[1] == "1"
+	case gRunLogRunIdKey:
+		entry.RunId = parts[1]
+	case gRunLogSkipKey:
+		entry.SkipReason = unescapeRunLogField(parts[1])
+	case gRunLogAttemptKey:
+		attempt, err := strconv.Atoi(parts[1])
+		if err != nil {
+			msg := fmt.Sprintf("Invalid attempt in log entry line: \"%v\"",
+				parts[1])
+			return &common.Error{What: msg, Cause: err}
+		}
+		entry.Attempt = attempt
+	case gRunLogQueuedKey:
+		delay, err := time.ParseDuration(parts[1])
+		if err != nil {
+			msg := fmt.Sprintf("Invalid queue delay in log entry line: "+
+				"\"%v\"", parts[1])
+			return &common.Error{What: msg, Cause: err}
+		}
+		entry.QueueDelay = delay
+	case gRunLogLimitKey:
+		entry.LimitExceeded = unescapeRunLogField(parts[1])
+	}
+	return nil
+}
+
 var gFateDecoder = map[string]common.SubprocFate{
 	common.SubprocFateSucceeded.String(): common.SubprocFateSucceeded,
 	common.SubprocFateFailed.String():    common.SubprocFateFailed,
 	common.SubprocFateCancelled.String(): common.SubprocFateCancelled,
+	common.SubprocFateSkipped.String():   common.SubprocFateSkipped,
+	common.SubprocFateWarning.String():   common.SubprocFateWarning,
 
 	// deprecated values:
 	"true":  common.SubprocFateSucceeded,
@@ -929,17 +1216,14 @@ func decodeRunLogEntry(s string) (*RunLogEntry, error) {
 	// split string into fields
 	fields := strings.Split(s, "\t")
 	nbrFields := 5
-	if len(fields) != nbrFields {
-		msg := fmt.Sprintf("Wrong number of fields in log entry line: %v but expected %v",
+	if len(fields) < nbrFields {
+		msg := fmt.Sprintf("Wrong number of fields in log entry line: %v but expected at least %v",
 			len(fields), nbrFields)
 		return nil, &common.Error{What: msg}
 	}
 
 	// decode job name
-	jobNameParts := strings.Split(fields[0], "\\n")
-	entry.JobName = strings.Join(jobNameParts, "\n")
-	jobNameParts = strings.Split(entry.JobName, "\\t")
-	entry.JobName = strings.Join(jobNameParts, "\t")
+	entry.JobName = unescapeRunLogField(fields[0])
 
 	// decode time
 	unixTime, err := strconv.ParseInt(fields[1], 10, 64)
@@ -974,6 +1258,13 @@ func decodeRunLogEntry(s string) (*RunLogEntry, error) {
 		return nil, &common.Error{What: "Invalid 'ExecTime' field."}
 	}
 
+	// decode optional fields
+	for _, field := range fields[nbrFields:] {
+		if err := decodeRunLogEntryOptField(field, &entry); err != nil {
+			return nil, err
+		}
+	}
+
 	return &entry, nil
 }
 
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,9 +49,119 @@ var EntryEncodeDecodeTestCases = []EntryEncodeDecodeTestCase{
 		},
 		"My\\n\\nDumb\\tJob\t1506313655000000000\tcancelled\tGood\t1s           ",
 	},
+	{
+		RunLogEntry{
+			JobName:  "MyJob",
+			Time:     time.Unix(1506313655, 0),
+			Fate:     common.SubprocFateSucceeded,
+			Result:   JobGood,
+			ExecTime: time.Second,
+			Manual:   true,
+		},
+		"MyJob\t1506313655000000000\tsucceeded\tGood\t1s\tmanual=1",
+	},
+	{
+		RunLogEntry{
+			JobName:  "MyJob",
+			Time:     time.Unix(1506313655, 0),
+			Fate:     common.SubprocFateSucceeded,
+			Result:   JobGood,
+			ExecTime: time.Second,
+			RunId:    "20170925T042735Z-0a1b2c3d",
+		},
+		"MyJob\t1506313655000000000\tsucceeded\tGood\t1s\tid=20170925T042735Z-0a1b2c3d",
+	},
+	{
+		RunLogEntry{
+			JobName:    "MyJob",
+			Time:       time.Unix(1506313655, 0),
+			Fate:       common.SubprocFateSkipped,
+			Result:     JobGood,
+			SkipReason: "calendar holidays",
+		},
+		"MyJob\t1506313655000000000\tskipped\tGood\t0s\tskip=calendar holidays",
+	},
+	{
+		RunLogEntry{
+			JobName:    "MyJob",
+			Time:       time.Unix(1506313655, 0),
+			Fate:       common.SubprocFateSkipped,
+			Result:     JobGood,
+			SkipReason: "when: /a\tb\ndoesn't exist",
+			Attempt:    1,
+		},
+		"MyJob\t1506313655000000000\tskipped\tGood\t0s\tskip=when: /a\\tb\\ndoesn't exist\tattempt=1",
+	},
+	{
+		RunLogEntry{
+			JobName:  "MyJob",
+			Time:     time.Unix(1506313655, 0),
+			Fate:     common.SubprocFateFailed,
+			Result:   JobGood,
+			ExecTime: time.Second,
+			Attempt:  2,
+		},
+		"MyJob\t1506313655000000000\tfailed\tGood\t1s\tattempt=2",
+	},
+	{
+		RunLogEntry{
+			JobName:    "MyJob",
+			Time:       time.Unix(1506313655, 0),
+			Fate:       common.SubprocFateSucceeded,
+			Result:     JobGood,
+			ExecTime:   time.Second,
+			QueueDelay: 2500 * time.Millisecond,
+		},
+		"MyJob\t1506313655000000000\tsucceeded\tGood\t1s\tqueued=2.5s",
+	},
+	{
+		RunLogEntry{
+			JobName:       "MyJob",
+			Time:          time.Unix(1506313655, 0),
+			Fate:          common.SubprocFateFailed,
+			Result:        JobGood,
+			ExecTime:      time.Second,
+			LimitExceeded: "CPU time limit (1s)",
+		},
+		"MyJob\t1506313655000000000\tfailed\tGood\t1s\tlimit=CPU time limit (1s)",
+	},
+	{
+		RunLogEntry{
+			JobName:  "MyJob",
+			Time:     time.Unix(1506313655, 0),
+			Fate:     common.SubprocFateWarning,
+			Result:   JobGood,
+			ExecTime: time.Second,
+		},
+		"MyJob\t1506313655000000000\twarning\tGood\t1s",
+	},
+	// backslashes and trailing spaces
+	{
+		RunLogEntry{
+			JobName:    "C:\\new\\tmp ",
+			Time:       time.Unix(1506313655, 0),
+			Fate:       common.SubprocFateSkipped,
+			Result:     JobGood,
+			ExecTime:   time.Second,
+			SkipReason: "when: cmd said \\n  ",
+		},
+		"C:\\\\new\\\\tmp\\s\t1506313655000000000\tskipped\tGood\t1s\t" +
+			"skip=when: cmd said \\\\n\\s\\s",
+	},
 }
 
 var EntryDecodeTestCases = []EntryEncodeDecodeTestCase{
+	// unknown optional fields
+	{
+		RunLogEntry{
+			JobName:  "MyJob",
+			Time:     time.Unix(1506313655, 0),
+			Fate:     common.SubprocFateSucceeded,
+			Result:   JobGood,
+			ExecTime: time.Second,
+		},
+		"MyJob\t1506313655000000000\tsucceeded\tGood\t1s\tfuture=thing    ",
+	},
 	// deprecated values for "Fate"
 	{
 		RunLogEntry{
@@ -76,11 +188,13 @@ var EntryDecodeTestCases = []EntryEncodeDecodeTestCase{
 func TestEntryEncodeDecode(t *testing.T) {
 	for _, testCase := range EntryEncodeDecodeTestCases {
 		// test encodeRunLogEntry
+		encoded := encodeRunLogEntry(&testCase.entry)
 		require.Equal(
 			t,
-			testCase.encoded,
-			encodeRunLogEntry(&testCase.entry),
+			strings.TrimRight(testCase.encoded, " "),
+			strings.TrimRight(encoded, " "),
 		)
+		require.Equal(t, int(gLogEntryLen), len(encoded))
 
 		// test decodeRunLogEntry
 		actualEntry, _ := decodeRunLogEntry(testCase.encoded)
@@ -88,6 +202,46 @@ func TestEntryEncodeDecode(t *testing.T) {
 	}
 }
 
+func TestEntryEncodeTruncatesSkipReason(t *testing.T) {
+	entry := RunLogEntry{
+		JobName:       "MyJob",
+		Time:          time.Unix(1506313655, 0),
+		Fate:          common.SubprocFateSkipped,
+		Result:        JobGood,
+		SkipReason:    "when: " + strings.Repeat("\t", 300),
+		Attempt:       2,
+		QueueDelay:    time.Second,
+		LimitExceeded: "CPU time limit (1s)",
+	}
+
+	encoded := encodeRunLogEntry(&entry)
+	require.Equal(t, int(gLogEntryLen), len(encoded))
+	decoded, err := decodeRunLogEntry(encoded)
+	require.Nil(t, err)
+
+	/* the other fields are kept */
+	require.Equal(t, 2, decoded.Attempt)
+	require.Equal(t, time.Second, decoded.QueueDelay)
+	require.Equal(t, "CPU time limit (1s)", decoded.LimitExceeded)
+
+	/* the reason is truncated, without cutting an escape sequence */
+	require.True(t, strings.HasPrefix(decoded.SkipReason, "when: \t\t"))
+	require.True(t, strings.HasSuffix(decoded.SkipReason, "\t..."))
+	require.Less(t, len(decoded.SkipReason), len(entry.SkipReason))
+
+	/* (whatever the escape sequences before the cut) */
+	for i := 0; i < 4; i++ {
+		entry.SkipReason = "when: " + strings.Repeat("x", i) +
+			strings.Repeat("\\", 300)
+		encoded := encodeRunLogEntry(&entry)
+		start := strings.Index(encoded, "\tskip=")
+		end := strings.Index(encoded[start+1:], "\t") + start + 1
+		field := strings.TrimSuffix(encoded[start+1:end], "...")
+		escapes := len(field) - len(strings.TrimRight(field, "\\"))
+		require.Equal(t, 0, escapes%2, "%v", field)
+	}
+}
+
 func TestEntryDecode(t *testing.T) {
 	for _, testCase := range EntryDecodeTestCases {
 		// test decodeRunLogEntry
@@ -143,3 +297,112 @@ func TestWithOneEntry(t *testing.T) {
 	require.Equal(t, 1, len(entries))
 	require.Equal(t, entry, *entries[0])
 }
+
+func TestLegacyBackingFile(t *testing.T) {
+	/*
+		Set up
+	*/
+
+	// make legacy log file
+	entries := []RunLogEntry{
+		{
+			JobName:  "Job1",
+			Time:     time.Unix(1506313655, 0),
+			Fate:     common.SubprocFateSucceeded,
+			Result:   JobGood,
+			ExecTime: time.Second,
+		},
+		{
+			JobName:  "Job2",
+			Time:     time.Unix(1506313656, 0),
+			Fate:     common.SubprocFateFailed,
+			Result:   JobFailed,
+			ExecTime: time.Minute,
+		},
+	}
+	var lines []string
+	for _, entry := range entries {
+		line := strings.TrimRight(encodeRunLogEntry(&entry), " ")
+		line += strings.Repeat(" ", int(gLegacyLogEntryLen)-len(line))
+		lines = append(lines, line)
+	}
+	tmpDir, err := ioutil.TempDir("", "Testing")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to make temp dir: %v", err))
+	}
+	defer os.RemoveAll(tmpDir)
+	logFilePath := filepath.Join(tmpDir, "runlog")
+	histFileContents := strings.Join(lines[:1], "\n")
+	currFileContents := strings.Join(lines[1:], "\n")
+	ioutil.WriteFile(logFilePath+".1", []byte(histFileContents), 0600)
+	ioutil.WriteFile(logFilePath, []byte(currFileContents), 0600)
+
+	/*
+		Call
+	*/
+	log, err := NewFileRunLog(logFilePath, 10*(1<<20), 100)
+	require.Nil(t, err)
+	newEntry := RunLogEntry{
+		JobName:  "Job3",
+		Time:     time.Unix(1506313657, 0),
+		Fate:     common.SubprocFateSucceeded,
+		Result:   JobGood,
+		ExecTime: time.Second,
+		Manual:   true,
+	}
+	require.Nil(t, log.Put(newEntry))
+	actualEntries, err := log.GetAll()
+	require.Nil(t, err)
+
+	/*
+		Test
+	*/
+	require.Equal(t, 3, len(actualEntries))
+	require.Equal(t, newEntry, *actualEntries[0])
+	require.Equal(t, entries[1], *actualEntries[1])
+	require.Equal(t, entries[0], *actualEntries[2])
+
+	// current file must have been converted; historical one must not
+	currFileInfo, err := os.Stat(logFilePath)
+	require.Nil(t, err)
+	require.Equal(t, 2*gLogEntryLen+1, currFileInfo.Size())
+	histFileInfo, err := os.Stat(logFilePath + ".1")
+	require.Nil(t, err)
+	require.Equal(t, gLegacyLogEntryLen, histFileInfo.Size())
+}
+
+func TestQueryLongJobNames(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "Testing")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	log, err := NewFileRunLog(filepath.Join(tmpDir, "runlog"), 1<<20, 1)
+	require.Nil(t, err)
+
+	longName := "nightly-backup-of-the-production-database-to-offsite-" +
+		"storage-with-checks"
+	names := []string{
+		longName[:gLegacyMaxJobNameLen], // as written by older versions
+		longName,
+		"nightly-backup",
+	}
+	for i, name := range names {
+		require.Nil(t, log.Put(RunLogEntry{
+			JobName:  name,
+			Time:     time.Unix(1506313655+int64(i), 0),
+			Fate:     common.SubprocFateSucceeded,
+			Result:   JobGood,
+			ExecTime: time.Second,
+		}))
+	}
+
+	entries, _, err := QueryRunLog(log, RunLogQuery{Jobs: []string{longName}})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(entries))
+	require.Equal(t, longName[:gMaxJobNameLen], entries[0].JobName)
+	require.Equal(t, longName[:gLegacyMaxJobNameLen], entries[1].JobName)
+
+	entries, _, err = QueryRunLog(log,
+		RunLogQuery{Jobs: []string{"nightly-backup"}})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(entries))
+}
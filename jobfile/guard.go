@@ -0,0 +1,184 @@
+package jobfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+A job's "when" block holds conditions that must all hold for the job to
+run:
+
+	when:
+	  cmd: test -f /run/primary    # a command that must succeed
+	  fileExists: /run/primary     # a file that must exist
+	  pathMounted: /mnt/backup     # a path that must be a mount point
+	  loadBelow: 4.0               # the 1-minute load average must be lower
+
+They are checked right before each run.  If one doesn't hold, the run is
+recorded as skipped (rather than failed).  Relative paths are relative
+to the user's home dir.
+*/
+
+type GuardRaw struct {
+	Cmd         *string  `json:"cmd" yaml:"cmd,omitempty"`
+	FileExists  *string  `json:"fileExists" yaml:"fileExists,omitempty"`
+	PathMounted *string  `json:"pathMounted" yaml:"pathMounted,omitempty"`
+	LoadBelow   *float64 `json:"loadBelow" yaml:"loadBelow,omitempty"`
+}
+
+type Guard struct {
+	Cmd         string  // "" if none
+	FileExists  string  // "" if none
+	PathMounted string  // "" if none
+	LoadBelow   float64 // 0 if none
+}
+
+func guardPath(usr *user.User, path string) (string, error) {
+	if len(path) == 0 {
+		return "", &common.Error{What: "Empty path"}
+	}
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	if len(usr.HomeDir) == 0 {
+		errMsg := fmt.Sprintf("User has no home directory, so "+
+			"cannot interpret relative path %v", path)
+		return "", &common.Error{What: errMsg}
+	}
+	return filepath.Join(usr.HomeDir, path), nil
+}
+
+func (self GuardRaw) ToGuard(usr *user.User) (*Guard, error) {
+	var guard Guard
+	var err error
+	if self.Cmd != nil {
+		if len(strings.TrimSpace(*self.Cmd)) == 0 {
+			return nil, &common.Error{What: "Empty cmd"}
+		}
+		guard.Cmd = *self.Cmd
+	}
+	if self.FileExists != nil {
+		if guard.FileExists, err = guardPath(usr, *self.FileExists); err != nil {
+			return nil, &common.Error{What: "Invalid fileExists", Cause: err}
+		}
+	}
+	if self.PathMounted != nil {
+		if guard.PathMounted, err = guardPath(usr, *self.PathMounted); err != nil {
+			return nil, &common.Error{What: "Invalid pathMounted", Cause: err}
+		}
+	}
+	if self.LoadBelow != nil {
+		if *self.LoadBelow <= 0 {
+			return nil, &common.Error{What: "loadBelow must be positive"}
+		}
+		guard.LoadBelow = *self.LoadBelow
+	}
+	if guard == (Guard{}) {
+		return nil, &common.Error{What: "No conditions"}
+	}
+	return &guard, nil
+}
+
+/*
+Check the guard's conditions, running its command (if any) with the
+given shell and extra environment variables.  Returns "" if they all
+hold; otherwise, returns a description of one that doesn't (for the
+skipped run's SkipReason).  If ctx is cancelled while the command is
+running, returns ctx's error instead.
+*/
+func (self *Guard) Check(ctx context.Context, shell string,
+	env []string) (string, error) {
+
+	if len(self.FileExists) > 0 {
+		if _, err := os.Stat(self.FileExists); err != nil {
+			return fmt.Sprintf("when: %v doesn't exist",
+				self.FileExists), nil
+		}
+	}
+
+	if len(self.PathMounted) > 0 {
+		mounted, err := isMountPoint(self.PathMounted)
+		if err != nil {
+			return fmt.Sprintf("when: %v", err), nil
+		} else if !mounted {
+			return fmt.Sprintf("when: %v isn't mounted",
+				self.PathMounted), nil
+		}
+	}
+
+	if self.LoadBelow > 0 {
+		load, err := loadAvg()
+		if err != nil {
+			return fmt.Sprintf("when: failed to get load average: %v",
+				err), nil
+		} else if load >= self.LoadBelow {
+			return fmt.Sprintf("when: load average is %.2f", load), nil
+		}
+	}
+
+	if len(self.Cmd) > 0 {
+		execResult, err := common.ExecAndWaitWithParams(ctx, common.ExecParams{
+			Args: []string{shell, "-c", self.Cmd},
+			Env:  env,
+		})
+		if err != nil {
+			return fmt.Sprintf("when: failed to run cmd: %v", err), nil
+		}
+		defer execResult.Close()
+		switch execResult.Fate {
+		case common.SubprocFateCancelled:
+			return "", ctx.Err()
+		case common.SubprocFateFailed:
+			return fmt.Sprintf("when: cmd exited with %v",
+				execResult.ExitCode), nil
+		}
+	}
+
+	return "", nil
+}
+
+/*
+Whether path is a mount point: i.e., whether it's on a different device
+than its parent dir (or is the root).
+*/
+func isMountPoint(path string) (bool, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+	path = filepath.Clean(path)
+	parent := filepath.Dir(path)
+	if parent == path {
+		return true, nil
+	}
+
+	var pathStat, parentStat syscall.Stat_t
+	if err := syscall.Stat(path, &pathStat); err != nil {
+		return false, &common.Error{What: "Failed to stat " + path, Cause: err}
+	}
+	if err := syscall.Stat(parent, &parentStat); err != nil {
+		return false, &common.Error{What: "Failed to stat " + parent, Cause: err}
+	}
+	return pathStat.Dev != parentStat.Dev, nil
+}
+
+/*
+Parse the 1-minute load average from the output of "sysctl -n
+vm.loadavg", which looks like "{ 0.52 0.41 0.39 }".
+*/
+func parseSysctlLoadAvg(output string) (float64, error) {
+	fields := strings.Fields(strings.Trim(strings.TrimSpace(output), "{}"))
+	if len(fields) == 0 {
+		return 0, &common.Error{What: "Unexpected output from sysctl: " + output}
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
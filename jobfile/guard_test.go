@@ -0,0 +1,97 @@
+package jobfile
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobber-guard-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	missing := filepath.Join(dir, "missing")
+
+	cases := []struct {
+		guard  Guard
+		reason string // prefix of the reason ("" means it holds)
+	}{
+		{Guard{FileExists: dir}, ""},
+		{Guard{FileExists: missing}, "when: " + missing + " doesn't exist"},
+		{Guard{PathMounted: "/"}, ""},
+		{Guard{PathMounted: dir}, "when: " + dir + " isn't mounted"},
+		{Guard{PathMounted: missing}, "when: "},
+		{Guard{LoadBelow: 1e9}, ""},
+		{Guard{Cmd: "test -d " + dir}, ""},
+		{Guard{Cmd: "exit 3"}, "when: cmd exited with 3"},
+		{Guard{Cmd: "test \"$JOBBER_JOB_NAME\" = Guarded"}, ""},
+		{Guard{FileExists: dir, Cmd: "false"}, "when: cmd exited with 1"},
+	}
+	env := RunEnv("Guarded", "20261019T000000Z-00000000")
+	for _, c := range cases {
+		reason, err := c.guard.Check(ctx, "/bin/sh", env)
+		require.Nil(t, err)
+		if len(c.reason) == 0 {
+			require.Equal(t, "", reason, "%+v", c.guard)
+		} else {
+			require.True(t, strings.HasPrefix(reason, c.reason),
+				"%+v: %v", c.guard, reason)
+		}
+	}
+}
+
+func TestGuardCheckCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	guard := Guard{Cmd: "sleep 60"}
+
+	reason, err := guard.Check(ctx, "/bin/sh", nil)
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, "", reason)
+}
+
+func TestGuardRawToGuard(t *testing.T) {
+	str := func(s string) *string { return &s }
+	load := 2.5
+	usr := user.User{Username: "alice", HomeDir: "/home/alice"}
+
+	guard, err := GuardRaw{
+		FileExists:  str("flag"),
+		PathMounted: str("/mnt/backup"),
+		LoadBelow:   &load,
+	}.ToGuard(&usr)
+	require.Nil(t, err)
+	require.Equal(t, Guard{
+		FileExists:  "/home/alice/flag",
+		PathMounted: "/mnt/backup",
+		LoadBelow:   2.5,
+	}, *guard)
+
+	zero := 0.0
+	for _, raw := range []GuardRaw{
+		{},
+		{Cmd: str(" ")},
+		{FileExists: str("")},
+		{LoadBelow: &zero},
+	} {
+		_, err := raw.ToGuard(&usr)
+		require.NotNil(t, err, "%+v", raw)
+	}
+}
+
+func TestParseSysctlLoadAvg(t *testing.T) {
+	load, err := parseSysctlLoadAvg("{ 0.52 0.41 0.39 }\n")
+	require.Nil(t, err)
+	require.Equal(t, 0.52, load)
+
+	_, err = parseSysctlLoadAvg("{ }")
+	require.NotNil(t, err)
+}
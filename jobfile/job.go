@@ -36,30 +36,176 @@ func (s JobStatus) String() string {
 
 type Job struct {
 	// params
-	Name            string
-	Cmd             string
-	FullTimeSpec    FullTimeSpec
-	User            string
-	ErrorHandler    ErrorHandler
-	NotifyOnError   []ResultSink
-	NotifyOnFailure []ResultSink
-	NotifyOnSuccess []ResultSink
+	Name             string
+	Cmd              string
+	FullTimeSpec     FullTimeSpec
+	At               *time.Time             // for one-shot jobs (FullTimeSpec is then unused)
+	ActiveFrom       *time.Time             // the job doesn't run before this
+	ActiveUntil      *time.Time             // the job doesn't run at or after this
+	MaxRuns          int                    // max successful runs (0 means no limit)
+	Priority         int                    // higher runs first when runs are queued
+	Retry            *RetryPolicy           // nil means no retries
+	When             *Guard                 // nil means no preconditions
+	Watch            *WatchSpec             // nil means no watch trigger
+	Lock             *LockSpec              // nil means no lock
+	Resources        *common.ResourceLimits // nil means no limits
+	Sandbox          *common.Sandbox        // nil means no sandbox
+	Secrets          []SecretSpec           // sorted by EnvVar
+	Unscheduled      bool                   // the job has no time spec (cf. Watch)
+	User             string
+	ErrorHandler     ErrorHandler
+	OnStatusChange   string // command to run when the job's status changes
+	NotifyOnError    []ResultSink
+	NotifyOnFailure  []ResultSink
+	NotifyOnSuccess  []ResultSink
+	NotifyOnWarning  []ResultSink
+	SuccessExitCodes []int // nil means just 0
+	WarningExitCodes []int
+	OutputMatchers   []OutputMatcher
+	Calendars        JobCalendars
 
 	// backoff after errors
 	backoffLevel int
 	skipsLeft    int
 
+	// number of consecutive failed runs (cf. StopAfterErrorHandler)
+	ConsecutiveFailures int
+
+	// when the job was last marked Failed (cf. ErrorHandler.Cooldown)
+	FailedTime time.Time
+
 	// other dynamic stuff
 	NextRunTime *time.Time
 	Status      JobStatus
 	LastRunTime time.Time
 	Paused      bool
+
+	// a run moved here by a shift calendar (nil if none is pending)
+	ShiftedRunTime *time.Time
+
+	// whether a one-shot job's time has come
+	AtFired bool
+
+	// number of successful scheduled runs (for MaxRuns)
+	SuccessfulRuns int
 }
 
 func (j *Job) String() string {
 	return j.Name
 }
 
+/*
+The format in which one-shot jobs' "at" times are written.  Times
+without a zone are local.
+*/
+const AtTimeFmt = "2006-01-02T15:04:05"
+
+/*
+Parse a one-shot job's "at" time: a date-time like
+"2026-11-01T02:00:00", optionally with a zone (as in RFC 3339), or a
+date (meaning midnight).
+*/
+func ParseAtTime(s string) (time.Time, error) {
+	t, _, err := parseCalendarTime(s)
+	return t, err
+}
+
+/*
+Returns the job's next time at or after now, according to its time spec
+or (for one-shot jobs) its "at" time, without regard to its calendars.
+A one-shot job whose time has passed but that hasn't fired yet (e.g.,
+because Jobber wasn't running) is due now.
+*/
+func (self *Job) NextTime(now time.Time) *time.Time {
+	if self.RunsExhausted() {
+		return nil
+	}
+	if self.ActiveFrom != nil && now.Before(*self.ActiveFrom) {
+		now = *self.ActiveFrom
+	}
+
+	var next *time.Time
+	if self.Unscheduled {
+		return nil
+	} else if self.At == nil {
+		next = self.FullTimeSpec.NextTime(now)
+	} else if self.AtFired {
+		return nil
+	} else if self.At.Before(now) {
+		next = &now
+	} else {
+		at := *self.At
+		next = &at
+	}
+
+	if next != nil && !self.InWindow(*next) {
+		return nil
+	}
+	return next
+}
+
+/*
+Whether t is within the job's active window (cf. ActiveFrom and
+ActiveUntil).
+*/
+func (self *Job) InWindow(t time.Time) bool {
+	if self.ActiveFrom != nil && t.Before(*self.ActiveFrom) {
+		return false
+	}
+	if self.ActiveUntil != nil && !t.Before(*self.ActiveUntil) {
+		return false
+	}
+	return true
+}
+
+/*
+Whether the job has had as many successful runs as it may have.
+*/
+func (self *Job) RunsExhausted() bool {
+	return self.MaxRuns > 0 && self.SuccessfulRuns >= self.MaxRuns
+}
+
+const (
+	JobNotYetActiveStr = "Not yet active"
+	JobExpiredStr      = "Expired"
+)
+
+/*
+Describes whether the job is outside its active window or has run as
+many times as it may, for display.  Returns "" if neither is so.
+*/
+func (self *Job) ActivityString(now time.Time) string {
+	switch {
+	case self.RunsExhausted():
+		return JobExpiredStr
+	case self.At != nil && self.AtFired:
+		/* (e.g., its run was skipped) */
+		return JobExpiredStr
+	case self.ActiveUntil != nil && !now.Before(*self.ActiveUntil):
+		return JobExpiredStr
+	case self.ActiveFrom != nil && now.Before(*self.ActiveFrom):
+		return JobNotYetActiveStr
+	default:
+		return ""
+	}
+}
+
+/*
+Describes when the job runs, for display.
+*/
+func (self *Job) ScheduleString() string {
+	if self.At != nil {
+		return "at " + self.At.Format(AtTimeFmt)
+	}
+	if self.Unscheduled {
+		return self.Watch.String()
+	}
+	if self.Watch != nil {
+		return self.FullTimeSpec.String() + " or " + self.Watch.String()
+	}
+	return self.FullTimeSpec.String()
+}
+
 func NewRawJob() JobRaw {
 	onError := "continue;"
 	return JobV3Raw{
@@ -67,10 +213,12 @@ func NewRawJob() JobRaw {
 	}
 }
 
-func (job *Job) ShouldRun() bool {
+func (job *Job) ShouldRun(now time.Time) bool {
 	switch job.Status {
 	case JobFailed:
-		return false
+		/* a Failed job gets another chance after its cooldown */
+		cooldown := job.ErrorHandler.Cooldown()
+		return cooldown > 0 && !now.Before(job.FailedTime.Add(cooldown))
 
 	case JobBackoff:
 		job.skipsLeft--
@@ -85,13 +233,31 @@ const RunRecOutputMaxLen = 1 << 20
 
 type RunRec struct {
 	Job       *Job
+	RunId     string // cf. NewRunId
 	RunTime   time.Time
+	OldStatus JobStatus // the job's status before the run
 	NewStatus JobStatus
 	Stdout    []byte
 	Stderr    []byte
 	Fate      common.SubprocFate
 	ExecTime  time.Duration
+	Manual    bool // whether the run was triggered by "jobber run"
 	Err       error
+
+	// why the run was skipped (for SubprocFateSkipped)
+	SkipReason string
+
+	// which attempt this was, for jobs with a retry policy (0 otherwise)
+	Attempt int
+
+	// whether the run failed and will be retried (cf. Job.Retry)
+	WillRetry bool
+
+	// how long the run waited for a slot (cf. UserPrefs.MaxConcurrentJobs)
+	QueueDelay time.Duration
+
+	// the resource limits the run exceeded, if any (cf. Job.Resources)
+	LimitExceeded string
 }
 
 func (rec *RunRec) Describe() string {
@@ -101,11 +267,24 @@ func (rec *RunRec) Describe() string {
 		summary = fmt.Sprintf("Job \"%v\" succeeded.", rec.Job.Name)
 		break
 	case common.SubprocFateFailed:
-		summary = fmt.Sprintf("Job \"%v\" failed.", rec.Job.Name)
+		if len(rec.LimitExceeded) > 0 {
+			summary = fmt.Sprintf("Job \"%v\" failed (exceeded %v).",
+				rec.Job.Name, rec.LimitExceeded)
+		} else {
+			summary = fmt.Sprintf("Job \"%v\" failed.", rec.Job.Name)
+		}
 		break
 	case common.SubprocFateCancelled:
 		summary = fmt.Sprintf("Job \"%v\" cancelled.", rec.Job.Name)
 		break
+	case common.SubprocFateSkipped:
+		summary = fmt.Sprintf("Job \"%v\" skipped (%v).", rec.Job.Name,
+			rec.SkipReason)
+		break
+	case common.SubprocFateWarning:
+		summary = fmt.Sprintf("Job \"%v\" succeeded with a warning.",
+			rec.Job.Name)
+		break
 	default:
 		panic("Unknown subproc fate")
 	}
@@ -8,6 +8,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -22,6 +23,27 @@ const (
 	gDefaultMemRunLogMaxLen = 100
 )
 
+/*
+An error in a particular field of a jobfile.  Path is the sequence of
+YAML keys leading to the field -- e.g., ["jobs", "DailyBackup", "time"].
+*/
+type FieldError struct {
+	Path  []string
+	Cause error
+}
+
+func (self *FieldError) Error() string {
+	return fmt.Sprintf("%v: %v", strings.Join(self.Path, "."), self.Cause)
+}
+
+func jobFieldError(jobName string, field string, err error) error {
+	return &FieldError{Path: []string{JobsSectName, jobName, field}, Cause: err}
+}
+
+func prefsFieldError(field string, err error) error {
+	return &FieldError{Path: []string{PrefsSectName, field}, Cause: err}
+}
+
 type JobFile struct {
 	Prefs UserPrefs
 	Jobs  map[string]*Job
@@ -32,16 +54,25 @@ func (self *JobFile) InitResultSinks() {
 }
 
 type UserPrefs struct {
-	RunLog  RunLog
-	LogPath string // for error msgs etc.  May be "".
+	RunLog            RunLog
+	RunOutputs        RunOutputStore // nil if output is not kept
+	LogPath           string         // for error msgs etc.  May be "".
+	Calendars         map[string]*Calendar
+	MaxConcurrentJobs int // 0 means no limit
 }
 
 func (self *UserPrefs) String() string {
 	s := ""
 	s += fmt.Sprintf("RunLog: %v\n", self.RunLog)
+	if self.RunOutputs != nil {
+		s += fmt.Sprintf("RunOutputs: %v\n", self.RunOutputs)
+	}
 	if len(self.LogPath) > 0 {
 		s += fmt.Sprintf("Log path: %v\n", self.LogPath)
 	}
+	if self.MaxConcurrentJobs > 0 {
+		s += fmt.Sprintf("Max concurrent jobs: %v\n", self.MaxConcurrentJobs)
+	}
 	return s
 }
 
@@ -62,17 +93,27 @@ type RunLogRaw struct {
 	Type string `yaml:"type"` // "file" or "memory"
 
 	// fields for type == "memory
-	MaxLen *int `yaml:"maxLen"`
+	MaxLen *int `yaml:"maxLen,omitempty"`
 
 	// fields for type == "file":
-	Path         *string `yaml:"path"`
-	MaxFileLen   *string `yaml:"maxFileLen"`
-	MaxHistories *int    `yaml:"maxHistories"`
+	Path         *string `yaml:"path,omitempty"`
+	MaxFileLen   *string `yaml:"maxFileLen,omitempty"`
+	MaxHistories *int    `yaml:"maxHistories,omitempty"`
+
+	// if not nil, the output of recent runs is kept
+	Output *RunOutputRaw `yaml:"output,omitempty"`
+}
+
+type RunOutputRaw struct {
+	MaxRunsPerJob *int    `yaml:"maxRunsPerJob,omitempty"`
+	MaxTotalLen   *string `yaml:"maxTotalLen,omitempty"` // e.g., "20m"
 }
 
 type UserPrefsV3Raw struct {
-	LogPath *string    `yaml:"logPath"`
-	RunLog  *RunLogRaw `yaml:"runLog"`
+	LogPath           *string                `yaml:"logPath,omitempty"`
+	RunLog            *RunLogRaw             `yaml:"runLog,omitempty"`
+	Calendars         map[string]CalendarRaw `yaml:"calendars,omitempty"`
+	MaxConcurrentJobs *int                   `yaml:"maxConcurrentJobs,omitempty"`
 }
 
 type UserPrefsV1V2Raw struct {
@@ -84,12 +125,30 @@ type UserPrefsV1V2Raw struct {
 type JobRaw = JobV3Raw
 
 type JobV3Raw struct {
-	Cmd             string          `json:"cmd" yaml:"cmd"`
-	Time            string          `json:"time" yaml:"time"`
-	OnError         *string         `json:"onError" yaml:"onError"`
-	NotifyOnSuccess []ResultSinkRaw `json:"notifyOnSuccess" yaml:"notifyOnSuccess"`
-	NotifyOnError   []ResultSinkRaw `json:"notifyOnError" yaml:"notifyOnError"`
-	NotifyOnFailure []ResultSinkRaw `json:"notifyOnFailure" yaml:"notifyOnFailure"`
+	Cmd              string             `json:"cmd" yaml:"cmd"`
+	Time             string             `json:"time" yaml:"time,omitempty"`
+	At               *string            `json:"at" yaml:"at,omitempty"`
+	ActiveFrom       *string            `json:"activeFrom" yaml:"activeFrom,omitempty"`
+	ActiveUntil      *string            `json:"activeUntil" yaml:"activeUntil,omitempty"`
+	MaxRuns          *int               `json:"maxRuns" yaml:"maxRuns,omitempty"`
+	Priority         *int               `json:"priority" yaml:"priority,omitempty"`
+	Retry            *RetryRaw          `json:"retry" yaml:"retry,omitempty"`
+	When             *GuardRaw          `json:"when" yaml:"when,omitempty"`
+	Watch            *WatchRaw          `json:"watch" yaml:"watch,omitempty"`
+	Lock             *LockRaw           `json:"lock" yaml:"lock,omitempty"`
+	Resources        *ResourcesRaw      `json:"resources" yaml:"resources,omitempty"`
+	Sandbox          *SandboxRaw        `json:"sandbox" yaml:"sandbox,omitempty"`
+	Secrets          map[string]string  `json:"secrets" yaml:"secrets,omitempty"`
+	OnError          *string            `json:"onError" yaml:"onError,omitempty"`
+	OnStatusChange   *string            `json:"onStatusChange" yaml:"onStatusChange,omitempty"`
+	NotifyOnSuccess  []ResultSinkRaw    `json:"notifyOnSuccess" yaml:"notifyOnSuccess,omitempty"`
+	NotifyOnError    []ResultSinkRaw    `json:"notifyOnError" yaml:"notifyOnError,omitempty"`
+	NotifyOnFailure  []ResultSinkRaw    `json:"notifyOnFailure" yaml:"notifyOnFailure,omitempty"`
+	NotifyOnWarning  []ResultSinkRaw    `json:"notifyOnWarning" yaml:"notifyOnWarning,omitempty"`
+	SuccessExitCodes []int              `json:"successExitCodes" yaml:"successExitCodes,omitempty"`
+	WarningExitCodes []int              `json:"warningExitCodes" yaml:"warningExitCodes,omitempty"`
+	OutputMatchers   []OutputMatcherRaw `json:"outputMatchers" yaml:"outputMatchers,omitempty"`
+	Calendars        *JobCalendarsRaw   `json:"calendars" yaml:"calendars,omitempty"`
 }
 
 type JobV1V2Raw struct {
@@ -132,6 +191,11 @@ func (self *JobFileV3Raw) Activate(usr *user.User) (*JobFile, error) {
 		if err := jobRaw.ToJob(usr, &job); err != nil {
 			return nil, err
 		}
+		calendars, err := jobRaw.Calendars.ToJobCalendars(jfile.Prefs.Calendars)
+		if err != nil {
+			return nil, jobFieldError(jobName, "calendars", err)
+		}
+		job.Calendars = calendars
 		jfile.Jobs[jobName] = &job
 	}
 
@@ -141,6 +205,7 @@ func (self *JobFileV3Raw) Activate(usr *user.User) (*JobFile, error) {
 		sinks = append(sinks, job.NotifyOnError...)
 		sinks = append(sinks, job.NotifyOnFailure...)
 		sinks = append(sinks, job.NotifyOnSuccess...)
+		sinks = append(sinks, job.NotifyOnWarning...)
 	}
 
 	/*
@@ -161,6 +226,45 @@ func (self *JobFileV3Raw) Activate(usr *user.User) (*JobFile, error) {
 	return &jfile, nil
 }
 
+/*
+Do the checks that Activate does, but without making the run log or
+starting any servers.  Unlike Activate, this does not stop at the first
+problem: it returns all the problems it finds.
+*/
+func (self *JobFileV3Raw) Check(usr *user.User) []error {
+	var errs []error
+
+	// check prefs
+	if err := self.Prefs.check(usr); err != nil {
+		errs = append(errs, err)
+	}
+	calendars, calErr := self.Prefs.calendars(usr)
+	if calErr != nil {
+		errs = append(errs, calErr)
+	}
+
+	// check jobs
+	var jobNames []string
+	for jobName := range self.Jobs {
+		jobNames = append(jobNames, jobName)
+	}
+	sort.Strings(jobNames)
+	for _, jobName := range jobNames {
+		job := Job{Name: jobName, ErrorHandler: ContinueErrorHandler{}}
+		if err := self.Jobs[jobName].ToJob(usr, &job); err != nil {
+			errs = append(errs, err)
+		}
+		if calErr == nil {
+			_, err := self.Jobs[jobName].Calendars.ToJobCalendars(calendars)
+			if err != nil {
+				errs = append(errs, jobFieldError(jobName, "calendars", err))
+			}
+		}
+	}
+
+	return errs
+}
+
 func NewEmptyRawJobFile() *JobFileRaw {
 	maxLen := gDefaultMemRunLogMaxLen
 	return &JobFileRaw{
@@ -229,7 +333,10 @@ func jobfileVersion(f *os.File) (*SemVer, error) {
 	if err != nil {
 		return nil, err
 	}
+	return jobfileVersionOfData(data)
+}
 
+func jobfileVersionOfData(data []byte) (*SemVer, error) {
 	// check version
 	v1Content := make([]interface{}, 0)
 	if err := yaml.Unmarshal(data, &v1Content); err == nil {
@@ -260,12 +367,16 @@ func parseV3Jobfile(f *os.File) (*JobFileV3Raw, error) {
 	if err != nil {
 		return nil, err
 	}
-	dataStr := string(data)
-	if !strings.HasPrefix(dataStr, gYamlStarter+"\n") {
-		dataStr = gYamlStarter + "\n" + dataStr
-	}
+	return parseV3JobfileData(data)
+}
+
+func parseV3JobfileData(data []byte) (*JobFileV3Raw, error) {
+	/*
+		NOTE: We don't prepend gYamlStarter here (as we do for V1/V2
+		sections), so that line numbers in errors match the file.
+	*/
 	var jobfileRaw JobFileV3Raw
-	if err := yaml.Unmarshal([]byte(dataStr), &jobfileRaw); err != nil {
+	if err := yaml.Unmarshal(data, &jobfileRaw); err != nil {
 		return nil, err
 	}
 	return &jobfileRaw, nil
@@ -481,7 +592,70 @@ func findSections(f *os.File) (map[string]string, error) {
 	return retval, nil
 }
 
+const (
+	gDefaultMaxFileLen   int64 = 50 * (1 << 20)
+	gDefaultMaxHistories int   = 5
+)
+
+/*
+Parse a max file len like "50m" (which means 50 MB).
+*/
+func parseMaxFileLen(maxFileLenStr string) (int64, error) {
+	if len(maxFileLenStr) == 0 {
+		msg := fmt.Sprintf("Invalid max file len: '%v'",
+			maxFileLenStr)
+		return 0, &common.Error{What: msg}
+	}
+
+	lastChar := maxFileLenStr[len(maxFileLenStr)-1]
+	if lastChar != 'm' && lastChar != 'M' {
+		msg := fmt.Sprintf("Invalid max file len: '%v'",
+			maxFileLenStr)
+		return 0, &common.Error{What: msg}
+	}
+
+	numPart := maxFileLenStr[:len(maxFileLenStr)-1]
+	tmp, err := strconv.Atoi(numPart)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid max file len: '%v'",
+			maxFileLenStr)
+		return 0, &common.Error{What: msg, Cause: err}
+	}
+	return int64(tmp) * (1 << 20), nil
+}
+
+/*
+Check the run log's params without making the run log.
+*/
+func (self RunLogRaw) check() error {
+	if self.Type == "memory" {
+		if self.MaxLen != nil && *self.MaxLen <= 0 {
+			return &common.Error{What: "Run log's maxLen must be > 0"}
+		}
+		return self.Output.check()
+
+	} else if self.Type == "file" {
+		if self.Path == nil {
+			return &common.Error{What: "Missing path for run log"}
+		}
+		if self.MaxFileLen != nil {
+			if _, err := parseMaxFileLen(*self.MaxFileLen); err != nil {
+				return err
+			}
+		}
+		return self.Output.check()
+
+	} else {
+		msg := fmt.Sprintf("Invalid run log type: %v", self.Type)
+		return &common.Error{What: msg}
+	}
+}
+
 func (self RunLogRaw) ToRunLog() (RunLog, error) {
+	if err := self.check(); err != nil {
+		return nil, err
+	}
+
 	if self.Type == "memory" {
 		// make memory run log
 		maxLen := gDefaultMemRunLogMaxLen
@@ -490,90 +664,177 @@ func (self RunLogRaw) ToRunLog() (RunLog, error) {
 		}
 		return NewMemOnlyRunLog(maxLen), nil
 
-	} else if self.Type == "file" {
-		const defaultMaxFileLen int64 = 50 * (1 << 20)
-		const defaultMaxHistories int = 5
-
-		// check for file path
-		if self.Path == nil {
-			return nil, &common.Error{What: "Missing path for run log"}
-		}
-
+	} else {
 		// get max file len
-		maxFileLen := defaultMaxFileLen
+		maxFileLen := gDefaultMaxFileLen
 		if self.MaxFileLen != nil {
-			maxFileLenStr := *self.MaxFileLen
-
-			if len(maxFileLenStr) == 0 {
-				msg := fmt.Sprintf("Invalid max file len: '%v'",
-					maxFileLenStr)
-				return nil, &common.Error{What: msg}
-			}
-
-			lastChar := maxFileLenStr[len(maxFileLenStr)-1]
-			if lastChar != 'm' && lastChar != 'M' {
-				msg := fmt.Sprintf("Invalid max file len: '%v'",
-					maxFileLenStr)
-				return nil, &common.Error{What: msg}
-			}
-
-			numPart := maxFileLenStr[:len(maxFileLenStr)-1]
-			tmp, err := strconv.Atoi(numPart)
-			if err != nil {
-				msg := fmt.Sprintf("Invalid max file len: '%v'",
-					maxFileLenStr)
-				return nil, &common.Error{What: msg, Cause: err}
-			}
-			maxFileLen = int64(tmp) * (1 << 20)
+			maxFileLen, _ = parseMaxFileLen(*self.MaxFileLen)
 		}
 
 		// get max histories
-		maxHistories := defaultMaxHistories
+		maxHistories := gDefaultMaxHistories
 		if self.MaxHistories != nil {
 			maxHistories = *self.MaxHistories
 		}
 
 		// make file run log
 		return NewFileRunLog(*self.Path, maxFileLen, maxHistories)
+	}
+}
 
-	} else {
-		msg := fmt.Sprintf("Invalid run log type: %v", self.Type)
-		return nil, &common.Error{What: msg}
+func (self *RunOutputRaw) check() error {
+	if self == nil {
+		return nil
+	}
+	if self.MaxRunsPerJob != nil && *self.MaxRunsPerJob <= 0 {
+		return &common.Error{What: "Run log's output.maxRunsPerJob must be > 0"}
+	}
+	if self.MaxTotalLen != nil {
+		if _, err := parseMaxFileLen(*self.MaxTotalLen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Make the store for the output of recent runs.  Returns nil if output
+is not to be kept.
+*/
+func (self RunLogRaw) ToRunOutputStore() (RunOutputStore, error) {
+	if self.Output == nil {
+		return nil, nil
+	}
+	if err := self.check(); err != nil {
+		return nil, err
+	}
+
+	maxRunsPerJob := gDefaultMaxOutputRunsPerJob
+	if self.Output.MaxRunsPerJob != nil {
+		maxRunsPerJob = *self.Output.MaxRunsPerJob
+	}
+	maxTotalLen := gDefaultMaxOutputTotalLen
+	if self.Output.MaxTotalLen != nil {
+		maxTotalLen, _ = parseMaxFileLen(*self.Output.MaxTotalLen)
+	}
+
+	if self.Type == "memory" {
+		return NewMemOnlyRunOutputStore(maxRunsPerJob, maxTotalLen), nil
+	}
+	return NewFileRunOutputStore(*self.Path+".output", maxRunsPerJob,
+		maxTotalLen)
+}
+
+func (self UserPrefsV3Raw) logPath(usr *user.User) (string, error) {
+	/*
+	   Relative paths are interpreted as relative to the user's
+	   home dir.
+	*/
+	logPath := *self.LogPath
+	if filepath.IsAbs(logPath) {
+		return logPath, nil
+	}
+	if len(usr.HomeDir) == 0 {
+		errMsg := fmt.Sprintf("User has no home directory, so "+
+			"cannot interpret relative log file path %v",
+			logPath)
+		return "", &common.Error{What: errMsg}
+	}
+	return filepath.Join(usr.HomeDir, logPath), nil
+}
+
+/*
+Load the calendars defined in the prefs.
+*/
+func (self UserPrefsV3Raw) calendars(usr *user.User) (map[string]*Calendar, error) {
+	var calendars map[string]*Calendar
+	for name, calRaw := range self.Calendars {
+		if calendars == nil {
+			calendars = make(map[string]*Calendar)
+		}
+		cal, err := calRaw.ToCalendar(usr, name)
+		if err != nil {
+			return nil, &FieldError{
+				Path:  []string{PrefsSectName, "calendars", name},
+				Cause: err,
+			}
+		}
+		calendars[name] = cal
 	}
+	return calendars, nil
+}
+
+/*
+Check the prefs without making the run log.
+*/
+func (self UserPrefsV3Raw) check(usr *user.User) error {
+	if self.LogPath != nil {
+		if _, err := self.logPath(usr); err != nil {
+			return prefsFieldError("logPath", err)
+		}
+	}
+	if self.RunLog != nil {
+		if err := self.RunLog.check(); err != nil {
+			return prefsFieldError("runLog", err)
+		}
+	}
+	if err := self.checkMaxConcurrentJobs(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (self UserPrefsV3Raw) checkMaxConcurrentJobs() error {
+	if self.MaxConcurrentJobs != nil && *self.MaxConcurrentJobs < 1 {
+		return prefsFieldError("maxConcurrentJobs", &common.Error{
+			What: "maxConcurrentJobs must be positive",
+		})
+	}
+	return nil
 }
 
 func (self UserPrefsV3Raw) ToPrefs(usr *user.User, dest *UserPrefs) error {
 	// parse "logPath"
 	if self.LogPath != nil {
-		/*
-		   Relative paths are interpreted as relative to the user's
-		   home dir.
-		*/
-		logPath := *self.LogPath
-		if filepath.IsAbs(logPath) {
-			dest.LogPath = logPath
-		} else {
-			if len(usr.HomeDir) == 0 {
-				errMsg := fmt.Sprintf("User has no home directory, so "+
-					"cannot interpret relative log file path %v",
-					logPath)
-				return &common.Error{What: errMsg}
-			}
-			dest.LogPath = filepath.Join(usr.HomeDir, logPath)
+		logPath, err := self.logPath(usr)
+		if err != nil {
+			return prefsFieldError("logPath", err)
 		}
+		dest.LogPath = logPath
 	} // logPath
 
 	// parse "runLog"
 	if self.RunLog != nil {
 		runLog, err := self.RunLog.ToRunLog()
 		if err != nil {
-			return err
+			return prefsFieldError("runLog", err)
 		}
 		dest.RunLog = runLog
+
+		runOutputs, err := self.RunLog.ToRunOutputStore()
+		if err != nil {
+			return prefsFieldError("runLog", err)
+		}
+		dest.RunOutputs = runOutputs
 	} else {
 		dest.RunLog = NewMemOnlyRunLog(gDefaultMemRunLogMaxLen)
 	}
 
+	// parse "calendars"
+	calendars, err := self.calendars(usr)
+	if err != nil {
+		return err
+	}
+	dest.Calendars = calendars
+
+	// parse "maxConcurrentJobs"
+	if err := self.checkMaxConcurrentJobs(); err != nil {
+		return err
+	}
+	if self.MaxConcurrentJobs != nil {
+		dest.MaxConcurrentJobs = *self.MaxConcurrentJobs
+	}
+
 	return nil
 }
 
@@ -596,6 +857,18 @@ func normalizeResultSinkArray(sinks []ResultSink) []ResultSink {
 	return newSinks
 }
 
+func makeResultSinks(sinksRaw []ResultSinkRaw) ([]ResultSink, error) {
+	var sinks []ResultSink
+	for _, sinkRaw := range sinksRaw {
+		sink, err := MakeResultSinkFromConfig(sinkRaw)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return normalizeResultSinkArray(sinks), nil
+}
+
 func (self JobV3Raw) ToJob(usr *user.User, dest *Job) error {
 	// set cmd, user
 	dest.Cmd = self.Cmd
@@ -606,44 +879,195 @@ func (self JobV3Raw) ToJob(usr *user.User, dest *Job) error {
 		var err error
 		dest.ErrorHandler, err = GetErrorHandler(*self.OnError)
 		if err != nil {
-			return err
+			return jobFieldError(dest.Name, "onError", err)
 		}
 	}
+	if self.OnStatusChange != nil {
+		dest.OnStatusChange = *self.OnStatusChange
+	}
 
 	// handle NotifyOnError
-	for _, sinkRaw := range self.NotifyOnError {
-		sink, err := MakeResultSinkFromConfig(sinkRaw)
+	var err error
+	dest.NotifyOnError, err = makeResultSinks(self.NotifyOnError)
+	if err != nil {
+		return jobFieldError(dest.Name, "notifyOnError", err)
+	}
+
+	// handle NotifyOnFailure
+	dest.NotifyOnFailure, err = makeResultSinks(self.NotifyOnFailure)
+	if err != nil {
+		return jobFieldError(dest.Name, "notifyOnFailure", err)
+	}
+
+	// handle NotifyOnSuccess
+	dest.NotifyOnSuccess, err = makeResultSinks(self.NotifyOnSuccess)
+	if err != nil {
+		return jobFieldError(dest.Name, "notifyOnSuccess", err)
+	}
+
+	// handle NotifyOnWarning
+	dest.NotifyOnWarning, err = makeResultSinks(self.NotifyOnWarning)
+	if err != nil {
+		return jobFieldError(dest.Name, "notifyOnWarning", err)
+	}
+
+	// parse success criteria
+	if err := checkExitCodes(self.SuccessExitCodes); err != nil {
+		return jobFieldError(dest.Name, "successExitCodes", err)
+	}
+	if err := checkExitCodes(self.WarningExitCodes); err != nil {
+		return jobFieldError(dest.Name, "warningExitCodes", err)
+	}
+	for _, code := range self.WarningExitCodes {
+		if containsInt(self.SuccessExitCodes, code) ||
+			(self.SuccessExitCodes == nil && code == 0) {
+			return jobFieldError(dest.Name, "warningExitCodes", &common.Error{
+				What: fmt.Sprintf("Exit code %v also means success", code),
+			})
+		}
+	}
+	dest.SuccessExitCodes = self.SuccessExitCodes
+	dest.WarningExitCodes = self.WarningExitCodes
+	dest.OutputMatchers = nil
+	for i, matcherRaw := range self.OutputMatchers {
+		matcher, err := matcherRaw.ToOutputMatcher()
 		if err != nil {
-			return err
+			return jobFieldError(dest.Name,
+				fmt.Sprintf("outputMatchers[%v]", i), err)
 		}
-		dest.NotifyOnError = append(dest.NotifyOnError, sink)
+		dest.OutputMatchers = append(dest.OutputMatchers, matcher)
 	}
-	dest.NotifyOnError = normalizeResultSinkArray(dest.NotifyOnError)
 
-	// handle NotifyOnFailure
-	for _, sinkRaw := range self.NotifyOnFailure {
-		sink, err := MakeResultSinkFromConfig(sinkRaw)
+	// parse active window
+	if self.ActiveFrom != nil {
+		t, _, err := parseCalendarTime(*self.ActiveFrom)
 		if err != nil {
-			return err
+			return jobFieldError(dest.Name, "activeFrom", err)
 		}
-		dest.NotifyOnFailure = append(dest.NotifyOnFailure, sink)
+		dest.ActiveFrom = &t
+	}
+	if self.ActiveUntil != nil {
+		/* a date means the end of that day */
+		t, isDate, err := parseCalendarTime(*self.ActiveUntil)
+		if err != nil {
+			return jobFieldError(dest.Name, "activeUntil", err)
+		}
+		if isDate {
+			t = t.AddDate(0, 0, 1)
+		}
+		if dest.ActiveFrom != nil && !t.After(*dest.ActiveFrom) {
+			return jobFieldError(dest.Name, "activeUntil", &common.Error{
+				What: "activeUntil must be after activeFrom",
+			})
+		}
+		dest.ActiveUntil = &t
 	}
-	dest.NotifyOnFailure = normalizeResultSinkArray(dest.NotifyOnFailure)
 
-	// handle NotifyOnSuccess
-	for _, sinkRaw := range self.NotifyOnSuccess {
-		sink, err := MakeResultSinkFromConfig(sinkRaw)
+	// parse "maxRuns"
+	if self.MaxRuns != nil {
+		if *self.MaxRuns < 1 {
+			return jobFieldError(dest.Name, "maxRuns", &common.Error{
+				What: "maxRuns must be positive",
+			})
+		}
+		dest.MaxRuns = *self.MaxRuns
+	}
+
+	// parse "priority"
+	if self.Priority != nil {
+		dest.Priority = *self.Priority
+	}
+
+	// parse "when"
+	if self.When != nil {
+		dest.When, err = self.When.ToGuard(usr)
 		if err != nil {
-			return err
+			return jobFieldError(dest.Name, "when", err)
+		}
+	}
+
+	// parse "retry"
+	if self.Retry != nil {
+		dest.Retry, err = self.Retry.ToRetryPolicy()
+		if err != nil {
+			return jobFieldError(dest.Name, "retry", err)
 		}
-		dest.NotifyOnSuccess = append(dest.NotifyOnSuccess, sink)
 	}
-	dest.NotifyOnSuccess = normalizeResultSinkArray(dest.NotifyOnSuccess)
+
+	// parse "resources"
+	if self.Resources != nil {
+		dest.Resources, err = self.Resources.ToResourceLimits()
+		if err != nil {
+			return jobFieldError(dest.Name, "resources", err)
+		}
+	}
+
+	// parse "sandbox"
+	if self.Sandbox != nil {
+		dest.Sandbox, err = self.Sandbox.ToSandbox()
+		if err != nil {
+			return jobFieldError(dest.Name, "sandbox", err)
+		}
+	}
+
+	// parse "secrets"
+	if self.Secrets != nil {
+		dest.Secrets, err = makeSecretSpecs(usr, self.Secrets)
+		if err != nil {
+			return jobFieldError(dest.Name, "secrets", err)
+		}
+	}
+
+	// parse "lock"
+	if self.Lock != nil {
+		dest.Lock, err = self.Lock.ToLockSpec()
+		if err != nil {
+			return jobFieldError(dest.Name, "lock", err)
+		}
+	}
+
+	// parse "watch"
+	if self.Watch != nil {
+		if self.At != nil {
+			return jobFieldError(dest.Name, "watch", &common.Error{
+				What: "A job cannot have both \"at\" and \"watch\"",
+			})
+		}
+		dest.Watch, err = self.Watch.ToWatchSpec(usr)
+		if err != nil {
+			return jobFieldError(dest.Name, "watch", err)
+		}
+		if len(self.Time) == 0 {
+			/* it runs only when triggered */
+			dest.Unscheduled = true
+			return nil
+		}
+	}
+
+	// parse "at" (for one-shot jobs)
+	if self.At != nil {
+		if len(self.Time) > 0 {
+			return jobFieldError(dest.Name, "at", &common.Error{
+				What: "A job cannot have both \"time\" and \"at\"",
+			})
+		}
+		if len(dest.Name) > MaxOneShotJobNameLen {
+			msg := fmt.Sprintf("The names of jobs with \"at\" may be at "+
+				"most %v bytes long", MaxOneShotJobNameLen)
+			return jobFieldError(dest.Name, "at", &common.Error{What: msg})
+		}
+		at, err := ParseAtTime(*self.At)
+		if err != nil {
+			return jobFieldError(dest.Name, "at", err)
+		}
+		dest.At = &at
+		return nil
+	}
 
 	// parse time spec
 	tmp, err := ParseFullTimeSpec(self.Time)
 	if err != nil {
-		return err
+		return jobFieldError(dest.Name, "time", err)
 	}
 	dest.FullTimeSpec = *tmp
 	dest.FullTimeSpec.Derandomize()
@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/dshearer/jobber/common"
 	"github.com/stretchr/testify/require"
 )
 
@@ -93,6 +95,72 @@ prefs:
 	{
 		Input: `
 version: 1.4
+prefs:
+    runLog:
+        type: memory
+        maxLen: 10
+        output:
+            maxRunsPerJob: 5
+            maxTotalLen: 2m
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog:     NewMemOnlyRunLog(10),
+				RunOutputs: NewMemOnlyRunOutputStore(5, 2*(1<<20)),
+			},
+			Jobs: nil,
+		},
+	},
+	{
+		Input: `
+version: 1.4
+prefs:
+    runLog:
+        type: memory
+        output:
+            maxRunsPerJob: 0
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+prefs:
+    maxConcurrentJobs: 2
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        priority: -5
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog:            NewMemOnlyRunLog(100),
+				MaxConcurrentJobs: 2,
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					Priority:     -5,
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+prefs:
+    maxConcurrentJobs: 0
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
 prefs:
     runLog:
         type: file
@@ -341,6 +409,718 @@ jobs:
 			},
 		},
 	},
+	{
+		Input: `
+version: 1.4
+prefs:
+    calendars:
+        holidays:
+            dates: [2024-12-25, 2024-12-31/2025-01-01]
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        calendars:
+            skip: [holidays]
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog:    NewMemOnlyRunLog(100),
+				Calendars: map[string]*Calendar{"holidays": &gTestHolidays},
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Calendars: JobCalendars{
+						Skip: []*Calendar{&gTestHolidays},
+					},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        calendars:
+            shift: [holidays]
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+prefs:
+    calendars:
+        holidays:
+            dates: [2024-13-25]
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        at: 2026-11-01T02:00:00
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					Cmd:          "exit 0",
+					At:           &gTestAtTime,
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: 0 0 2
+        at: 2026-11-01T02:00:00
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1-with-a-name-that-is-much-too-long-to-fit-in-the-run-log-entries:
+        cmd: exit 0
+        at: 2026-11-01T02:00:00
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        at: next sunday
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        activeFrom: 2026-11-01T02:00:00
+        activeUntil: 2026-11-30
+        maxRuns: 3
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					ActiveFrom:   &gTestAtTime,
+					ActiveUntil:  &gTestActiveUntil,
+					MaxRuns:      3,
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        retry:
+            attempts: 3
+            delay: 30s
+            maxDelay: 1m
+            jitter: 0.5
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					Retry: &RetryPolicy{
+						Attempts:   3,
+						Delay:      30 * time.Second,
+						Multiplier: 2,
+						MaxDelay:   time.Minute,
+						Jitter:     0.5,
+					},
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        retry:
+            attempts: 0
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        onError: backoff(max=32, cooldown=1h)
+        onStatusChange: echo $JOBBER_NEW_STATUS
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: BackoffErrorHandler{
+						Max:         32,
+						CooldownDur: time.Hour,
+					},
+					OnStatusChange: "echo $JOBBER_NEW_STATUS",
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        successExitCodes: [0, 1]
+        warningExitCodes: [2]
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:             "Job1",
+					FullTimeSpec:     gEverySecTimeSpec,
+					Cmd:              "exit 0",
+					User:             gUserEx.Username,
+					ErrorHandler:     ContinueErrorHandler{},
+					SuccessExitCodes: []int{0, 1},
+					WarningExitCodes: []int{2},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        warningExitCodes: [0]
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        successExitCodes: [256]
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        outputMatchers:
+            - pattern: ERROR
+              fate: broken
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        when:
+            cmd: test -f /run/primary
+            pathMounted: /mnt/backup
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					When: &Guard{
+						Cmd:         "test -f /run/primary",
+						PathMounted: "/mnt/backup",
+					},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        when: {}
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        watch:
+            path: /var/inbox
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Unscheduled:  true,
+					Watch: &WatchSpec{
+						Path:     "/var/inbox",
+						Events:   WatchCreate | WatchWrite | WatchMove,
+						Debounce: time.Second,
+					},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        watch:
+            path: /var/inbox
+            events: [Delete, attrib]
+            debounce: 10s
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Watch: &WatchSpec{
+						Path:     "/var/inbox",
+						Events:   WatchDelete | WatchAttrib,
+						Debounce: 10 * time.Second,
+					},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        watch:
+            path: /var/inbox
+            events: [explode]
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        at: 2026-11-01T02:00:00
+        watch:
+            path: /var/inbox
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        lock:
+            name: db
+            timeout: 30m
+            system: true
+    Job2:
+        cmd: exit 0
+        time: "*"
+        lock:
+            name: db
+            policy: Skip
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Lock: &LockSpec{
+						Name:    "db",
+						Wait:    true,
+						Timeout: 30 * time.Minute,
+						System:  true,
+					},
+				},
+				"Job2": &Job{
+					Name:         "Job2",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Lock:         &LockSpec{Name: "db"},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        lock:
+            name: my lock
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        lock:
+            name: db
+            policy: skip
+            timeout: 1m
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        resources:
+            cpuTime: 1h
+            addressSpace: 2g
+            openFiles: 256
+            nice: 10
+            ionice: best-effort:6
+            memoryMax: 512m
+            cpuMax: 0.5
+    Job2:
+        cmd: exit 0
+        time: "*"
+        resources: {ionice: idle}
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Resources: &common.ResourceLimits{
+						CPUTime:   time.Hour,
+						AddrSpace: 2 << 30,
+						OpenFiles: 256,
+						Nice:      &gTen,
+						IOClass:   common.IOClassBestEffort,
+						IOLevel:   6,
+						MemoryMax: 512 << 20,
+						CPUMax:    0.5,
+					},
+				},
+				"Job2": &Job{
+					Name:         "Job2",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Resources: &common.ResourceLimits{
+						IOClass: common.IOClassIdle,
+					},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        resources:
+            nice: 20
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        resources:
+            memoryMax: 12x
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        resources:
+            ionice: idle:3
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        resources: {}
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        sandbox:
+            privateTmp: true
+            readOnly: [/home/me/, /etc]
+            noNetwork: true
+            noNewPrivs: true
+`,
+		Error: !common.SandboxesSupported,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          "exit 0",
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Sandbox: &common.Sandbox{
+						PrivateTmp: true,
+						ReadOnly:   []string{"/home/me", "/etc"},
+						NoNetwork:  true,
+						NoNewPrivs: true,
+					},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        sandbox:
+            readOnly: [data]
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        sandbox:
+            noNetwork: false
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: 'curl -H "Authorization: $API_TOKEN" example.com'
+        time: "*"
+        secrets:
+            API_TOKEN: /etc/jobber/token
+            DB_PASSWORD: .secrets/db
+`,
+		Output: JobFile{
+			Prefs: UserPrefs{
+				RunLog: NewMemOnlyRunLog(100),
+			},
+			Jobs: map[string]*Job{
+				"Job1": &Job{
+					Name:         "Job1",
+					FullTimeSpec: gEverySecTimeSpec,
+					Cmd:          `curl -H "Authorization: $API_TOKEN" example.com`,
+					User:         gUserEx.Username,
+					ErrorHandler: ContinueErrorHandler{},
+					Secrets: []SecretSpec{
+						{"API_TOKEN", "/etc/jobber/token"},
+						{"DB_PASSWORD",
+							filepath.Join(gUserEx.HomeDir, ".secrets/db")},
+					},
+				},
+			},
+		},
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        secrets:
+            API-TOKEN: /etc/jobber/token
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        time: "*"
+        secrets:
+            JOBBER_TOKEN: /etc/jobber/token
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        activeFrom: 2026-11-30
+        activeUntil: 2026-11-01
+`,
+		Error: true,
+	},
+	{
+		Input: `
+version: 1.4
+jobs:
+    Job1:
+        cmd: exit 0
+        maxRuns: 0
+`,
+		Error: true,
+	},
 	{
 		Input: `[unparseable
 `,
@@ -348,6 +1128,25 @@ jobs:
 	},
 }
 
+var gTestHolidays = Calendar{
+	Name: "holidays",
+	Ranges: []CalendarRange{
+		{
+			Start: time.Date(2024, 12, 25, 0, 0, 0, 0, time.Local),
+			End:   time.Date(2024, 12, 26, 0, 0, 0, 0, time.Local),
+		},
+		{
+			Start: time.Date(2024, 12, 31, 0, 0, 0, 0, time.Local),
+			End:   time.Date(2025, 1, 2, 0, 0, 0, 0, time.Local),
+		},
+	},
+}
+
+var gTen = 10
+
+var gTestAtTime = time.Date(2026, 11, 1, 2, 0, 0, 0, time.Local)
+var gTestActiveUntil = time.Date(2026, 12, 1, 0, 0, 0, 0, time.Local)
+
 func TestLoadJobFileV3(t *testing.T) {
 	for _, testCase := range gJobFileV3TestCases {
 		/*
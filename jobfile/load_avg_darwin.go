@@ -0,0 +1,16 @@
+package jobfile
+
+import (
+	"os/exec"
+)
+
+/*
+Get the 1-minute load average.
+*/
+func loadAvg() (float64, error) {
+	output, err := exec.Command("sysctl", "-n", "vm.loadavg").Output()
+	if err != nil {
+		return 0, err
+	}
+	return parseSysctlLoadAvg(string(output))
+}
@@ -0,0 +1,24 @@
+package jobfile
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+Get the 1-minute load average.
+*/
+func loadAvg() (float64, error) {
+	data, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, &common.Error{What: "/proc/loadavg is empty"}
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
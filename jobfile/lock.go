@@ -0,0 +1,94 @@
+package jobfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+A job's "lock" block keeps it from running at the same time as other
+jobs with the same lock:
+
+	lock:
+	  name: db          # the lock's name
+	  policy: skip      # if the lock is held: wait (the default) or skip
+	  timeout: 30m      # if waiting, skip after waiting this long
+	  system: true      # share the lock with other users' jobs
+
+Without "system", a lock is shared only by the user's own jobs.  System
+locks are managed by jobbermaster, so that jobs of different users can
+use them.  If a run is skipped because of a lock, it is recorded as
+skipped (rather than failed).
+*/
+
+const (
+	LockPolicyWait = "wait"
+	LockPolicySkip = "skip"
+)
+
+var gLockNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+type LockRaw struct {
+	Name    string  `json:"name" yaml:"name"`
+	Policy  *string `json:"policy" yaml:"policy,omitempty"`
+	Timeout *string `json:"timeout" yaml:"timeout,omitempty"`
+	System  bool    `json:"system" yaml:"system,omitempty"`
+}
+
+type LockSpec struct {
+	Name    string
+	Wait    bool          // if false, skip the run if the lock is held
+	Timeout time.Duration // max wait (0 means no limit)
+	System  bool
+}
+
+func (self LockRaw) ToLockSpec() (*LockSpec, error) {
+	spec := LockSpec{Name: self.Name, Wait: true, System: self.System}
+
+	if !gLockNameRegexp.MatchString(self.Name) {
+		msg := fmt.Sprintf("Invalid lock name: \"%v\" (must be letters, "+
+			"digits, '_', '.', and '-')", self.Name)
+		return nil, &common.Error{What: msg}
+	}
+
+	if self.Policy != nil {
+		switch strings.ToLower(*self.Policy) {
+		case LockPolicyWait:
+			spec.Wait = true
+		case LockPolicySkip:
+			spec.Wait = false
+		default:
+			msg := fmt.Sprintf("Invalid policy: \"%v\" (must be \"%v\" "+
+				"or \"%v\")", *self.Policy, LockPolicyWait, LockPolicySkip)
+			return nil, &common.Error{What: msg}
+		}
+	}
+
+	if self.Timeout != nil {
+		if !spec.Wait {
+			return nil, &common.Error{
+				What: "timeout can only be used with policy \"wait\"",
+			}
+		}
+		var err error
+		spec.Timeout, err = time.ParseDuration(*self.Timeout)
+		if err != nil {
+			return nil, &common.Error{What: "Invalid timeout", Cause: err}
+		}
+		if spec.Timeout <= 0 {
+			return nil, &common.Error{What: "timeout must be positive"}
+		}
+	}
+	return &spec, nil
+}
+
+func (self *LockSpec) String() string {
+	if self.System {
+		return "system:" + self.Name
+	}
+	return self.Name
+}
@@ -77,6 +77,88 @@ func (self RandomTimeSpecExp) Eval(fieldName string, min int,
     return &RandomTimeSpec{vals: vals, desc: desc}, nil
 }
 
+/*
+The following exps are for the cron extensions that depend on the whole
+date: "L" (last day of the month), "LW" (last weekday of the month), and
+"15W" (weekday nearest the 15th) in the month-day field, and "5L" (last
+Friday of the month) and "5#3" (third Friday of the month) in the
+weekday field.
+*/
+
+func checkTimeSpecField(fieldName string, wantFieldName string,
+    desc string) error {
+
+    if fieldName != wantFieldName {
+        msg := fmt.Sprintf("\"%v\" can only be used in the %v field",
+            desc, wantFieldName)
+        return &common.Error{What: msg}
+    }
+    return nil
+}
+
+type LastDayTimeSpecExp struct {
+    weekday bool
+}
+
+func (self LastDayTimeSpecExp) Eval(fieldName string, min int,
+    max int) (TimeSpec, error) {
+
+    spec := LastDayTimeSpec{Weekday: self.weekday}
+    if err := checkTimeSpecField(fieldName, gMdayFieldName,
+        spec.String()); err != nil {
+        return nil, err
+    }
+    return spec, nil
+}
+
+type NearestWeekdayTimeSpecExp struct {
+    day int
+}
+
+func (self NearestWeekdayTimeSpecExp) Eval(fieldName string, min int,
+    max int) (TimeSpec, error) {
+
+    spec := NearestWeekdayTimeSpec{Day: self.day}
+    if err := checkTimeSpecField(fieldName, gMdayFieldName,
+        spec.String()); err != nil {
+        return nil, err
+    }
+    if _, err := (OneValTimeSpecExp{val: self.day}).Eval(fieldName, min,
+        max); err != nil {
+        return nil, err
+    }
+    return spec, nil
+}
+
+type NthWdayTimeSpecExp struct {
+    wday int
+    nth  int
+    last bool
+}
+
+func (self NthWdayTimeSpecExp) Eval(fieldName string, min int,
+    max int) (TimeSpec, error) {
+
+    spec := NthWdayTimeSpec{Wday: self.wday, Nth: self.nth}
+    if self.last {
+        spec.Nth = 0
+    }
+    if err := checkTimeSpecField(fieldName, gWdayFieldName,
+        spec.String()); err != nil {
+        return nil, err
+    }
+    if _, err := (OneValTimeSpecExp{val: self.wday}).Eval(fieldName, min,
+        max); err != nil {
+        return nil, err
+    }
+    if !self.last && (self.nth < 1 || self.nth > 5) {
+        msg := fmt.Sprintf("Invalid '%v' value: \"%v\": the week must be " +
+            "between 1 and 5", fieldName, spec)
+        return nil, &common.Error{What: msg}
+    }
+    return spec, nil
+}
+
 type SetExp interface {
     fmt.Stringer
     Eval(fieldName string, min, max int) ([]int, error)
@@ -163,28 +245,80 @@ func (self RangeSetExp) Eval(fieldName string, min int,
     errMsgPrefix := fmt.Sprintf("Invalid \"%v\" value", fieldName)
 
     // check values
-    if self.start < min {
+    if self.start < min || self.end < min {
         msg := fmt.Sprintf("%v: Values must be greater than or " +
             "equal to %v", errMsgPrefix, min)
         return nil, &common.Error{What: msg}
-    } else if self.end > max {
+    } else if self.end > max || self.start > max {
         msg := fmt.Sprintf("%v: Values must be less than or " +
             "equal to %v", errMsgPrefix, max)
         return nil, &common.Error{What: msg}
-    } else if self.start > self.end {
+    } else if self.start > self.end && fieldName != gWdayFieldName {
         msg := fmt.Sprintf("%s: start must be less than or " +
             "equal to end", errMsgPrefix)
         return nil, &common.Error{What: msg}
     }
 
-    // make values
+    // make values (weekday ranges, like FRI-MON, may wrap around)
     var vals []int
-    for i := self.start; i <= self.end; i++ {
+    for i := self.start; ; i++ {
+        if i > max {
+            i = min
+        }
         vals = append(vals, i)
+        if i == self.end {
+            break
+        }
     }
+    sort.Ints(vals)
     return vals, nil
 }
 
+/*
+A comma-separated list of values and ranges, like "1-3,12".
+*/
+type ListSetExp struct {
+    elems []SetExp
+}
+
+/*
+Make a SetExp for a list.  If the list has only values (not ranges),
+makes an EnumSetExp.
+*/
+func makeListSetExp(elems []SetExp) SetExp {
+    var enum EnumSetExp
+    for _, elem := range elems {
+        enumElem, ok := elem.(EnumSetExp)
+        if !ok {
+            return ListSetExp{elems: elems}
+        }
+        enum.ints = append(enum.ints, enumElem.ints...)
+    }
+    return enum
+}
+
+func (self ListSetExp) String() string {
+    var strs []string
+    for _, elem := range self.elems {
+        strs = append(strs, elem.String())
+    }
+    return strings.Join(strs, ",")
+}
+
+func (self ListSetExp) Eval(fieldName string, min int,
+    max int) ([]int, error) {
+
+    var all EnumSetExp
+    for _, elem := range self.elems {
+        vals, err := elem.Eval(fieldName, min, max)
+        if err != nil {
+            return nil, err
+        }
+        all.ints = append(all.ints, vals...)
+    }
+    return all.normValues(), nil
+}
+
 type AnySetExp struct{}
 
 func (self AnySetExp) String() string {
@@ -217,21 +351,24 @@ func setErrorMsg(msg string) {
 
 %union {
     nbr                 *int
+    timeSpecExp         TimeSpecExp
     randTimeSpecExp     RandomTimeSpecExp
     setExp              SetExp
     stepSetExp          StepSetExp
-    enumSetExp          EnumSetExp
+    setExps             []SetExp
     rangeSetExp         RangeSetExp
 }
 
 %type <randTimeSpecExp>   rand_time_spec_exp
 %type <setExp>            set_exp
 %type <stepSetExp>        step_set_exp
-%type <enumSetExp>        enum_set_exp
-%type <enumSetExp>        enum_set_exp_tail
+%type <setExp>            list_set_exp
+%type <setExps>           list_set_exp_tail
+%type <setExp>            list_item
 %type <rangeSetExp>       range_set_exp
+%type <timeSpecExp>       date_time_spec_exp
 
-%token ',' '-' '*' 'R' STAR_SLASH
+%token ',' '-' '*' 'R' 'L' 'W' '#' STAR_SLASH
 %token <nbr>   INT
 
 %%
@@ -245,6 +382,25 @@ top:
     { gPhrase = WildcardTimeSpecExp{} }
 |   rand_time_spec_exp
     { gPhrase = $1 }
+|   date_time_spec_exp
+    { gPhrase = $1 }
+
+date_time_spec_exp:
+    'L'
+    { $$ = LastDayTimeSpecExp{} }
+|   'L' 'W'
+    { $$ = LastDayTimeSpecExp{weekday: true} }
+|   INT 'W'
+    { $$ = NearestWeekdayTimeSpecExp{day: *$1} }
+|   INT 'L'
+    { $$ = NthWdayTimeSpecExp{wday: *$1, last: true} }
+|   INT '#' INT
+    { $$ = NthWdayTimeSpecExp{wday: *$1, nth: *$3} }
+|   INT '#' error
+    {
+        setErrorMsg("Expected int after \"#\"")
+        goto ret1
+    }
 
 rand_time_spec_exp:
     'R' set_exp
@@ -255,7 +411,7 @@ rand_time_spec_exp:
 set_exp:
     step_set_exp
     { $$ = $1 }
-|   enum_set_exp
+|   list_set_exp
     { $$ = $1 }
 |   range_set_exp
     { $$ = $1 }
@@ -269,27 +425,27 @@ step_set_exp:
         goto ret1
     }
 
-enum_set_exp:
-    INT enum_set_exp_tail
-    {
-        $$ = EnumSetExp{ints: []int{*$1}}
-        $$.ints = append($$.ints, $2.ints...)
-    }
+list_set_exp:
+    list_item list_set_exp_tail
+    { $$ = makeListSetExp(append([]SetExp{$1}, $2...)) }
 
-enum_set_exp_tail:
-    ',' INT
-    { $$ = EnumSetExp{ints: []int{*$2}} }
-|   ',' INT enum_set_exp_tail
-    {
-        $$ = EnumSetExp{ints: []int{*$2}}
-        $$.ints = append($$.ints, $3.ints...)
-    }
+list_set_exp_tail:
+    ',' list_item
+    { $$ = []SetExp{$2} }
+|   ',' list_item list_set_exp_tail
+    { $$ = append([]SetExp{$2}, $3...) }
 |   ',' error
     {
-        setErrorMsg("Expected int after \",\"")
+        setErrorMsg("Expected int or range after \",\"")
         goto ret1
     }
 
+list_item:
+    INT
+    { $$ = EnumSetExp{ints: []int{*$1}} }
+|   INT '-' INT
+    { $$ = RangeSetExp{start: *$1, end: *$3} }
+
 range_set_exp:
     INT '-' INT
     { $$ = RangeSetExp{start: *$1, end: *$3} }
@@ -303,12 +459,60 @@ range_set_exp:
 type yyLex struct {
     expr      string
     peek      rune
+    names     map[string]int // names for values, like "JAN"
+    pending   []int          // tokens to return before reading more
+}
+
+var gMonthNames = map[string]int{
+    "JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+    "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var gWeekdayNames = map[string]int{
+    "SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
 }
 
 func NewTimeSpecLexer(expr string) (*yyLex) {
     return &yyLex{expr: expr}
 }
 
+/*
+Handle a word (a run of letters): "R", "L", "W", "LW", or a name (in
+any case) for a value.  A name may follow "R", as in "RMON-FRI", or be
+followed by "L", as in "FRIL".
+*/
+func (self *yyLex) lexWord(word string, yylval *yySymType) int {
+    switch word {
+    case "R", "L", "W":
+        return int(word[0])
+    case "LW":
+        self.pending = append(self.pending, 'W')
+        return 'L'
+    }
+    if val, ok := self.names[strings.ToUpper(word)]; ok {
+        yylval.nbr = &val
+        return INT
+    }
+    if strings.HasSuffix(word, "L") {
+        // e.g., "FRIL" (last Friday of the month)
+        name := strings.ToUpper(word[:len(word)-1])
+        if val, ok := self.names[name]; ok {
+            self.pending = append(self.pending, 'L')
+            yylval.nbr = &val
+            return INT
+        }
+    }
+    if word[0] == 'R' {
+        if val, ok := self.names[strings.ToUpper(word[1:])]; ok {
+            self.pending = append(self.pending, INT)
+            yylval.nbr = &val
+            return 'R'
+        }
+    }
+    setErrorMsg(fmt.Sprintf("Unexpected word: \"%v\"", word))
+    return gEof
+}
+
 func (self *yyLex) Error(msg string) {
     if gErrorMsg == nil {
         setErrorMsg(msg)
@@ -316,10 +520,17 @@ func (self *yyLex) Error(msg string) {
 }
 
 func (self *yyLex) Lex(yylval *yySymType) int {
+    if len(self.pending) > 0 {
+        // (yylval.nbr was already set, if needed)
+        tok := self.pending[0]
+        self.pending = self.pending[1:]
+        return tok
+    }
+
     for {
         r := self.nextRune()
         switch r {
-        case ',', '-', 'R':
+        case ',', '-', '#':
             return int(r)
 
         case '*':
@@ -338,7 +549,8 @@ func (self *yyLex) Lex(yylval *yySymType) int {
                 r2 := self.nextRune()
                 if unicode.IsDigit(r2) {
                     numeral += string(r2)
-                } else if unicode.IsSpace(r2) || unicode.IsPunct(r2) || r2 == gEof {
+                } else if unicode.IsSpace(r2) || unicode.IsPunct(r2) ||
+                    unicode.IsLetter(r2) || r2 == gEof {
                     self.peek = r2
                     break ReadNums
                 } else {
@@ -362,6 +574,19 @@ func (self *yyLex) Lex(yylval *yySymType) int {
             return gEof
 
         default:
+            if unicode.IsLetter(r) {
+                word := string(r)
+                for {
+                    r2 := self.nextRune()
+                    if !unicode.IsLetter(r2) {
+                        self.peek = r2
+                        break
+                    }
+                    word += string(r2)
+                }
+                return self.lexWord(word, yylval)
+            }
+
             var msg string
             if unicode.IsGraphic(r) {
                 msg = fmt.Sprintf("Unexpected char: \"%v\"", string(r))
@@ -394,6 +619,12 @@ func parseTimeSpec(s string, fieldName string, min int,
     // parse
     gErrorMsg = nil
     lex := NewTimeSpecLexer(s)
+    switch fieldName {
+    case gMonFieldName:
+        lex.names = gMonthNames
+    case gWdayFieldName:
+        lex.names = gWeekdayNames
+    }
     retval := yyParse(lex)
     if retval != 0 {
         msg := fmt.Sprintf("Cannot parse time spec for \"%v\": %v",
@@ -2,6 +2,7 @@ package jobfile
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -70,6 +71,62 @@ func TestParseFullTimeSpec(t *testing.T) {
 			WildcardTimeSpec{},
 			WildcardTimeSpec{},
 			OneValTimeSpec{1}}},
+		{"0 0 12 * JAN-MAR,dec MON-FRI", FullTimeSpec{
+			OneValTimeSpec{0},
+			OneValTimeSpec{0},
+			OneValTimeSpec{12},
+			WildcardTimeSpec{},
+			SetTimeSpec{"1-3,12", []int{1, 2, 3, 12}},
+			SetTimeSpec{"1-5", makeRange(1, 6)}}},
+		{"0 0 12 * Jun FRI-MON", FullTimeSpec{
+			OneValTimeSpec{0},
+			OneValTimeSpec{0},
+			OneValTimeSpec{12},
+			WildcardTimeSpec{},
+			OneValTimeSpec{6},
+			SetTimeSpec{"5-1", []int{0, 1, 5, 6}}}},
+		{"0 0 12 L", FullTimeSpec{
+			OneValTimeSpec{0},
+			OneValTimeSpec{0},
+			OneValTimeSpec{12},
+			LastDayTimeSpec{},
+			WildcardTimeSpec{},
+			WildcardTimeSpec{}}},
+		{"0 0 12 LW", FullTimeSpec{
+			OneValTimeSpec{0},
+			OneValTimeSpec{0},
+			OneValTimeSpec{12},
+			LastDayTimeSpec{Weekday: true},
+			WildcardTimeSpec{},
+			WildcardTimeSpec{}}},
+		{"0 0 12 15W", FullTimeSpec{
+			OneValTimeSpec{0},
+			OneValTimeSpec{0},
+			OneValTimeSpec{12},
+			NearestWeekdayTimeSpec{15},
+			WildcardTimeSpec{},
+			WildcardTimeSpec{}}},
+		{"0 0 12 * * 2#1", FullTimeSpec{
+			OneValTimeSpec{0},
+			OneValTimeSpec{0},
+			OneValTimeSpec{12},
+			WildcardTimeSpec{},
+			WildcardTimeSpec{},
+			NthWdayTimeSpec{Wday: 2, Nth: 1}}},
+		{"0 0 12 * * FRIL", FullTimeSpec{
+			OneValTimeSpec{0},
+			OneValTimeSpec{0},
+			OneValTimeSpec{12},
+			WildcardTimeSpec{},
+			WildcardTimeSpec{},
+			NthWdayTimeSpec{Wday: 5}}},
+		{"0 0 12 * * RMON-FRI", FullTimeSpec{
+			OneValTimeSpec{0},
+			OneValTimeSpec{0},
+			OneValTimeSpec{12},
+			WildcardTimeSpec{},
+			WildcardTimeSpec{},
+			&RandomTimeSpec{desc: "R1-5", vals: makeRange(1, 6)}}},
 	}
 
 	for _, c := range cases {
@@ -89,3 +146,56 @@ func TestParseFullTimeSpec(t *testing.T) {
 		require.Equal(t, c.spec, *result)
 	}
 }
+
+func TestDateTimeSpecs(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		spec     string
+		from     time.Time
+		expected []time.Time
+	}{
+		{"0 0 12 L", day(2024, time.February, 1),
+			[]time.Time{day(2024, time.February, 29), day(2024, time.March, 31)}},
+		{"0 0 12 LW", day(2024, time.November, 1),
+			[]time.Time{day(2024, time.November, 29), day(2024, time.December, 31)}},
+		{"0 0 12 15W", day(2024, time.June, 1),
+			[]time.Time{day(2024, time.June, 14), day(2024, time.July, 15)}},
+		{"0 0 12 1W", day(2024, time.June, 1),
+			[]time.Time{day(2024, time.June, 3), day(2024, time.July, 1)}},
+		{"0 0 12 31W", day(2024, time.September, 1),
+			[]time.Time{day(2024, time.October, 31), day(2024, time.December, 31)}},
+		{"0 0 12 * * 2#1", day(2024, time.October, 1),
+			[]time.Time{day(2024, time.October, 1), day(2024, time.November, 5)}},
+		{"0 0 12 * * FRIL", day(2024, time.November, 1),
+			[]time.Time{day(2024, time.November, 29), day(2024, time.December, 27)}},
+		{"0 0 12 * AUG MON#5", day(2026, time.January, 1),
+			[]time.Time{day(2026, time.August, 31), day(2027, time.August, 30)}},
+	}
+
+	for _, c := range cases {
+		spec, err := ParseFullTimeSpec(c.spec)
+		require.Nil(t, err, "%v: %v", c.spec, err)
+		require.Equal(t, c.expected, spec.NextTimes(c.from, 2), c.spec)
+	}
+}
+
+func TestParseFullTimeSpecErrors(t *testing.T) {
+	bad := []string{
+		"0 0 12 * * L",
+		"0 L",
+		"0 0 12 15#2",
+		"0 0 12 * * 2W",
+		"0 0 12 32W",
+		"0 0 12 * * 2#6",
+		"0 0 12 * * 2#0",
+		"0 0 12 * MON",
+		"0 0 12 * * JAN",
+		"0 0 12 * * 2#",
+	}
+	for _, s := range bad {
+		_, err := ParseFullTimeSpec(s)
+		require.NotNil(t, err, s)
+	}
+}
@@ -0,0 +1,155 @@
+package jobfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+A job's "resources" block limits what its runs may use:
+
+	resources:
+	  cpuTime: 10m          # max CPU time (rounded up to whole seconds)
+	  addressSpace: 2g      # max virtual memory (k, m, g, or t; or bytes)
+	  openFiles: 1024       # max open files
+	  nice: 10              # scheduling priority (-20 to 19)
+	  ionice: idle          # IO class (realtime, best-effort, idle) and,
+	                        # optionally, level (0 to 7): "best-effort:7"
+	  memoryMax: 1g         # cgroup v2 memory.max
+	  cpuMax: 1.5           # cgroup v2 cpu.max, in CPUs
+
+ionice, memoryMax, and cpuMax work only on Linux, and memoryMax and cpuMax
+only if the runner's cgroup has been delegated to the user.  A run that
+exceeds its CPU time or memoryMax is killed and recorded as failed, as
+is a run whose limits can't be applied (without running its command).
+*/
+
+type ResourcesRaw struct {
+	CPUTime      *string  `json:"cpuTime" yaml:"cpuTime,omitempty"`
+	AddressSpace *string  `json:"addressSpace" yaml:"addressSpace,omitempty"`
+	OpenFiles    *uint64  `json:"openFiles" yaml:"openFiles,omitempty"`
+	Nice         *int     `json:"nice" yaml:"nice,omitempty"`
+	IONice       *string  `json:"ionice" yaml:"ionice,omitempty"`
+	MemoryMax    *string  `json:"memoryMax" yaml:"memoryMax,omitempty"`
+	CPUMax       *float64 `json:"cpuMax" yaml:"cpuMax,omitempty"`
+}
+
+var gIOClassNames = map[string]common.IOClass{
+	"realtime":    common.IOClassRealtime,
+	"best-effort": common.IOClassBestEffort,
+	"idle":        common.IOClassIdle,
+}
+
+/*
+Parse a size like "512k", "2g", or "1048576" (bytes).  Suffixes are
+powers of 1024.
+*/
+func parseByteSize(str string) (uint64, error) {
+	s := strings.TrimSpace(str)
+	mult := uint64(1)
+	if len(s) > 0 {
+		switch s[len(s)-1] {
+		case 'k', 'K':
+			mult = 1 << 10
+		case 'm', 'M':
+			mult = 1 << 20
+		case 'g', 'G':
+			mult = 1 << 30
+		case 't', 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || n == 0 {
+		return 0, &common.Error{What: fmt.Sprintf("Invalid size: \"%v\"", str)}
+	}
+	return n * mult, nil
+}
+
+func parseIONice(s string) (common.IOClass, int, error) {
+	parts := strings.SplitN(s, ":", 2)
+	class, ok := gIOClassNames[strings.ToLower(parts[0])]
+	if !ok {
+		msg := fmt.Sprintf("Invalid IO class: \"%v\" (must be realtime, "+
+			"best-effort, or idle)", parts[0])
+		return 0, 0, &common.Error{What: msg}
+	}
+	level := 4
+	if len(parts) == 2 {
+		var err error
+		level, err = strconv.Atoi(parts[1])
+		if err != nil || level < 0 || level > 7 {
+			msg := fmt.Sprintf("Invalid IO level: \"%v\" (must be 0 to 7)",
+				parts[1])
+			return 0, 0, &common.Error{What: msg}
+		}
+		if class == common.IOClassIdle {
+			return 0, 0, &common.Error{What: "Class idle has no levels"}
+		}
+	}
+	if class == common.IOClassIdle {
+		level = 0
+	}
+	return class, level, nil
+}
+
+func (self ResourcesRaw) ToResourceLimits() (*common.ResourceLimits, error) {
+	var limits common.ResourceLimits
+	var err error
+
+	if self.CPUTime != nil {
+		limits.CPUTime, err = time.ParseDuration(*self.CPUTime)
+		if err != nil {
+			return nil, &common.Error{What: "Invalid cpuTime", Cause: err}
+		}
+		if limits.CPUTime <= 0 {
+			return nil, &common.Error{What: "cpuTime must be positive"}
+		}
+	}
+	if self.AddressSpace != nil {
+		if limits.AddrSpace, err = parseByteSize(*self.AddressSpace); err != nil {
+			return nil, &common.Error{What: "Invalid addressSpace", Cause: err}
+		}
+	}
+	if self.OpenFiles != nil {
+		if *self.OpenFiles == 0 {
+			return nil, &common.Error{What: "openFiles must be positive"}
+		}
+		limits.OpenFiles = *self.OpenFiles
+	}
+	if self.Nice != nil {
+		if *self.Nice < -20 || *self.Nice > 19 {
+			return nil, &common.Error{What: "nice must be from -20 to 19"}
+		}
+		nice := *self.Nice
+		limits.Nice = &nice
+	}
+	if self.IONice != nil {
+		limits.IOClass, limits.IOLevel, err = parseIONice(*self.IONice)
+		if err != nil {
+			return nil, &common.Error{What: "Invalid ionice", Cause: err}
+		}
+	}
+	if self.MemoryMax != nil {
+		if limits.MemoryMax, err = parseByteSize(*self.MemoryMax); err != nil {
+			return nil, &common.Error{What: "Invalid memoryMax", Cause: err}
+		}
+	}
+	if self.CPUMax != nil {
+		if *self.CPUMax < 0.01 {
+			return nil, &common.Error{What: "cpuMax must be at least 0.01"}
+		}
+		limits.CPUMax = *self.CPUMax
+	}
+	if limits == (common.ResourceLimits{}) {
+		return nil, &common.Error{What: "No limits"}
+	}
+	return &limits, nil
+}
@@ -153,6 +153,11 @@ func SerializeRunRec(rec RunRec, data ResultSinkDataParam) []byte {
 		"startTime": rec.RunTime.Unix(),
 		"succeeded": rec.Fate == common.SubprocFateSucceeded,
 		"fate":      rec.Fate,
+		"manual":    rec.Manual,
+		"runId":     rec.RunId,
+	}
+	if rec.Attempt > 0 {
+		recJson["attempt"] = rec.Attempt
 	}
 
 	if data.Contains(RESULT_SINK_DATA_STDOUT) {
@@ -24,12 +24,14 @@ For a job named "JobOne", this will result in a set of files like this:
 
   - /some/dir/
     - JobOne/
-      - 1521318351.stdout
-      - 1521318351.stderr
-      - 1521318411.stdout
-      - 1521318411.stderr
-      - 1521318471.stdout
-      - 1521318471.stderr
+      - 1521318351_20180317T202551Z-1a2b3c4d.stdout
+      - 1521318351_20180317T202551Z-1a2b3c4d.stderr
+      - 1521318411_20180317T202651Z-5e6f7a8b.stdout
+      - 1521318411_20180317T202651Z-5e6f7a8b.stderr
+
+The first part of each name is the run's start time (in seconds since
+the Epoch), and the second is the run's ID.  (Files written by older
+versions of Jobber lack the run ID.)
 */
 type FilesystemResultSink struct {
 	Path       string              `yaml:"path"`
@@ -79,14 +81,14 @@ func (self FilesystemResultSink) Handle(rec RunRec) {
 
 	// write output
 	if self.Data.Contains(RESULT_SINK_DATA_STDOUT) {
-		fileName := runTimeToFileName(rec.RunTime, _FS_SINK_STDOUT_SUFFIX)
+		fileName := runToFileName(rec, _FS_SINK_STDOUT_SUFFIX)
 		path := filepath.Join(dirPath, fileName)
 		if err := ioutil.WriteFile(path, rec.Stdout, 0600); err != nil {
 			common.ErrLogger.Println(err.Error())
 		}
 	}
 	if self.Data.Contains(RESULT_SINK_DATA_STDERR) {
-		fileName := runTimeToFileName(rec.RunTime, _FS_SINK_STDERR_SUFFIX)
+		fileName := runToFileName(rec, _FS_SINK_STDERR_SUFFIX)
 		path := filepath.Join(dirPath, fileName)
 		if err := ioutil.WriteFile(path, rec.Stderr, 0600); err != nil {
 			common.ErrLogger.Println(err.Error())
@@ -97,8 +99,11 @@ func (self FilesystemResultSink) Handle(rec RunRec) {
 	deleteOldOutputs(dirPath, self.MaxAgeDays)
 }
 
-func runTimeToFileName(t time.Time, suffix string) string {
-	return fmt.Sprintf("%v.%v", t.Unix(), suffix)
+func runToFileName(rec RunRec, suffix string) string {
+	if !IsValidRunId(rec.RunId) {
+		return fmt.Sprintf("%v.%v", rec.RunTime.Unix(), suffix)
+	}
+	return fmt.Sprintf("%v_%v.%v", rec.RunTime.Unix(), rec.RunId, suffix)
 }
 
 func fileNameToRunTime(name string) (time.Time, error) {
@@ -108,7 +113,8 @@ func fileNameToRunTime(name string) (time.Time, error) {
 		(parts[1] != _FS_SINK_STDOUT_SUFFIX && parts[1] != _FS_SINK_STDERR_SUFFIX) {
 		return time.Time{}, &retErr
 	}
-	secs, err := strconv.Atoi(parts[0])
+	secsStr := strings.SplitN(parts[0], "_", 2)[0]
+	secs, err := strconv.Atoi(secsStr)
 	if err != nil {
 		return time.Time{}, &retErr
 	}
@@ -0,0 +1,115 @@
+package jobfile
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+A job's "retry" block says to re-run it after a delay when it fails,
+rather than waiting for its next scheduled time:
+
+	retry:
+	  attempts: 4      # at most 4 runs in all
+	  delay: 10s       # wait 10s before the second run
+	  multiplier: 2    # then 20s, then 40s, ...
+	  maxDelay: 1m     # ... but never more than 1m
+	  jitter: 0.1      # and vary each delay randomly by up to 10%
+
+Every attempt is recorded in the run log.  The job's error handler and
+notifyOnError/notifyOnFailure apply only to the final attempt.
+*/
+
+type RetryRaw struct {
+	Attempts   int      `json:"attempts" yaml:"attempts"`
+	Delay      *string  `json:"delay" yaml:"delay,omitempty"`
+	Multiplier *float64 `json:"multiplier" yaml:"multiplier,omitempty"`
+	MaxDelay   *string  `json:"maxDelay" yaml:"maxDelay,omitempty"`
+	Jitter     *float64 `json:"jitter" yaml:"jitter,omitempty"`
+}
+
+const (
+	gDefaultRetryDelay      = 10 * time.Second
+	gDefaultRetryMultiplier = 2.0
+	gMaxRetryDelay          = time.Duration(math.MaxInt64)
+)
+
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+type RetryPolicy struct {
+	Attempts   int // total number of attempts, including the first
+	Delay      time.Duration
+	Multiplier float64
+	MaxDelay   time.Duration // 0 means no limit
+	Jitter     float64       // fraction of each delay by which to vary it
+}
+
+func (self RetryRaw) ToRetryPolicy() (*RetryPolicy, error) {
+	policy := RetryPolicy{
+		Attempts:   self.Attempts,
+		Delay:      gDefaultRetryDelay,
+		Multiplier: gDefaultRetryMultiplier,
+	}
+	if policy.Attempts < 1 {
+		return nil, &common.Error{What: "attempts must be positive"}
+	}
+
+	var err error
+	if self.Delay != nil {
+		if policy.Delay, err = time.ParseDuration(*self.Delay); err != nil {
+			return nil, &common.Error{What: "Invalid delay", Cause: err}
+		}
+		if policy.Delay < 0 {
+			return nil, &common.Error{What: "delay must not be negative"}
+		}
+	}
+	if self.Multiplier != nil {
+		policy.Multiplier = *self.Multiplier
+		if !isFinite(policy.Multiplier) || policy.Multiplier < 1 {
+			return nil, &common.Error{What: "multiplier must be at least 1"}
+		}
+	}
+	if self.MaxDelay != nil {
+		if policy.MaxDelay, err = time.ParseDuration(*self.MaxDelay); err != nil {
+			return nil, &common.Error{What: "Invalid maxDelay", Cause: err}
+		}
+		if policy.MaxDelay < policy.Delay {
+			msg := fmt.Sprintf("maxDelay must be at least delay (%v)",
+				policy.Delay)
+			return nil, &common.Error{What: msg}
+		}
+	}
+	if self.Jitter != nil {
+		policy.Jitter = *self.Jitter
+		if !isFinite(policy.Jitter) || policy.Jitter < 0 || policy.Jitter > 1 {
+			return nil, &common.Error{What: "jitter must be between 0 and 1"}
+		}
+	}
+	return &policy, nil
+}
+
+/*
+How long to wait after the given (1-based) attempt fails before making
+the next one.  r is a random number in [0, 1), used for jitter.
+*/
+func (self *RetryPolicy) DelayAfter(attempt int, r float64) time.Duration {
+	if self.Delay == 0 {
+		return 0
+	}
+	delay := float64(self.Delay) * math.Pow(self.Multiplier, float64(attempt-1))
+	if self.MaxDelay > 0 && delay > float64(self.MaxDelay) {
+		delay = float64(self.MaxDelay)
+	}
+	delay *= 1 + self.Jitter*(2*r-1)
+
+	/* without a maxDelay, enough attempts would overflow time.Duration */
+	if delay >= float64(gMaxRetryDelay) {
+		return gMaxRetryDelay
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,63 @@
+package jobfile
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyDelayAfter(t *testing.T) {
+	policy := RetryPolicy{
+		Attempts:   5,
+		Delay:      10 * time.Second,
+		Multiplier: 2,
+		MaxDelay:   time.Minute,
+	}
+	require.Equal(t, 10*time.Second, policy.DelayAfter(1, 0))
+	require.Equal(t, 20*time.Second, policy.DelayAfter(2, 0))
+	require.Equal(t, 40*time.Second, policy.DelayAfter(3, 0))
+	require.Equal(t, time.Minute, policy.DelayAfter(4, 0))
+
+	// jitter varies the delay by up to the given fraction either way
+	policy.Jitter = 0.5
+	require.Equal(t, 5*time.Second, policy.DelayAfter(1, 0))
+	require.Equal(t, 10*time.Second, policy.DelayAfter(1, 0.5))
+	require.Equal(t, 90*time.Second, policy.DelayAfter(4, 1))
+
+	// without a maxDelay, the delay doesn't overflow
+	policy = RetryPolicy{Attempts: 5000, Delay: time.Second, Multiplier: 2}
+	require.Equal(t, gMaxRetryDelay, policy.DelayAfter(100, 0))
+	require.Equal(t, gMaxRetryDelay, policy.DelayAfter(4999, 0))
+	policy.Delay = 0
+	require.Equal(t, time.Duration(0), policy.DelayAfter(4999, 0))
+}
+
+func TestRetryRawToRetryPolicy(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(f float64) *float64 { return &f }
+
+	policy, err := RetryRaw{Attempts: 2}.ToRetryPolicy()
+	require.Nil(t, err)
+	require.Equal(t, RetryPolicy{
+		Attempts:   2,
+		Delay:      gDefaultRetryDelay,
+		Multiplier: gDefaultRetryMultiplier,
+	}, *policy)
+
+	for _, raw := range []RetryRaw{
+		{Attempts: 0},
+		{Attempts: 2, Delay: str("soon")},
+		{Attempts: 2, Delay: str("-1s")},
+		{Attempts: 2, Multiplier: num(0.5)},
+		{Attempts: 2, Delay: str("1m"), MaxDelay: str("30s")},
+		{Attempts: 2, Multiplier: num(math.NaN())},
+		{Attempts: 2, Multiplier: num(math.Inf(1))},
+		{Attempts: 2, Jitter: num(1.5)},
+		{Attempts: 2, Jitter: num(math.NaN())},
+	} {
+		_, err := raw.ToRetryPolicy()
+		require.NotNil(t, err, "%+v", raw)
+	}
+}
@@ -0,0 +1,68 @@
+package jobfile
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+/*
+Every run of a job gets an ID that is unique among the runs of all
+jobs.  It looks like "20171028T000000Z-1a2b3c4d": the (UTC) time at
+which the run started, followed by some random hex digits.  Thus IDs
+sort by start time, and they can be used in filenames.
+
+A job can get the ID of its current run from the JOBBER_RUN_ID
+environment variable.
+*/
+
+const (
+	RunIdEnvVar   = "JOBBER_RUN_ID"
+	JobNameEnvVar = "JOBBER_JOB_NAME"
+)
+
+const gRunIdTimeFmt = "20060102T150405Z"
+
+func NewRunId(startTime time.Time) string {
+	var randBytes [4]byte
+	if _, err := rand.Read(randBytes[:]); err != nil {
+		// very unlikely; fall back on the clock
+		nanos := uint32(time.Now().UnixNano())
+		randBytes = [4]byte{byte(nanos >> 24), byte(nanos >> 16),
+			byte(nanos >> 8), byte(nanos)}
+	}
+	return fmt.Sprintf("%v-%v", startTime.UTC().Format(gRunIdTimeFmt),
+		hex.EncodeToString(randBytes[:]))
+}
+
+/*
+Return whether the given string could be a run ID.  (This is lax, so
+that we accept IDs made by other versions of Jobber, but it ensures
+that an ID can be safely used as a filename and as a field in the run
+log.)
+*/
+func IsValidRunId(s string) bool {
+	if len(s) == 0 || len(s) > 64 {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z',
+			c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Get the environment variables that tell a job about its current run.
+*/
+func RunEnv(jobName string, runId string) []string {
+	return []string{
+		RunIdEnvVar + "=" + runId,
+		JobNameEnvVar + "=" + jobName,
+	}
+}
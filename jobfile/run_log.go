@@ -12,6 +12,20 @@ type RunLogEntry struct {
 	Fate     common.SubprocFate
 	Result   JobStatus
 	ExecTime time.Duration
+	Manual   bool   // whether the run was triggered by "jobber run"
+	RunId    string // "" for runs logged by older versions of Jobber
+
+	// why the run was skipped (for SubprocFateSkipped)
+	SkipReason string
+
+	// which attempt this was, for jobs with a retry policy (0 otherwise)
+	Attempt int
+
+	// how long the run waited for a slot (cf. UserPrefs.MaxConcurrentJobs)
+	QueueDelay time.Duration
+
+	// the resource limits the run exceeded, if any (cf. Job.Resources)
+	LimitExceeded string
 }
 
 /*
@@ -0,0 +1,219 @@
+package jobfile
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+Describes a subset of a run log's entries.  Zero values mean "no
+constraint".
+*/
+type RunLogQuery struct {
+	Jobs  []string
+	Fates []string // e.g., "failed"
+
+	// entries for runs that started no earlier than this
+	Since *time.Time
+
+	// entries for runs that started no later than this
+	Until *time.Time
+
+	// max number of entries to return
+	Limit int
+
+	// from the result of a previous query with the same constraints
+	Cursor string
+}
+
+/*
+The fates that can appear in run log entries.
+*/
+var RunLogFates = []common.SubprocFate{
+	common.SubprocFateSucceeded,
+	common.SubprocFateFailed,
+	common.SubprocFateCancelled,
+	common.SubprocFateSkipped,
+	common.SubprocFateWarning,
+}
+
+/*
+How many entries we read from a run log at once.
+*/
+const gRunLogQueryChunkLen = 100
+
+/*
+A position in a run log that doesn't change when entries are added: it
+refers to the entries that started no later than Time, minus the first
+Skip of those that started exactly at Time.
+*/
+type runLogCursor struct {
+	Time time.Time
+	Skip int
+}
+
+func (self runLogCursor) String() string {
+	return fmt.Sprintf("%v:%v", self.Time.UnixNano(), self.Skip)
+}
+
+func parseRunLogCursor(s string) (*runLogCursor, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return nil, &common.Error{What: fmt.Sprintf("Invalid cursor: \"%v\"", s)}
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, &common.Error{What: fmt.Sprintf("Invalid cursor: \"%v\"", s)}
+	}
+	skip, err := strconv.Atoi(parts[1])
+	if err != nil || skip < 0 {
+		return nil, &common.Error{What: fmt.Sprintf("Invalid cursor: \"%v\"", s)}
+	}
+	return &runLogCursor{Time: time.Unix(0, nanos), Skip: skip}, nil
+}
+
+/*
+Get the entries matching the given query, in order of start time,
+descending.  If the query's limit was reached, also returns a cursor
+that can be used to get the next entries (though there might not be any
+more matching ones); otherwise, returns "".
+*/
+func QueryRunLog(runLog RunLog, query RunLogQuery) ([]*RunLogEntry, string,
+	error) {
+
+	// check fates
+	fates := make(map[string]bool)
+	for _, fate := range query.Fates {
+		fate = strings.ToLower(fate)
+		ok := false
+		for _, knownFate := range RunLogFates {
+			if fate == knownFate.String() {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			var knownFates []string
+			for _, knownFate := range RunLogFates {
+				knownFates = append(knownFates, knownFate.String())
+			}
+			msg := fmt.Sprintf("Invalid fate: \"%v\" (expected one of: %v)",
+				fate, strings.Join(knownFates, ", "))
+			return nil, "", &common.Error{What: msg}
+		}
+		fates[fate] = true
+	}
+	isForJobs := func(entry *RunLogEntry) bool {
+		for _, job := range query.Jobs {
+			if runLogEntryIsFor(entry, job) {
+				return true
+			}
+		}
+		return false
+	}
+	matches := func(entry *RunLogEntry) bool {
+		if len(query.Jobs) > 0 && !isForJobs(entry) {
+			return false
+		}
+		if len(fates) > 0 && !fates[entry.Fate.String()] {
+			return false
+		}
+		return true
+	}
+
+	// figure out where to start
+	var cursor *runLogCursor
+	if len(query.Cursor) > 0 {
+		var err error
+		if cursor, err = parseRunLogCursor(query.Cursor); err != nil {
+			return nil, "", err
+		}
+	}
+	var maxTime *time.Time
+	if query.Until != nil {
+		maxTime = query.Until
+	}
+	if cursor != nil && (maxTime == nil || !cursor.Time.After(*maxTime)) {
+		maxTime = &cursor.Time
+	} else {
+		cursor = nil
+	}
+	startIdx, err := firstRunLogIdxNotAfter(runLog, maxTime)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// read entries
+	result := make([]*RunLogEntry, 0)
+	var lastTime time.Time
+	nbrAtLastTime := 0
+	for idx := startIdx; idx < runLog.Len(); idx += gRunLogQueryChunkLen {
+		end := idx + gRunLogQueryChunkLen
+		if end > runLog.Len() {
+			end = runLog.Len()
+		}
+		entries, err := runLog.GetFromIndex(idx, end)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for i, entry := range entries {
+			if query.Since != nil && entry.Time.Before(*query.Since) {
+				return result, "", nil
+			}
+
+			// keep track of where we are
+			if entry.Time.Equal(lastTime) {
+				nbrAtLastTime++
+			} else {
+				lastTime = entry.Time
+				nbrAtLastTime = 1
+			}
+			if cursor != nil && entry.Time.Equal(cursor.Time) &&
+				nbrAtLastTime <= cursor.Skip {
+				continue
+			}
+
+			if !matches(entry) {
+				continue
+			}
+			result = append(result, entry)
+			if query.Limit > 0 && len(result) >= query.Limit {
+				if idx+i+1 >= runLog.Len() {
+					return result, "", nil
+				}
+				next := runLogCursor{Time: lastTime, Skip: nbrAtLastTime}
+				return result, next.String(), nil
+			}
+		}
+	}
+	return result, "", nil
+}
+
+/*
+Get the index of the latest entry that started no later than t (or 0,
+if t is nil).  Remember that entries with lower indices are later.
+*/
+func firstRunLogIdxNotAfter(runLog RunLog, t *time.Time) (int, error) {
+	if t == nil {
+		return 0, nil
+	}
+	var searchErr error
+	idx := sort.Search(runLog.Len(), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		entries, err := runLog.GetFromIndex(i, i+1)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return !entries[0].Time.After(*t)
+	})
+	return idx, searchErr
+}
@@ -200,6 +200,57 @@ func (self *RunLogTestSuite) TestGetFromIndex() {
 	)
 }
 
+func (self *RunLogTestSuite) TestQuery() {
+	// no constraints
+	entries, cursor, err := QueryRunLog(self.runLog, RunLogQuery{})
+	require.Nil(self.T(), err)
+	require.Equal(self.T(), "", cursor)
+	require.Equal(self.T(), entriesToTimes(self.expEntryArray),
+		entriesToTimes(entries))
+
+	// job filter
+	entries, _, err = QueryRunLog(self.runLog,
+		RunLogQuery{Jobs: []string{"Entry 0", "Entry 1"}})
+	require.Nil(self.T(), err)
+	require.Equal(self.T(), 2, len(entries))
+	require.Equal(self.T(), "Entry 1", entries[0].JobName)
+	require.Equal(self.T(), "Entry 0", entries[1].JobName)
+
+	// fate filter
+	entries, _, err = QueryRunLog(self.runLog,
+		RunLogQuery{Fates: []string{"failed"}})
+	require.Nil(self.T(), err)
+	require.Equal(self.T(), 0, len(entries))
+	_, _, err = QueryRunLog(self.runLog, RunLogQuery{Fates: []string{"nope"}})
+	require.NotNil(self.T(), err)
+
+	// time range
+	since := self.expEntryArray[len(self.expEntryArray)-1].Time.Add(time.Hour)
+	until := self.expEntryArray[0].Time.Add(-time.Hour)
+	entries, _, err = QueryRunLog(self.runLog,
+		RunLogQuery{Since: &since, Until: &until})
+	require.Nil(self.T(), err)
+	require.Equal(self.T(), entriesToTimes(self.expEntryArray[1:6]),
+		entriesToTimes(entries))
+
+	// pages (including a page boundary between entries with the same time)
+	var all []*RunLogEntry
+	cursor = ""
+	for i := 0; ; i++ {
+		require.True(self.T(), i < len(self.expEntryArray), "Too many pages")
+		entries, cursor, err = QueryRunLog(self.runLog,
+			RunLogQuery{Limit: 2, Cursor: cursor})
+		require.Nil(self.T(), err)
+		all = append(all, entries...)
+		if len(cursor) == 0 {
+			break
+		}
+	}
+	require.Equal(self.T(), entriesToTimes(self.expEntryArray),
+		entriesToTimes(all))
+	require.Equal(self.T(), len(self.expEntryArray), len(all))
+}
+
 func TestMemOnlyRunLog(t *testing.T) {
 	makeRunLog := func() (RunLog, error) {
 		return NewMemOnlyRunLog(10), nil
@@ -0,0 +1,382 @@
+package jobfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+The output (stdout and stderr) of one run of a job.
+*/
+type RunOutput struct {
+	RunOutputDesc
+	Stdout []byte
+	Stderr []byte
+}
+
+/*
+Describes a run whose output is stored.
+*/
+type RunOutputDesc struct {
+	JobName   string             `json:"job"`
+	RunId     string             `json:"runId"`
+	Time      time.Time          `json:"time"`
+	Fate      common.SubprocFate `json:"fate"`
+	StdoutLen int                `json:"stdoutLen"`
+	StderrLen int                `json:"stderrLen"`
+}
+
+/*
+This is a store of the output of recent runs.  It is part of the run
+log prefs; like the run log, it is kept in memory or in files,
+depending on the type of run log.  Output is kept compressed.
+
+Only the most recent runs are kept: there is a limit on the number of
+runs per job, and a limit on the total (compressed) size of the store.
+*/
+type RunOutputStore interface {
+	/*
+		Store the output of a run.  output.RunId must be set.
+	*/
+	Put(output RunOutput) error
+
+	/*
+		Get the output of the run of the given job with the given ID.
+		If runId is "", gets the latest run.  Returns nil if there is
+		no such run.
+	*/
+	Get(jobName string, runId string) (*RunOutput, error)
+
+	/*
+		Describe the stored runs of the given job, latest first.
+	*/
+	List(jobName string) []RunOutputDesc
+}
+
+const (
+	gDefaultMaxOutputRunsPerJob int   = 10
+	gDefaultMaxOutputTotalLen   int64 = 20 * (1 << 20)
+)
+
+type runOutputRec struct {
+	desc RunOutputDesc
+	len  int64  // compressed
+	data []byte // compressed; nil if kept in a file
+}
+
+type runOutputStore struct {
+	dir           string // if "", output is kept in memory
+	maxRunsPerJob int
+	maxTotalLen   int64
+
+	// ordered by time, ascending
+	recs     map[string][]*runOutputRec
+	totalLen int64
+}
+
+func (self *runOutputStore) String() string {
+	return fmt.Sprintf(
+		"RunOutputStore{dir: %v, maxRunsPerJob: %v, maxTotalLen: %v}",
+		self.dir,
+		self.maxRunsPerJob,
+		self.maxTotalLen,
+	)
+}
+
+func NewMemOnlyRunOutputStore(maxRunsPerJob int,
+	maxTotalLen int64) RunOutputStore {
+
+	return &runOutputStore{
+		maxRunsPerJob: maxRunsPerJob,
+		maxTotalLen:   maxTotalLen,
+		recs:          make(map[string][]*runOutputRec),
+	}
+}
+
+/*
+Make a store that keeps output in files in the given dir (which is
+made if necessary).  Output already in the dir is kept (subject to the
+limits).
+*/
+func NewFileRunOutputStore(dir string, maxRunsPerJob int,
+	maxTotalLen int64) (RunOutputStore, error) {
+
+	store := &runOutputStore{
+		dir:           dir,
+		maxRunsPerJob: maxRunsPerJob,
+		maxTotalLen:   maxTotalLen,
+		recs:          make(map[string][]*runOutputRec),
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := store.loadIndex(); err != nil {
+		return nil, err
+	}
+	store.applyLimits()
+	return store, nil
+}
+
+/*
+Output files look like DIR/JOB/RUNID.gz, where JOB is the
+path-escaped job name (with the dots of "." and ".." escaped too).
+Each contains a line of JSON (a RunOutputDesc) followed by the stdout
+and then the stderr.
+*/
+func (self *runOutputStore) jobDir(jobName string) string {
+	name := url.PathEscape(jobName)
+	if name == "." || name == ".." {
+		name = strings.Replace(name, ".", "%2E", -1)
+	}
+	return filepath.Join(self.dir, name)
+}
+
+func (self *runOutputStore) runPath(jobName string, runId string) string {
+	return filepath.Join(self.jobDir(jobName), runId+".gz")
+}
+
+func (self *runOutputStore) loadIndex() error {
+	jobDirs, err := ioutil.ReadDir(self.dir)
+	if err != nil {
+		return err
+	}
+	for _, jobDir := range jobDirs {
+		if !jobDir.IsDir() {
+			continue
+		}
+		jobName, err := url.PathUnescape(jobDir.Name())
+		if err != nil {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(self.dir, jobDir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			runId := strings.TrimSuffix(file.Name(), ".gz")
+			if runId == file.Name() {
+				continue
+			}
+			desc, err := self.readDesc(jobName, runId)
+			if err != nil {
+				common.ErrLogger.Printf("Ignoring bad output file %v: %v",
+					self.runPath(jobName, runId), err)
+				continue
+			}
+			self.add(&runOutputRec{desc: *desc, len: file.Size()})
+		}
+	}
+	for jobName := range self.recs {
+		recs := self.recs[jobName]
+		sort.Slice(recs, func(i, j int) bool {
+			return recs[i].desc.Time.Before(recs[j].desc.Time)
+		})
+	}
+	return nil
+}
+
+func (self *runOutputStore) readDesc(jobName string,
+	runId string) (*RunOutputDesc, error) {
+
+	f, err := os.Open(self.runPath(jobName, runId))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gzReader, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(gzReader).ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var desc RunOutputDesc
+	if err := json.Unmarshal(line, &desc); err != nil {
+		return nil, err
+	}
+	desc.JobName = jobName
+	desc.RunId = runId
+	return &desc, nil
+}
+
+func (self *runOutputStore) add(rec *runOutputRec) {
+	jobName := rec.desc.JobName
+	self.recs[jobName] = append(self.recs[jobName], rec)
+	self.totalLen += rec.len
+}
+
+func (self *runOutputStore) Put(output RunOutput) error {
+	jobName := output.JobName
+	if !IsValidRunId(output.RunId) {
+		msg := fmt.Sprintf("Invalid run ID: \"%v\"", output.RunId)
+		return &common.Error{What: msg}
+	}
+	output.StdoutLen = len(output.Stdout)
+	output.StderrLen = len(output.Stderr)
+
+	// compress
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	header, err := json.Marshal(output.RunOutputDesc)
+	if err != nil {
+		return err
+	}
+	gzWriter.Write(header)
+	gzWriter.Write([]byte("\n"))
+	gzWriter.Write(output.Stdout)
+	gzWriter.Write(output.Stderr)
+	if err := gzWriter.Close(); err != nil {
+		return err
+	}
+
+	// store
+	rec := &runOutputRec{desc: output.RunOutputDesc, len: int64(buf.Len())}
+	if len(self.dir) == 0 {
+		rec.data = buf.Bytes()
+	} else {
+		if err := os.MkdirAll(self.jobDir(jobName), 0700); err != nil {
+			return err
+		}
+		path := self.runPath(jobName, output.RunId)
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+			return err
+		}
+	}
+	self.add(rec)
+	self.applyLimits()
+	return nil
+}
+
+/*
+Delete old output until we're within the limits.
+*/
+func (self *runOutputStore) applyLimits() {
+	// apply per-job limit
+	for jobName, recs := range self.recs {
+		for len(recs) > self.maxRunsPerJob {
+			self.remove(recs[0])
+			recs = recs[1:]
+		}
+		self.recs[jobName] = recs
+	}
+
+	// apply total limit, deleting the oldest runs first
+	for self.totalLen > self.maxTotalLen {
+		var oldest *runOutputRec
+		for _, recs := range self.recs {
+			if len(recs) > 0 &&
+				(oldest == nil || recs[0].desc.Time.Before(oldest.desc.Time)) {
+				oldest = recs[0]
+			}
+		}
+		if oldest == nil {
+			break
+		}
+		self.remove(oldest)
+		self.recs[oldest.desc.JobName] = self.recs[oldest.desc.JobName][1:]
+	}
+
+	for jobName, recs := range self.recs {
+		if len(recs) == 0 {
+			delete(self.recs, jobName)
+			if len(self.dir) > 0 {
+				os.Remove(self.jobDir(jobName))
+			}
+		}
+	}
+}
+
+/*
+Delete the given record's output.  (The caller must remove the record
+from self.recs.)
+*/
+func (self *runOutputStore) remove(rec *runOutputRec) {
+	self.totalLen -= rec.len
+	if len(self.dir) > 0 {
+		path := self.runPath(rec.desc.JobName, rec.desc.RunId)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			common.ErrLogger.Printf("Failed to remove %v: %v", path, err)
+		}
+	}
+}
+
+func (self *runOutputStore) Get(jobName string, runId string) (*RunOutput,
+	error) {
+
+	// find record
+	recs := self.recs[jobName]
+	var rec *runOutputRec
+	if len(runId) == 0 && len(recs) > 0 {
+		rec = recs[len(recs)-1]
+	} else {
+		for _, r := range recs {
+			if r.desc.RunId == runId {
+				rec = r
+				break
+			}
+		}
+	}
+	if rec == nil {
+		return nil, nil
+	}
+
+	// get compressed data
+	var reader io.Reader
+	if rec.data != nil {
+		reader = bytes.NewReader(rec.data)
+	} else {
+		f, err := os.Open(self.runPath(rec.desc.JobName, rec.desc.RunId))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	// decompress
+	gzReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(gzReader)
+	if err != nil {
+		return nil, err
+	}
+	newlineIdx := bytes.IndexByte(data, '\n')
+	if newlineIdx < 0 {
+		return nil, &common.Error{What: "Invalid output record"}
+	}
+	data = data[newlineIdx+1:]
+	desc := rec.desc
+	if len(data) != desc.StdoutLen+desc.StderrLen {
+		return nil, &common.Error{What: "Invalid output record"}
+	}
+	return &RunOutput{
+		RunOutputDesc: desc,
+		Stdout:        data[:desc.StdoutLen],
+		Stderr:        data[desc.StdoutLen:],
+	}, nil
+}
+
+func (self *runOutputStore) List(jobName string) []RunOutputDesc {
+	recs := self.recs[jobName]
+	descs := make([]RunOutputDesc, 0, len(recs))
+	for i := len(recs) - 1; i >= 0; i-- {
+		descs = append(descs, recs[i].desc)
+	}
+	return descs
+}
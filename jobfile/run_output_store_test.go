@@ -0,0 +1,134 @@
+package jobfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/stretchr/testify/require"
+)
+
+func putTestOutputs(t *testing.T, store RunOutputStore, jobName string,
+	n int, startTime time.Time) []string {
+
+	var runIds []string
+	for i := 0; i < n; i++ {
+		runTime := startTime.Add(time.Duration(i) * time.Minute)
+		output := RunOutput{
+			RunOutputDesc: RunOutputDesc{
+				JobName: jobName,
+				RunId:   NewRunId(runTime),
+				Time:    runTime,
+				Fate:    common.SubprocFateFailed,
+			},
+			Stdout: []byte(fmt.Sprintf("out %v\n", i)),
+			Stderr: []byte(fmt.Sprintf("err %v\n", i)),
+		}
+		require.Nil(t, store.Put(output))
+		runIds = append(runIds, output.RunId)
+	}
+	return runIds
+}
+
+func testRunOutputStore(t *testing.T, makeStore func() RunOutputStore) {
+	store := makeStore()
+	startTime := time.Unix(1509148800, 0)
+
+	// no output yet
+	output, err := store.Get("A", "")
+	require.Nil(t, err)
+	require.Nil(t, output)
+
+	// put 5 runs (but only 3 are kept)
+	runIds := putTestOutputs(t, store, "A", 5, startTime)
+	descs := store.List("A")
+	require.Equal(t, 3, len(descs))
+	require.Equal(t, runIds[4], descs[0].RunId)
+	require.Equal(t, runIds[2], descs[2].RunId)
+
+	// get latest
+	output, err = store.Get("A", "")
+	require.Nil(t, err)
+	require.NotNil(t, output)
+	require.Equal(t, runIds[4], output.RunId)
+	require.Equal(t, "out 4\n", string(output.Stdout))
+	require.Equal(t, "err 4\n", string(output.Stderr))
+	require.Equal(t, common.SubprocFateFailed, output.Fate)
+	require.True(t, startTime.Add(4*time.Minute).Equal(output.Time))
+
+	// get by ID
+	output, err = store.Get("A", runIds[2])
+	require.Nil(t, err)
+	require.NotNil(t, output)
+	require.Equal(t, "out 2\n", string(output.Stdout))
+	output, err = store.Get("A", runIds[1])
+	require.Nil(t, err)
+	require.Nil(t, output)
+
+	// other jobs are separate
+	otherIds := putTestOutputs(t, store, "B/C", 1, startTime.Add(time.Hour))
+	output, err = store.Get("B/C", "")
+	require.Nil(t, err)
+	require.Equal(t, otherIds[0], output.RunId)
+	require.Equal(t, 3, len(store.List("A")))
+}
+
+func TestMemOnlyRunOutputStore(t *testing.T) {
+	testRunOutputStore(t, func() RunOutputStore {
+		return NewMemOnlyRunOutputStore(3, 1<<20)
+	})
+}
+
+func TestFileRunOutputStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	storeDir := filepath.Join(dir, "output")
+
+	makeStore := func() RunOutputStore {
+		store, err := NewFileRunOutputStore(storeDir, 3, 1<<20)
+		require.Nil(t, err, "%v", err)
+		return store
+	}
+	testRunOutputStore(t, makeStore)
+
+	// output survives reopening the store
+	store := makeStore()
+	descs := store.List("A")
+	require.Equal(t, 3, len(descs))
+	output, err := store.Get("A", "")
+	require.Nil(t, err)
+	require.Equal(t, descs[0].RunId, output.RunId)
+	require.Equal(t, "out 4\n", string(output.Stdout))
+
+	// run IDs must be usable as filenames
+	err = store.Put(RunOutput{RunOutputDesc: RunOutputDesc{JobName: "A",
+		RunId: "../x"}})
+	require.NotNil(t, err)
+
+	// job names needn't be
+	for _, name := range []string{".", ".."} {
+		runIds := putTestOutputs(t, store, name, 4, time.Unix(1509148800, 0))
+		require.Equal(t, runIds[3], store.List(name)[0].RunId)
+	}
+	store = makeStore()
+	for _, name := range []string{".", ".."} {
+		require.Equal(t, 3, len(store.List(name)))
+	}
+	require.Equal(t, 3, len(store.List("A")))
+	entries, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(entries))
+}
+
+func TestRunOutputStoreTotalLimit(t *testing.T) {
+	store := NewMemOnlyRunOutputStore(100, 1)
+	putTestOutputs(t, store, "A", 1, time.Unix(1509148800, 0))
+
+	// the latest run is dropped too if it doesn't fit
+	require.Equal(t, 0, len(store.List("A")))
+}
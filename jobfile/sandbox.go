@@ -0,0 +1,61 @@
+package jobfile
+
+import (
+	"path/filepath"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+A job's "sandbox" block restricts what its runs may see and do:
+
+	sandbox:
+	  privateTmp: true        # give the job its own, empty /tmp
+	  readOnly: [/home/me]    # paths the job may read but not modify
+	  noNetwork: true         # no network access (only loopback)
+	  noNewPrivs: true        # setuid programs etc. don't gain privileges
+
+Sandboxes work only on Linux (elsewhere, a "sandbox" block is an
+error), and only if the kernel lets unprivileged users make user
+namespaces; if it doesn't, the job's runs fail.
+*/
+
+type SandboxRaw struct {
+	PrivateTmp *bool    `json:"privateTmp" yaml:"privateTmp,omitempty"`
+	ReadOnly   []string `json:"readOnly" yaml:"readOnly,omitempty"`
+	NoNetwork  *bool    `json:"noNetwork" yaml:"noNetwork,omitempty"`
+	NoNewPrivs *bool    `json:"noNewPrivs" yaml:"noNewPrivs,omitempty"`
+}
+
+func (self SandboxRaw) ToSandbox() (*common.Sandbox, error) {
+	if !common.SandboxesSupported {
+		return nil, &common.Error{
+			What: "Sandboxes are supported only on Linux",
+		}
+	}
+
+	var sandbox common.Sandbox
+	if self.PrivateTmp != nil {
+		sandbox.PrivateTmp = *self.PrivateTmp
+	}
+	if self.NoNetwork != nil {
+		sandbox.NoNetwork = *self.NoNetwork
+	}
+	if self.NoNewPrivs != nil {
+		sandbox.NoNewPrivs = *self.NoNewPrivs
+	}
+	for _, path := range self.ReadOnly {
+		if !filepath.IsAbs(path) {
+			return nil, &common.Error{
+				What: "readOnly path is not absolute: " + path,
+			}
+		}
+		sandbox.ReadOnly = append(sandbox.ReadOnly, filepath.Clean(path))
+	}
+
+	if !sandbox.PrivateTmp && !sandbox.NoNetwork && !sandbox.NoNewPrivs &&
+		len(sandbox.ReadOnly) == 0 {
+		return nil, &common.Error{What: "No restrictions"}
+	}
+	return &sandbox, nil
+}
@@ -0,0 +1,155 @@
+package jobfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+A job's "secrets" block maps environment variables to files holding
+their values:
+
+	secrets:
+	  API_TOKEN: .secrets/api-token    # relative to the home dir
+
+The files are read right before each run.  Each must be a regular file
+owned by the user and not accessible by anyone else (e.g., mode 0600);
+a trailing newline is dropped.  The values are replaced with
+RedactedSecret in the run's stdout and stderr before anything (result
+sinks, the run log) sees them.
+*/
+
+type SecretSpec struct {
+	EnvVar string
+	Path   string
+}
+
+const RedactedSecret = "[REDACTED]"
+
+var gEnvVarNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func makeSecretSpecs(usr *user.User,
+	raw map[string]string) ([]SecretSpec, error) {
+
+	if len(raw) == 0 {
+		return nil, &common.Error{What: "No secrets"}
+	}
+	var specs []SecretSpec
+	for name, path := range raw {
+		if !gEnvVarNameRegex.MatchString(name) {
+			return nil, &common.Error{
+				What: "Invalid environment variable name: " + name,
+			}
+		}
+		if strings.HasPrefix(name, "JOBBER_") {
+			return nil, &common.Error{
+				What: "Environment variables starting with JOBBER_ " +
+					"are reserved: " + name,
+			}
+		}
+		fullPath, err := guardPath(usr, path)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, SecretSpec{EnvVar: name, Path: fullPath})
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].EnvVar < specs[j].EnvVar
+	})
+	return specs, nil
+}
+
+/*
+The values of a run's secrets.
+*/
+type Secrets struct {
+	Env    []string // "NAME=value"
+	values [][]byte // longest first
+}
+
+/*
+Read the given secrets' files, which must be owned by the current user
+and not accessible by anyone else.
+*/
+func LoadSecrets(specs []SecretSpec) (*Secrets, error) {
+	secrets := &Secrets{}
+	for _, spec := range specs {
+		value, err := readSecretFile(spec.Path)
+		if err != nil {
+			msg := fmt.Sprintf("Failed to load secret %v", spec.EnvVar)
+			return nil, &common.Error{What: msg, Cause: err}
+		}
+		secrets.Env = append(secrets.Env, spec.EnvVar+"="+value)
+		if len(value) > 0 {
+			secrets.values = append(secrets.values, []byte(value))
+		}
+	}
+
+	/* so that a secret that contains another is redacted whole */
+	sort.SliceStable(secrets.values, func(i, j int) bool {
+		return len(secrets.values[i]) > len(secrets.values[j])
+	})
+	return secrets, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", &common.Error{What: path + " is not a regular file"}
+	}
+	if uid := info.Sys().(*syscall.Stat_t).Uid; int(uid) != os.Getuid() {
+		return "", &common.Error{What: path + " is not owned by you"}
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		msg := fmt.Sprintf("%v is accessible by others (mode %04o); "+
+			"only its owner may have access", path, perm)
+		return "", &common.Error{What: msg}
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+/*
+The length of the longest secret value.
+*/
+func (self *Secrets) MaxLen() int {
+	if len(self.values) == 0 {
+		return 0
+	}
+	return len(self.values[0])
+}
+
+/*
+Replace the secrets' values in data with RedactedSecret.  (To truncate
+data, do so afterwards, reading up to MaxLen() extra bytes: else, a
+value cut in two would escape redaction and its start be kept.)
+*/
+func (self *Secrets) Redact(data []byte) []byte {
+	for _, value := range self.values {
+		data = bytes.Replace(data, value, []byte(RedactedSecret), -1)
+	}
+	return data
+}
@@ -0,0 +1,65 @@
+package jobfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecretFile(t *testing.T, dir string, name string, value string,
+	perm os.FileMode) string {
+
+	path := filepath.Join(dir, name)
+	require.Nil(t, ioutil.WriteFile(path, []byte(value), perm))
+	require.Nil(t, os.Chmod(path, perm))
+	return path
+}
+
+func TestLoadSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobber-secrets-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	specs := []SecretSpec{
+		{"API_TOKEN", writeSecretFile(t, dir, "token", "abc123\n", 0600)},
+		{"DB_PASSWORD", writeSecretFile(t, dir, "pw", "xabc123y", 0400)},
+		{"EMPTY", writeSecretFile(t, dir, "empty", "", 0600)},
+	}
+	secrets, err := LoadSecrets(specs)
+	require.Nil(t, err)
+	require.Equal(t,
+		[]string{"API_TOKEN=abc123", "DB_PASSWORD=xabc123y", "EMPTY="},
+		secrets.Env)
+
+	/* a secret that contains another is redacted whole */
+	out := secrets.Redact([]byte("token abc123, password xabc123y\n"))
+	require.Equal(t, "token [REDACTED], password [REDACTED]\n", string(out))
+}
+
+func TestLoadSecretsBadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobber-secrets-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cases := []SecretSpec{
+		{"READABLE", writeSecretFile(t, dir, "readable", "x", 0644)},
+		{"WRITABLE", writeSecretFile(t, dir, "writable", "x", 0620)},
+		{"MISSING", filepath.Join(dir, "missing")},
+		{"DIR", dir},
+	}
+	for _, spec := range cases {
+		_, err := LoadSecrets([]SecretSpec{spec})
+		require.NotNil(t, err, "%v", spec.EnvVar)
+		require.Contains(t, err.Error(), "Failed to load secret "+spec.EnvVar)
+	}
+}
+
+func TestRedactNoSecrets(t *testing.T) {
+	secrets, err := LoadSecrets(nil)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(secrets.Env))
+	require.Equal(t, "output", string(secrets.Redact([]byte("output"))))
+}
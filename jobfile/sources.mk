@@ -1,27 +1,55 @@
 JOBFILE_SOURCES := \
+	jobfile/calendar.go \
+	jobfile/crontab.go \
+	jobfile/edit.go \
 	jobfile/error_handler.go \
+	jobfile/guard.go \
 	jobfile/file_run_log.go \
 	jobfile/job_file.go \
 	jobfile/job_output_handler.go \
 	jobfile/job.go \
+	jobfile/load_avg_darwin.go \
+	jobfile/load_avg_freebsd.go \
+	jobfile/load_avg_linux.go \
+	jobfile/lock.go \
 	jobfile/mem_only_run_log.go \
 	jobfile/parse_time_spec.y \
+	jobfile/resources.go \
 	jobfile/result_sink_filesystem.go \
 	jobfile/result_sink_program.go \
 	jobfile/result_sink_socket.go \
 	jobfile/result_sink_stdout.go \
 	jobfile/result_sink_system_email.go \
 	jobfile/result_sink.go \
+	jobfile/retry.go \
 	jobfile/run_log.go \
+	jobfile/run_id.go \
+	jobfile/run_log_query.go \
+	jobfile/run_output_store.go \
 	jobfile/run_rec_server.go \
 	jobfile/safe_bytes_to_str.go \
+	jobfile/sandbox.go \
+	jobfile/secrets.go \
 	jobfile/semver.go \
+	jobfile/success_criteria.go \
 	jobfile/sources.mk \
-	jobfile/time_spec.go
+	jobfile/time_spec.go \
+	jobfile/validate.go \
+	jobfile/watch.go
 
 JOBFILE_TEST_SOURCES := \
+	jobfile/calendar_test.go \
+	jobfile/crontab_test.go \
+	jobfile/edit_test.go \
+	jobfile/error_handler_test.go \
+	jobfile/guard_test.go \
 	jobfile/file_run_log_test.go \
 	jobfile/job_file_v1v2_parse_test.go \
 	jobfile/job_file_v3_parse_test.go \
 	jobfile/parse_time_spec_test.go \
-	jobfile/run_log_test.go
+	jobfile/retry_test.go \
+	jobfile/run_log_test.go \
+	jobfile/run_output_store_test.go \
+	jobfile/secrets_test.go \
+	jobfile/success_criteria_test.go \
+	jobfile/validate_test.go
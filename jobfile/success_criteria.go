@@ -0,0 +1,131 @@
+package jobfile
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+By default, a run succeeds iff the job's command exits with 0.  A job
+can change this:
+
+	successExitCodes: [0, 1]   # exit codes meaning success
+	warningExitCodes: [2]      # exit codes meaning success with a warning
+	outputMatchers:            # regexes on the output that override the above
+	  - stream: stdout         # stdout, stderr, or both (the default)
+	    pattern: ERROR
+	    fate: failed           # succeeded, warning, or failed
+
+The first matcher whose pattern matches decides the run's fate.  A run
+whose fate is "warning" doesn't count as a failure, but its result goes
+to the sinks in notifyOnWarning instead of those in notifyOnSuccess.
+*/
+
+const (
+	OutputStreamStdout = "stdout"
+	OutputStreamStderr = "stderr"
+	OutputStreamBoth   = "both"
+)
+
+type OutputMatcherRaw struct {
+	Stream  string `json:"stream" yaml:"stream,omitempty"`
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Fate    string `json:"fate" yaml:"fate"`
+}
+
+type OutputMatcher struct {
+	Stdout  bool
+	Stderr  bool
+	Pattern *regexp.Regexp
+	Fate    common.SubprocFate
+}
+
+var gOutputMatcherFates = map[string]common.SubprocFate{
+	common.SubprocFateSucceeded.String(): common.SubprocFateSucceeded,
+	common.SubprocFateWarning.String():   common.SubprocFateWarning,
+	common.SubprocFateFailed.String():    common.SubprocFateFailed,
+}
+
+func (self OutputMatcherRaw) ToOutputMatcher() (OutputMatcher, error) {
+	var matcher OutputMatcher
+	switch self.Stream {
+	case OutputStreamStdout:
+		matcher.Stdout = true
+	case OutputStreamStderr:
+		matcher.Stderr = true
+	case "", OutputStreamBoth:
+		matcher.Stdout = true
+		matcher.Stderr = true
+	default:
+		msg := fmt.Sprintf("Invalid stream: \"%v\"", self.Stream)
+		return matcher, &common.Error{What: msg}
+	}
+
+	if len(self.Pattern) == 0 {
+		return matcher, &common.Error{What: "Missing pattern"}
+	}
+	var err error
+	if matcher.Pattern, err = regexp.Compile(self.Pattern); err != nil {
+		msg := fmt.Sprintf("Invalid pattern: \"%v\"", self.Pattern)
+		return matcher, &common.Error{What: msg, Cause: err}
+	}
+
+	var ok bool
+	if matcher.Fate, ok = gOutputMatcherFates[self.Fate]; !ok {
+		msg := fmt.Sprintf("Invalid fate: \"%v\" (must be succeeded, "+
+			"warning, or failed)", self.Fate)
+		return matcher, &common.Error{What: msg}
+	}
+	return matcher, nil
+}
+
+func (self OutputMatcher) Matches(stdout, stderr []byte) bool {
+	return (self.Stdout && self.Pattern.Match(stdout)) ||
+		(self.Stderr && self.Pattern.Match(stderr))
+}
+
+func checkExitCodes(codes []int) error {
+	for _, code := range codes {
+		if code < 0 || code > 255 {
+			msg := fmt.Sprintf("Invalid exit code: %v", code)
+			return &common.Error{What: msg}
+		}
+	}
+	return nil
+}
+
+func containsInt(ints []int, n int) bool {
+	for _, i := range ints {
+		if i == n {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Decide the fate of a run of this job that exited with the given code
+(-1 if killed by a signal) and printed the given output.
+*/
+func (self *Job) Judge(exitCode int, stdout, stderr []byte) common.SubprocFate {
+	for _, matcher := range self.OutputMatchers {
+		if matcher.Matches(stdout, stderr) {
+			return matcher.Fate
+		}
+	}
+
+	successCodes := self.SuccessExitCodes
+	if successCodes == nil {
+		successCodes = []int{0}
+	}
+	switch {
+	case containsInt(successCodes, exitCode):
+		return common.SubprocFateSucceeded
+	case containsInt(self.WarningExitCodes, exitCode):
+		return common.SubprocFateWarning
+	default:
+		return common.SubprocFateFailed
+	}
+}
@@ -0,0 +1,57 @@
+package jobfile
+
+import (
+	"testing"
+
+	"github.com/dshearer/jobber/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJudge(t *testing.T) {
+	var job Job
+
+	// by default, only 0 means success
+	require.Equal(t, common.SubprocFateSucceeded, job.Judge(0, nil, nil))
+	require.Equal(t, common.SubprocFateFailed, job.Judge(1, nil, nil))
+	require.Equal(t, common.SubprocFateFailed, job.Judge(-1, nil, nil))
+
+	job.SuccessExitCodes = []int{0, 1}
+	job.WarningExitCodes = []int{2}
+	require.Equal(t, common.SubprocFateSucceeded, job.Judge(1, nil, nil))
+	require.Equal(t, common.SubprocFateWarning, job.Judge(2, nil, nil))
+	require.Equal(t, common.SubprocFateFailed, job.Judge(3, nil, nil))
+
+	// output matchers override exit codes, and the first match wins
+	for _, raw := range []OutputMatcherRaw{
+		{Stream: "stdout", Pattern: "^ERROR", Fate: "failed"},
+		{Pattern: "(?i)warn", Fate: "warning"},
+		{Stream: "stderr", Pattern: "nothing to do", Fate: "succeeded"},
+	} {
+		matcher, err := raw.ToOutputMatcher()
+		require.Nil(t, err, "%v", err)
+		job.OutputMatchers = append(job.OutputMatchers, matcher)
+	}
+	require.Equal(t, common.SubprocFateFailed,
+		job.Judge(0, []byte("ERROR: disk full\n"), []byte("Warning\n")))
+	require.Equal(t, common.SubprocFateSucceeded,
+		job.Judge(0, []byte("not an ERROR\n"), nil))
+	require.Equal(t, common.SubprocFateWarning,
+		job.Judge(0, nil, []byte("WARNING: low memory\n")))
+	require.Equal(t, common.SubprocFateSucceeded,
+		job.Judge(5, nil, []byte("nothing to do\n")))
+	require.Equal(t, common.SubprocFateFailed,
+		job.Judge(5, []byte("nothing to do\n"), nil))
+}
+
+func TestOutputMatcherRawErrors(t *testing.T) {
+	for _, raw := range []OutputMatcherRaw{
+		{Stream: "stdin", Pattern: "x", Fate: "failed"},
+		{Pattern: "", Fate: "failed"},
+		{Pattern: "(", Fate: "failed"},
+		{Pattern: "x", Fate: "cancelled"},
+		{Pattern: "x"},
+	} {
+		_, err := raw.ToOutputMatcher()
+		require.NotNil(t, err, "%+v", raw)
+	}
+}
@@ -15,6 +15,12 @@ const (
 	TimeWildcard = "*"
 )
 
+const (
+	gMdayFieldName = "month day"
+	gMonFieldName  = "month"
+	gWdayFieldName = "weekday"
+)
+
 func monthToInt(m time.Month) int {
 	switch m {
 	case time.January:
@@ -70,6 +76,16 @@ type TimeSpec interface {
 	Derandomize()
 }
 
+/*
+A time spec for the month-day or weekday field that depends on the whole
+date (e.g., "last day of the month").  For such specs, Satisfied always
+returns false; use SatisfiedByDate instead.
+*/
+type DateTimeSpec interface {
+	TimeSpec
+	SatisfiedByDate(t time.Time) bool
+}
+
 type FullTimeSpec struct {
 	Sec  TimeSpec
 	Min  TimeSpec
@@ -99,10 +115,20 @@ func (self *FullTimeSpec) Derandomize() {
 }
 
 func (self FullTimeSpec) Satisfied(t time.Time) bool {
-	nondayMatch := self.Sec.Satisfied(t.Second()) &&
-		self.Min.Satisfied(t.Minute()) &&
+	return self.dateSatisfied(t) &&
 		self.Hour.Satisfied(t.Hour()) &&
-		self.Mon.Satisfied(monthToInt(t.Month()))
+		self.Min.Satisfied(t.Minute()) &&
+		self.Sec.Satisfied(t.Second())
+}
+
+/*
+Get whether the month, month-day, and weekday parts of the spec are
+satisfied by t.
+*/
+func (self FullTimeSpec) dateSatisfied(t time.Time) bool {
+	if !self.Mon.Satisfied(monthToInt(t.Month())) {
+		return false
+	}
 
 	/*
 	   - If Mday and Wday are wildcards, then both must be satisfied.
@@ -111,16 +137,95 @@ func (self FullTimeSpec) Satisfied(t time.Time) bool {
 	   - If neither Mday nor Wday is a wildcard, then either must be
 	   satisfied.
 	*/
-	dayMatch := false
+	mdaySatisfied := func() bool {
+		if spec, ok := self.Mday.(DateTimeSpec); ok {
+			return spec.SatisfiedByDate(t)
+		}
+		return self.Mday.Satisfied(t.Day())
+	}
+	wdaySatisfied := func() bool {
+		if spec, ok := self.Wday.(DateTimeSpec); ok {
+			return spec.SatisfiedByDate(t)
+		}
+		return self.Wday.Satisfied(weekdayToInt(t.Weekday()))
+	}
 	if !self.Mday.IsWildcard() && !self.Wday.IsWildcard() {
-		dayMatch = self.Wday.Satisfied(weekdayToInt(t.Weekday())) ||
-			self.Mday.Satisfied(t.Day())
+		return wdaySatisfied() || mdaySatisfied()
 	} else {
-		dayMatch = self.Wday.Satisfied(weekdayToInt(t.Weekday())) &&
-			self.Mday.Satisfied(t.Day())
+		return wdaySatisfied() && mdaySatisfied()
 	}
+}
 
-	return nondayMatch && dayMatch
+/*
+How far into the future NextTime will look for a time that satisfies
+a spec.
+*/
+const MaxScheduleLookahead = 2 * 365 * 24 * time.Hour
+
+/*
+Get the earliest time t such that t >= now, t is a whole number of
+seconds after now, and t satisfies the spec.  If there is no such
+time within MaxScheduleLookahead of now, returns nil.
+
+This is equivalent to testing every second from now on, but whole
+days, hours, and minutes that cannot satisfy the spec are skipped.
+*/
+func (self FullTimeSpec) NextTime(now time.Time) *time.Time {
+	/*
+		Golang has a bug in its time package.  We must avoid using most of
+		the Time methods on values with monotonic clock readings.
+		Cf. https://github.com/golang/go/issues/27090
+	*/
+	now = now.Round(0)
+
+	max := now.Add(MaxScheduleLookahead)
+	next := now
+	for next.Before(max) {
+		if !self.dateSatisfied(next) {
+			// skip to the start of the next day
+			y, m, d := next.Date()
+			next = time.Date(y, m, d+1, 0, 0, 0, next.Nanosecond(),
+				next.Location())
+			continue
+		}
+		if !self.Hour.Satisfied(next.Hour()) {
+			// skip to the start of the next hour
+			secs := (59-next.Minute())*60 + (60 - next.Second())
+			next = next.Add(time.Duration(secs) * time.Second)
+			continue
+		}
+		if !self.Min.Satisfied(next.Minute()) {
+			// skip to the start of the next minute
+			secs := 60 - next.Second()
+			next = next.Add(time.Duration(secs) * time.Second)
+			continue
+		}
+		if !self.Sec.Satisfied(next.Second()) {
+			next = next.Add(time.Second)
+			continue
+		}
+		return &next
+	}
+
+	return nil
+}
+
+/*
+Get the first n times at or after now that satisfy the spec, in the
+same way that the runner schedules a job: each time is the NextTime
+after one second past the previous one.
+*/
+func (self FullTimeSpec) NextTimes(now time.Time, n int) []time.Time {
+	var times []time.Time
+	for len(times) < n {
+		next := self.NextTime(now)
+		if next == nil {
+			break
+		}
+		times = append(times, *next)
+		now = next.Add(time.Second)
+	}
+	return times
 }
 
 type WildcardTimeSpec struct{}
@@ -181,6 +286,133 @@ func (self SetTimeSpec) Satisfied(v int) bool {
 
 func (self SetTimeSpec) Derandomize() {}
 
+func daysInMonth(t time.Time) int {
+	y, m, _ := t.Date()
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+/*
+A month-day spec for the last day of the month ("L") or, if Weekday is
+true, the last weekday (Monday to Friday) of the month ("LW").
+*/
+type LastDayTimeSpec struct {
+	Weekday bool
+}
+
+func (self LastDayTimeSpec) IsWildcard() bool {
+	return false
+}
+
+func (self LastDayTimeSpec) String() string {
+	if self.Weekday {
+		return "LW"
+	}
+	return "L"
+}
+
+func (self LastDayTimeSpec) Satisfied(v int) bool {
+	return false
+}
+
+func (self LastDayTimeSpec) SatisfiedByDate(t time.Time) bool {
+	lastDay := daysInMonth(t)
+	if !self.Weekday {
+		return t.Day() == lastDay
+	}
+	switch t.AddDate(0, 0, lastDay-t.Day()).Weekday() {
+	case time.Saturday:
+		lastDay--
+	case time.Sunday:
+		lastDay -= 2
+	}
+	return t.Day() == lastDay
+}
+
+func (self LastDayTimeSpec) Derandomize() {}
+
+/*
+A month-day spec for the weekday (Monday to Friday) nearest to the
+given day of the month ("15W").  The weekday is always in the same
+month: if the 1st is a Saturday, "1W" is the 3rd.  If the month doesn't
+have the given day, the spec is not satisfied in that month.
+*/
+type NearestWeekdayTimeSpec struct {
+	Day int
+}
+
+func (self NearestWeekdayTimeSpec) IsWildcard() bool {
+	return false
+}
+
+func (self NearestWeekdayTimeSpec) String() string {
+	return fmt.Sprintf("%vW", self.Day)
+}
+
+func (self NearestWeekdayTimeSpec) Satisfied(v int) bool {
+	return false
+}
+
+func (self NearestWeekdayTimeSpec) SatisfiedByDate(t time.Time) bool {
+	lastDay := daysInMonth(t)
+	if self.Day > lastDay {
+		return false
+	}
+	day := self.Day
+	switch t.AddDate(0, 0, day-t.Day()).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			day += 2
+		} else {
+			day--
+		}
+	case time.Sunday:
+		if day == lastDay {
+			day -= 2
+		} else {
+			day++
+		}
+	}
+	return t.Day() == day
+}
+
+func (self NearestWeekdayTimeSpec) Derandomize() {}
+
+/*
+A weekday spec for the Nth occurrence of a weekday in the month ("5#3"
+for the third Friday) or, if Nth is 0, the last occurrence ("5L").
+*/
+type NthWdayTimeSpec struct {
+	Wday int
+	Nth  int
+}
+
+func (self NthWdayTimeSpec) IsWildcard() bool {
+	return false
+}
+
+func (self NthWdayTimeSpec) String() string {
+	if self.Nth == 0 {
+		return fmt.Sprintf("%vL", self.Wday)
+	}
+	return fmt.Sprintf("%v#%v", self.Wday, self.Nth)
+}
+
+func (self NthWdayTimeSpec) Satisfied(v int) bool {
+	return false
+}
+
+func (self NthWdayTimeSpec) SatisfiedByDate(t time.Time) bool {
+	if weekdayToInt(t.Weekday()) != self.Wday {
+		return false
+	}
+	if self.Nth == 0 {
+		return t.Day()+7 > daysInMonth(t)
+	}
+	return (t.Day()-1)/7+1 == self.Nth
+}
+
+func (self NthWdayTimeSpec) Derandomize() {}
+
 /*
 A time spec that chooses (pseudo-)randomly from a set of values.
 Each value in that set has an (approximately) equal chance of getting
@@ -285,7 +517,7 @@ func ParseFullTimeSpec(s string) (*FullTimeSpec, error) {
 
 	// mday
 	if len(timeParts) > 3 {
-		spec, err := parseTimeSpec(timeParts[3], "month day", 1, 31)
+		spec, err := parseTimeSpec(timeParts[3], gMdayFieldName, 1, 31)
 		if err != nil {
 			return nil, err
 		}
@@ -294,7 +526,7 @@ func ParseFullTimeSpec(s string) (*FullTimeSpec, error) {
 
 	// month
 	if len(timeParts) > 4 {
-		spec, err := parseTimeSpec(timeParts[4], "month", 1, 12)
+		spec, err := parseTimeSpec(timeParts[4], gMonFieldName, 1, 12)
 		if err != nil {
 			return nil, err
 		}
@@ -303,7 +535,7 @@ func ParseFullTimeSpec(s string) (*FullTimeSpec, error) {
 
 	// wday
 	if len(timeParts) > 5 {
-		spec, err := parseTimeSpec(timeParts[5], "weekday", 0, 6)
+		spec, err := parseTimeSpec(timeParts[5], gWdayFieldName, 0, 6)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,334 @@
+package jobfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ProblemSeverity int
+
+const (
+	ProblemError ProblemSeverity = iota
+	ProblemWarning
+)
+
+func (self ProblemSeverity) String() string {
+	switch self {
+	case ProblemError:
+		return "error"
+	default:
+		return "warning"
+	}
+}
+
+/*
+A problem found in a jobfile by ValidateJobfile.  Line and Col are
+1-based; they are 0 if the location of the problem is not known.
+*/
+type Problem struct {
+	Severity ProblemSeverity
+	Line     int
+	Col      int
+	Msg      string
+}
+
+func (self Problem) String() string {
+	if self.Line == 0 {
+		return fmt.Sprintf("%v: %v", self.Severity, self.Msg)
+	} else if self.Col == 0 {
+		return fmt.Sprintf("%v: %v: %v", self.Line, self.Severity, self.Msg)
+	}
+	return fmt.Sprintf("%v:%v: %v: %v", self.Line, self.Col, self.Severity,
+		self.Msg)
+}
+
+/*
+If a job's schedule makes it run more often than this, ValidateJobfile
+warns about it.
+*/
+const gMinExpectedRunInterval = time.Minute
+
+var gYamlErrLineRegexp = regexp.MustCompile(`line (\d+):`)
+
+/*
+Check a jobfile for problems, without talking to a runner and without
+making run logs, starting servers, etc.
+
+Returns the parsed jobfile (or nil, if it could not be parsed) and a
+list of problems, ordered by location.
+*/
+func ValidateJobfile(f *os.File, usr *user.User, now time.Time) (
+	*JobFileRaw, []Problem) {
+
+	var problems []Problem
+
+	// read file (so that we can locate problems)
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, []Problem{{Severity: ProblemError, Msg: err.Error()}}
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, []Problem{{Severity: ProblemError, Msg: err.Error()}}
+	}
+	lines := strings.Split(string(data), "\n")
+
+	// check owner and perms
+	if ok, err := ShouldLoadJobfile(f, usr); !ok {
+		problems = append(problems, Problem{
+			Severity: ProblemWarning,
+			Msg:      fmt.Sprintf("Jobber would refuse to load it: %v", err),
+		})
+	}
+
+	// parse it
+	raw, err := LoadJobfile(f)
+	if err != nil {
+		problems = append(problems, yamlErrorProblems(err)...)
+		return nil, problems
+	}
+
+	// activate it
+	for _, err := range raw.Check(usr) {
+		problem := Problem{Severity: ProblemError, Msg: err.Error()}
+		if fieldErr, ok := err.(*FieldError); ok {
+			problem.Line, problem.Col = locateKey(lines, fieldErr.Path)
+		}
+		problems = append(problems, problem)
+	}
+
+	// lint jobs
+	for jobName, jobRaw := range raw.Jobs {
+		job := Job{Name: jobName, ErrorHandler: ContinueErrorHandler{}}
+		if err := jobRaw.ToJob(usr, &job); err != nil {
+			/* already reported */
+			continue
+		}
+		for _, problem := range lintJob(&job, now) {
+			problem.Line, problem.Col = locateKey(lines, problem.path)
+			problems = append(problems, problem.Problem)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Col < problems[j].Col
+	})
+	return raw, problems
+}
+
+/*
+Convert an error from the YAML parser into problems.  Such errors
+may contain several lines, each of which mentions a line number.
+*/
+func yamlErrorProblems(err error) []Problem {
+	var problems []Problem
+	for _, msg := range strings.Split(err.Error(), "\n") {
+		msg = strings.TrimSpace(msg)
+		if len(msg) == 0 || msg == "yaml: unmarshal errors:" {
+			continue
+		}
+		problem := Problem{Severity: ProblemError, Msg: msg}
+		if matches := gYamlErrLineRegexp.FindStringSubmatch(msg); matches != nil {
+			problem.Line, _ = strconv.Atoi(matches[1])
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) == 0 {
+		problems = append(problems,
+			Problem{Severity: ProblemError, Msg: err.Error()})
+	}
+	return problems
+}
+
+type jobLintProblem struct {
+	Problem
+	path []string
+}
+
+func lintJob(job *Job, now time.Time) []jobLintProblem {
+	var problems []jobLintProblem
+	warn := func(field string, msg string) {
+		problems = append(problems, jobLintProblem{
+			Problem: Problem{Severity: ProblemWarning, Msg: msg},
+			path:    []string{JobsSectName, job.Name, field},
+		})
+	}
+
+	if len(strings.TrimSpace(job.Cmd)) == 0 {
+		warn("cmd", fmt.Sprintf("Job \"%v\" has no command", job.Name))
+	}
+
+	// check active window
+	if job.ActiveUntil != nil && !now.Before(*job.ActiveUntil) {
+		warn("activeUntil", fmt.Sprintf("Job \"%v\" has expired, so it "+
+			"will not run", job.Name))
+		return problems
+	}
+
+	// check one-shot jobs' times
+	if job.At != nil {
+		if job.At.Before(now) {
+			warn("at", fmt.Sprintf("Job \"%v\"'s time has passed, so it "+
+				"will run as soon as the jobfile is loaded", job.Name))
+		}
+		return problems
+	}
+
+	// check watched paths
+	if job.Watch != nil {
+		if _, err := os.Stat(job.Watch.Path); err != nil {
+			warn("watch", fmt.Sprintf("Job \"%v\" watches %v, which "+
+				"doesn't exist, so it can't be triggered", job.Name,
+				job.Watch.Path))
+		}
+		if job.Unscheduled {
+			return problems
+		}
+	}
+
+	// check that the schedule fires, and not too often
+	first := job.FullTimeSpec.NextTime(now)
+	if first == nil {
+		problems = append(problems, jobLintProblem{
+			Problem: Problem{
+				Severity: ProblemError,
+				Msg: fmt.Sprintf("Job \"%v\" will never run: no time "+
+					"in the next %v years satisfies \"%v\"", job.Name,
+					int(MaxScheduleLookahead.Hours()/24/365),
+					job.FullTimeSpec),
+			},
+			path: []string{JobsSectName, job.Name, "time"},
+		})
+		return problems
+	}
+	minInterval := MaxScheduleLookahead
+	prev := *first
+	for i := 0; i < 5; i++ {
+		next := job.FullTimeSpec.NextTime(prev.Add(time.Second))
+		if next == nil {
+			break
+		}
+		if interval := next.Sub(prev); interval < minInterval {
+			minInterval = interval
+		}
+		prev = *next
+	}
+	if minInterval < gMinExpectedRunInterval {
+		warn("time", fmt.Sprintf("Job \"%v\" runs every %v (note that "+
+			"the first field of \"time\" is seconds, not minutes)",
+			job.Name, minInterval))
+	}
+
+	return problems
+}
+
+/*
+Find the line and column (both 1-based) of the YAML key at the given
+path (e.g., ["jobs", "DailyBackup", "time"]).  If the full path cannot
+be found, returns the location of the longest prefix that can be found,
+or (0, 0) if not even the first key can be found.
+
+This works on the text rather than on the parsed document, because the
+YAML library doesn't tell us where things are.  It only handles block
+mappings.
+*/
+func locateKey(lines []string, path []string) (int, int) {
+	found := findKeyPath(lines, path)
+	if len(found) == 0 {
+		return 0, 0
+	}
+	lineIdx := found[len(found)-1]
+	return lineIdx + 1, indentOf(lines[lineIdx]) + 1
+}
+
+/*
+Find the lines containing the keys of the longest prefix of the given
+path that can be found.  Returns the (0-based) line indices, one per
+key found.
+*/
+func findKeyPath(lines []string, path []string) []int {
+	var found []int
+	start := 0
+	parentIndent := -1
+	for _, key := range path {
+		childIndent := -1
+		lineIdx := -1
+		for i := start; i < len(lines); i++ {
+			if isYamlFiller(lines[i]) {
+				continue
+			}
+			indent := indentOf(lines[i])
+			if indent <= parentIndent {
+				// end of parent's block
+				break
+			}
+			if childIndent < 0 {
+				childIndent = indent
+			}
+			if indent != childIndent {
+				continue
+			}
+			trimmed := strings.TrimSpace(lines[i])
+			if k, ok := yamlKey(trimmed); ok && k == key {
+				lineIdx = i
+				break
+			}
+		}
+		if lineIdx < 0 {
+			break
+		}
+		found = append(found, lineIdx)
+		start = lineIdx + 1
+		parentIndent = indentOf(lines[lineIdx])
+	}
+	return found
+}
+
+/*
+Return whether the given line has no content (i.e., it is blank, a
+comment, or a document starter).
+*/
+func isYamlFiller(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) == 0 || trimmed[0] == '#' ||
+		strings.HasPrefix(trimmed, gYamlStarter)
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+/*
+If the given (left-trimmed) line starts with a mapping key, return
+that key.
+*/
+func yamlKey(s string) (string, bool) {
+	if len(s) == 0 {
+		return "", false
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", false
+		}
+		rest := strings.TrimLeft(s[end+2:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", false
+		}
+		return s[1 : end+1], true
+	}
+	idx := strings.Index(s, ":")
+	if idx <= 0 || (idx+1 < len(s) && s[idx+1] != ' ' && s[idx+1] != '\t') {
+		return "", false
+	}
+	return strings.TrimRight(s[:idx], " "), true
+}
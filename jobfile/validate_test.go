@@ -0,0 +1,165 @@
+package jobfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type ValidateTestCase struct {
+	Input string
+
+	/*
+	   Expected problems that have a location.  (Problems without
+	   a location, like bad file permissions, are ignored.)
+	*/
+	Problems []Problem
+}
+
+var gValidateTestCases = []ValidateTestCase{
+	{
+		Input:    gV3JobFileContents,
+		Problems: nil,
+	},
+	{
+		Input: `version: 1.4
+
+jobs:
+  Job1:
+    cmd: echo hi
+    time: 0 0 14
+
+  Job2:
+    cmd: echo bye
+    # comment
+    time: 0 0 25
+`,
+		Problems: []Problem{
+			{Severity: ProblemError, Line: 11, Col: 5},
+		},
+	},
+	{
+		Input: `version: 1.4
+
+prefs:
+  runLog:
+    type: file
+    path: /tmp/log
+    maxFileLen: 10XB
+
+jobs:
+  Job1:
+    cmd: echo hi
+    time: 0 0 14
+    onError: Explode
+`,
+		Problems: []Problem{
+			{Severity: ProblemError, Line: 4, Col: 3},
+			{Severity: ProblemError, Line: 13, Col: 5},
+		},
+	},
+	{
+		Input: `version: 1.4
+
+jobs:
+  Feb30:
+    cmd: echo never
+    time: 0 0 0 30 2
+  TooOften:
+    cmd: echo often
+    time: '*'
+`,
+		Problems: []Problem{
+			{Severity: ProblemError, Line: 6, Col: 5},
+			{Severity: ProblemWarning, Line: 9, Col: 5},
+		},
+	},
+	{
+		Input: `version: 1.4
+
+jobs:
+  Job1:
+    cmd: echo hi
+    time: [0 0 14
+`,
+		Problems: []Problem{
+			{Severity: ProblemError, Line: 6},
+		},
+	},
+}
+
+func TestValidateJobfile(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.Local)
+
+	for _, testCase := range gValidateTestCases {
+		/*
+		 * Set up
+		 */
+		fmt.Printf("Input:\n%v\n", testCase.Input)
+
+		// make jobfile
+		f, err := ioutil.TempFile("", "Testing")
+		if err != nil {
+			panic(fmt.Sprintf("Failed to make tempfile: %v", err))
+		}
+		defer f.Close()
+		defer os.Remove(f.Name())
+		f.WriteString(testCase.Input)
+		f.Seek(0, 0)
+
+		/*
+		 * Call
+		 */
+		_, problems := ValidateJobfile(f, &gUserEx, now)
+
+		/*
+		 * Test
+		 */
+		var located []Problem
+		for _, problem := range problems {
+			fmt.Printf("%v\n", problem)
+			if problem.Line == 0 {
+				continue
+			}
+			require.NotEmpty(t, problem.Msg)
+			problem.Msg = ""
+			located = append(located, problem)
+		}
+		require.Equal(t, testCase.Problems, located)
+	}
+}
+
+func TestLocateKey(t *testing.T) {
+	lines := strings.Split(`version: 1.4
+jobs:
+  # time: here
+  "Job1":
+    cmd: echo time
+    time: 0 0 14
+  Job2:
+    time: 0 0 15
+`, "\n")
+
+	cases := []struct {
+		Path []string
+		Line int
+		Col  int
+	}{
+		{[]string{"jobs"}, 2, 1},
+		{[]string{"jobs", "Job1"}, 4, 3},
+		{[]string{"jobs", "Job1", "time"}, 6, 5},
+		{[]string{"jobs", "Job2", "time"}, 8, 5},
+		{[]string{"jobs", "Job2", "cmd"}, 7, 3},
+		{[]string{"prefs", "logPath"}, 0, 0},
+	}
+	for _, c := range cases {
+		line, col := locateKey(lines, c.Path)
+		require.Equal(t, c.Line, line, "%v", c.Path)
+		require.Equal(t, c.Col, col, "%v", c.Path)
+	}
+}
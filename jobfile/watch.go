@@ -0,0 +1,119 @@
+package jobfile
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/dshearer/jobber/common"
+)
+
+/*
+A job's "watch" block makes it run when a file or directory changes
+(currently only on Linux):
+
+	watch:
+	  path: inbox             # a file or dir (relative to the home dir)
+	  events: [create, move]  # which changes count (default: create, write, move)
+	  debounce: 5s            # wait for this much quiet first (default: 1s)
+
+When path is a dir, changes to the files in it count.  The job runs
+once the changes stop for the debounce period, and the path of the
+last one is in the JOBBER_WATCH_PATH environment variable.  A job with
+a "watch" block but no "time" runs only when triggered.
+*/
+
+const WatchPathEnvVar = "JOBBER_WATCH_PATH"
+
+type WatchEvents uint32
+
+const (
+	WatchCreate WatchEvents = 1 << iota // a file was created
+	WatchWrite                          // a file opened for writing was closed
+	WatchModify                         // a file was modified
+	WatchDelete                         // a file was deleted
+	WatchMove                           // a file was moved (or renamed) in or out
+	WatchAttrib                         // a file's metadata changed
+)
+
+var gWatchEventNames = []struct {
+	name  string
+	event WatchEvents
+}{
+	{"create", WatchCreate},
+	{"write", WatchWrite},
+	{"modify", WatchModify},
+	{"delete", WatchDelete},
+	{"move", WatchMove},
+	{"attrib", WatchAttrib},
+}
+
+const (
+	gDefaultWatchEvents   = WatchCreate | WatchWrite | WatchMove
+	gDefaultWatchDebounce = time.Second
+)
+
+func (self WatchEvents) String() string {
+	var names []string
+	for _, e := range gWatchEventNames {
+		if self&e.event != 0 {
+			names = append(names, e.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+type WatchRaw struct {
+	Path     string   `json:"path" yaml:"path"`
+	Events   []string `json:"events" yaml:"events,omitempty"`
+	Debounce *string  `json:"debounce" yaml:"debounce,omitempty"`
+}
+
+type WatchSpec struct {
+	Path     string
+	Events   WatchEvents
+	Debounce time.Duration
+}
+
+func (self WatchRaw) ToWatchSpec(usr *user.User) (*WatchSpec, error) {
+	spec := WatchSpec{Debounce: gDefaultWatchDebounce}
+
+	var err error
+	if spec.Path, err = guardPath(usr, self.Path); err != nil {
+		return nil, &common.Error{What: "Invalid path", Cause: err}
+	}
+
+	if len(self.Events) == 0 {
+		spec.Events = gDefaultWatchEvents
+	}
+	for _, name := range self.Events {
+		found := false
+		for _, e := range gWatchEventNames {
+			if strings.EqualFold(name, e.name) {
+				spec.Events |= e.event
+				found = true
+				break
+			}
+		}
+		if !found {
+			msg := fmt.Sprintf("Invalid event: \"%v\"", name)
+			return nil, &common.Error{What: msg}
+		}
+	}
+
+	if self.Debounce != nil {
+		spec.Debounce, err = time.ParseDuration(*self.Debounce)
+		if err != nil {
+			return nil, &common.Error{What: "Invalid debounce", Cause: err}
+		}
+		if spec.Debounce < 0 {
+			return nil, &common.Error{What: "debounce must not be negative"}
+		}
+	}
+	return &spec, nil
+}
+
+func (self *WatchSpec) String() string {
+	return fmt.Sprintf("watch %v", self.Path)
+}